@@ -3,38 +3,42 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
-	"os"
-	"strconv"
+	"fmt"
 	"time"
 
 	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
 )
 
+const (
+	// greenSchedulingPause gives ECS time to schedule the green tasks before their status is checked
+	greenSchedulingPause = 45 * time.Second
+)
+
 var (
 	initialDesiredCount int
 )
 
 // Returns blue service, green service, error
-func determineBlueGreen(e types.ECSClient, blueService string, greenService string, cluster string) (string, string, error) {
+func determineBlueGreen(ctx context.Context, e types.ECSClient, blueService string, greenService string, cluster string) (string, string, error) {
 
-	blueCount, err := deploy.GetServiceDesiredCount(context.Background(), e, blueService, cluster)
+	blueCount, err := deploy.GetServiceDesiredCount(ctx, e, blueService, cluster)
 
 	if err != nil {
-		log.Println("Error retrieving desired count for blue service", err.Error())
+		logging.From(ctx).Error("Error retrieving desired count for blue service", logging.Err(err))
 		return "", "", errors.New("deploy failed")
 	}
 
-	greenCount, err := deploy.GetServiceDesiredCount(context.Background(), e, greenService, cluster)
+	greenCount, err := deploy.GetServiceDesiredCount(ctx, e, greenService, cluster)
 
 	if err != nil {
-		log.Println("Error retrieving desired count for blue service", err.Error())
+		logging.From(ctx).Error("Error retrieving desired count for blue service", logging.Err(err))
 		return "", "", errors.New("deploy failed")
 	}
 
-	log.Printf("Service '%s' has a desired count of '%d'\n", blueService, blueCount)
-	log.Printf("Service '%s' has a desired count of '%d'\n", greenService, greenCount)
+	logging.From(ctx).Info(fmt.Sprintf("Service '%s' has a desired count of '%d'", blueService, blueCount))
+	logging.From(ctx).Info(fmt.Sprintf("Service '%s' has a desired count of '%d'", greenService, greenCount))
 
 	if blueCount == 0 {
 		return greenService, blueService, nil
@@ -44,67 +48,93 @@ func determineBlueGreen(e types.ECSClient, blueService string, greenService stri
 		return blueService, greenService, nil
 	}
 
-	log.Println("Unable to determine which service is blue and which is green")
-	log.Printf("Service '%s' has %d desired replicas while service '%s' has %d desired replicas. One of these should be 0\n", blueService, blueCount, greenService, greenCount)
+	logging.From(ctx).Error("Unable to determine which service is blue and which is green")
+	logging.From(ctx).Info(fmt.Sprintf("Service '%s' has %d desired replicas while service '%s' has %d desired replicas. One of these should be 0", blueService, blueCount, greenService, greenCount))
 	return "", "", errors.New("reconcile error")
 }
 
-func blueGreen(dc deploy.DeployConfig, maxDeployChecks int) error {
-	log.Println("Beginning blue green deployment")
+func blueGreen(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller) error {
+	logging.From(ctx).Info("Beginning blue green deployment")
 
-	blueServiceName := os.Getenv("PLUGIN_BLUE_SERVICE")
-	greenServiceName := os.Getenv("PLUGIN_GREEN_SERVICE")
+	blueServiceName := settings.BlueService
+	greenServiceName := settings.GreenService
 
-	determinedBlueService, determinedGreenService, err := determineBlueGreen(dc.ECS, blueServiceName, greenServiceName, dc.Cluster)
+	determinedBlueService, determinedGreenService, err := determineBlueGreen(ctx, dc.ECS, blueServiceName, greenServiceName, dc.Cluster)
 
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Determined service '%s' is blue and '%s' is green\n", determinedBlueService, determinedGreenService)
+	logging.From(ctx).Info(fmt.Sprintf("Determined service '%s' is blue and '%s' is green", determinedBlueService, determinedGreenService))
+	deployResult.SetBlueGreen(determinedBlueService, determinedGreenService)
+	deployResult.SetLive(determinedBlueService, "")
 
-	td, err := deploy.GetServiceRunningTaskDefinition(context.TODO(), dc.ECS, determinedBlueService, dc.Cluster)
+	td, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, determinedBlueService, dc.Cluster)
 
 	if err != nil {
-		log.Println("Failing because of an error determining the currently in-use task definition")
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition")
 		return err
 	}
 
-	currTD, err := deploy.RetrieveTaskDefinition(context.TODO(), dc.ECS, td)
+	currTD, err := deploy.RetrieveTaskDefinition(ctx, dc.ECS, td)
 
 	if err != nil {
-		log.Println("Failing because of an error retrieving the currently in-use task definition")
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition")
 		return err
 	}
 
-	newTD, err := deploy.CreateNewTaskDefinitionRevision(context.TODO(), dc.ECS, currTD, dc.Container, dc.Image)
+	newTD, err := deploy.CreateNewTaskDefinitionRevision(ctx, dc.ECS, currTD, dc.TaskDefinitionChanges())
 
 	if err != nil {
-		log.Println("Failing because of an error retrieving the creating a new task definition revision")
+		logging.From(ctx).Error("Failing because of an error retrieving the creating a new task definition revision")
 		return err
 	}
 
-	log.Println("Created new task definition revision", newTD.Revision)
+	logging.From(ctx).Info(fmt.Sprintf("Created new task definition revision %v", newTD.Revision))
+	deployStarted(currTD, *newTD)
 
-	currBlueDesiredCount, err := deploy.GetServiceDesiredCount(context.Background(), dc.ECS, determinedBlueService, dc.Cluster)
+	showTaskDefinitionDiff(currTD, *newTD)
+
+	if err := runPreDeployTask(ctx, dc.ECS, dc.Cluster, determinedBlueService, *newTD.TaskDefinitionArn, p); err != nil {
+		return err
+	}
+
+	currBlueDesiredCount, err := deploy.GetServiceDesiredCount(ctx, dc.ECS, determinedBlueService, dc.Cluster)
 
 	if err != nil {
-		log.Println("Failing because of an error determining desired count for blue service", err.Error())
+		logging.From(ctx).Error("Failing because of an error determining desired count for blue service", logging.Err(err))
 		return err
 	}
 
+	// Every poll of this deployment prints the events of both services
+	dc.Events = deploy.NewEventStream(dc.ECS, dc.Cluster, determinedGreenService, determinedBlueService)
+
+	// The checks of every poll share one description of green
+	cache := deploy.NewServiceCache(dc.ECS)
+	greenDC := dc
+	greenDC.ECS = cache
+
+	// Green has no deployment ID, so its stopped tasks are the ones created after the update
+	detector := &deploy.FailureDetector{
+		Client:    cache,
+		Cluster:   dc.Cluster,
+		Service:   determinedGreenService,
+		Since:     dc.Events.Since,
+		Threshold: failFastThreshold,
+	}
+
 	// There is no deployment ID so discard it
-	_, err = deploy.UpdateServiceTaskDefinitionVersion(context.TODO(), dc.ECS, determinedGreenService, dc.Cluster, *newTD.TaskDefinitionArn)
+	_, err = deploy.UpdateServiceTaskDefinitionVersion(ctx, dc.ECS, determinedGreenService, dc.Cluster, *newTD.TaskDefinitionArn)
 
 	if err != nil {
-		log.Println("Error updating task definition for service", err.Error())
+		logging.From(ctx).Error("Error updating task definition for service", logging.Err(err))
 		return errors.New("deploy failed")
 	}
 
-	serviceUsesAppAutoscaling, err := deploy.AppAutoscalingTargetExists(context.Background(), dc.AppAutoscaling, dc.Cluster, determinedBlueService)
+	serviceUsesAppAutoscaling, err := deploy.AppAutoscalingTargetExists(ctx, dc.AppAutoscaling, dc.Cluster, determinedBlueService)
 
 	if err != nil {
-		log.Println("Error determining if service uses application autoscaling", err.Error())
+		logging.From(ctx).Error("Error determining if service uses application autoscaling", logging.Err(err))
 		return err
 	}
 
@@ -112,11 +142,11 @@ func blueGreen(dc deploy.DeployConfig, maxDeployChecks int) error {
 	var serviceMinCount int32
 
 	if serviceUsesAppAutoscaling {
-		log.Printf("Service '%s' uses application autoscaling. Will modify autoscaling max count", determinedGreenService)
-		serviceMaxCount, serviceMinCount, err = deploy.GetServiceMinMaxCount(context.Background(), dc.AppAutoscaling, dc.Cluster, determinedBlueService)
+		logging.From(ctx).Info(fmt.Sprintf("Service '%s' uses application autoscaling. Will modify autoscaling max count", determinedGreenService))
+		serviceMaxCount, serviceMinCount, err = deploy.GetServiceMinMaxCount(ctx, dc.AppAutoscaling, dc.Cluster, determinedBlueService)
 
 		if err != nil {
-			log.Println("Error determining service max count", err.Error())
+			logging.From(ctx).Error("Error determining service max count", logging.Err(err))
 			return err
 		}
 	} else {
@@ -125,179 +155,270 @@ func blueGreen(dc deploy.DeployConfig, maxDeployChecks int) error {
 	}
 
 	// Scale up green service to the same count as blue
-	dc.ScaleUp(currBlueDesiredCount, serviceMinCount, serviceMaxCount, determinedGreenService)
+	dc.ScaleUp(ctx, currBlueDesiredCount, serviceMinCount, serviceMaxCount, determinedGreenService)
 
-	log.Println("Pausing for 45 seconds while ECS schedules", currBlueDesiredCount, "containers")
+	logging.From(ctx).Info(fmt.Sprintf("Pausing for %v while ECS schedules %v containers", greenSchedulingPause, currBlueDesiredCount))
 	initialDesiredCount = int(currBlueDesiredCount)
-	time.Sleep(45 * time.Second)
 
-	// Start polling deployment
-	greenScaleupFinished := false
-	deployCounter := 0
-	successCounter := 0
+	if err := deploy.Sleep(ctx, greenSchedulingPause); err != nil {
+		removeGreen(ctx, dc, p, determinedGreenService, serviceUsesAppAutoscaling)
+		return errors.New("deploy failed")
+	}
 
-	successCountThreshold, _ := strconv.Atoi(os.Getenv("PLUGIN_CHECKS_TO_PASS"))
+	successCounter := 0
+	successCountThreshold := settings.ChecksToPass
 
-	greenScaleupFinished, err = dc.GreenScaleUpFinished(context.Background(), determinedGreenService)
+	// Consecutive healthy checks after green has scaled up do not count towards max_deploy_checks
+	scaleUpPoller := p
 
-	if err != nil {
-		log.Println("Error checking if green service has finished scaling", err.Error())
-		return err
+	if scaleUpPoller.MaxChecks >= 0 {
+		scaleUpPoller.MaxChecks += successCountThreshold
 	}
 
-	for {
-		// Ensure we haven't surpassed the max check limit
-		if deployCounter > maxDeployChecks {
-			log.Println("Max deploy checks surpassed. Scaling green down and marking deployment a failure")
-			dc.ScaleDown(0, 0, 0, determinedGreenService, serviceUsesAppAutoscaling)
-			return errors.New("deploy failed")
+	err = scaleUpPoller.Poll(ctx, "green service to scale up", func(ctx context.Context) (bool, error) {
+		cache.Reset()
+		dc.Events.Print(ctx)
+
+		if err := dc.Alarms.Check(ctx); err != nil {
+			return false, err
+		}
+
+		if err := detector.Check(ctx); err != nil {
+			return false, err
+		}
+
+		recordFirstHealthyTask(ctx, cache, dc.ELBv2, determinedGreenService, dc.Cluster, newTD)
+
+		greenScaleupFinished, err := greenDC.GreenScaleUpFinished(ctx, determinedGreenService)
+
+		if err != nil {
+			logging.From(ctx).Error("Error checking if green has finished scaling up", logging.Err(err))
+			return false, err
 		}
 
 		if !greenScaleupFinished {
-			// In this case, the service is not done scaling up
-			// Increment counter first
-			deployCounter++
+			// Reset successCounter, successful checks must be consecutive
+			successCounter = 0
+			return false, nil
+		}
 
-			// Check if scale up has finished
-			greenScaleupFinished, err = dc.GreenScaleUpFinished(context.Background(), determinedGreenService)
+		if dc.ELBv2 != nil {
+			targetsHealthy, err := deploy.CheckTargetsHealthy(ctx, cache, dc.ELBv2, determinedGreenService, dc.Cluster, "")
 
 			if err != nil {
-				log.Println("Error checking if green has finished scaling up", err.Error())
-				return err
+				logging.From(ctx).Error("Error checking the target health of green", logging.Err(err))
+				return false, err
 			}
-			// Wait for 10 seconds
-			log.Println("Waiting 10 seconds for green service to scale up")
-			time.Sleep(10 * time.Second)
-			// Reset successCounter, successful checks must be consecutive
-			successCounter = 0
 
-		} else {
-			// In this case, running == desired
-			// Now we need to make sure the healthy check threshold has been reached
-			if successCounter < successCountThreshold {
-				// We simply need to increment the counter here
-				// because we already know that running == desired
-				log.Println("Successful checks:", successCounter)
-				// Wait for 10 seconds
-				log.Println("Waiting 10 seconds before incrementing healthy checks")
-				time.Sleep(10 * time.Second)
-				successCounter++
-			} else {
-				// Again, running == desired
-				// _and_ successCounter >= successCountThreshold
-				log.Println("Green deployment has reached healthy check threshold")
-				break
+			if !targetsHealthy {
+				successCounter = 0
+				return false, nil
 			}
 		}
 
+		// In this case, running == desired
+		// Now we need to make sure the healthy check threshold has been reached
+		if successCounter < successCountThreshold {
+			logging.From(ctx).Info(fmt.Sprintf("Successful checks: %v", successCounter))
+			successCounter++
+			return false, nil
+		}
+
+		logging.From(ctx).Info("Green deployment has reached healthy check threshold")
+		return true, nil
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Green service did not scale up. Scaling green down and marking deployment a failure", logging.Err(err))
+		recordFailureCategory(err)
+		reportStoppedTasks(ctx, dc.ECS, dc.Logs, p, dc.Cluster, determinedGreenService, "", dc.Events.Since)
+		removeGreen(ctx, dc, p, determinedGreenService, serviceUsesAppAutoscaling)
+		return errors.New("deploy failed")
 	}
 
-	log.Printf("Green service '%s' finished scaling up! Scaling down blue service '%s'\n", determinedGreenService, determinedBlueService)
+	logging.From(ctx).Info(fmt.Sprintf("Green service '%s' finished scaling up! Scaling down blue service '%s'", determinedGreenService, determinedBlueService))
+	deployResult.SetLive(determinedGreenService, "")
 
-	log.Printf("Waiting %s seconds before scaling down blue", os.Getenv("PLUGIN_SCALE_DOWN_WAIT_PERIOD"))
+	logging.From(ctx).Info(fmt.Sprintf("Waiting %d seconds before scaling down blue", settings.ScaleDownWaitPeriod))
 
-	scaleDownPause, _ := strconv.Atoi(os.Getenv("PLUGIN_SCALE_DOWN_WAIT_PERIOD"))
+	if err := dc.Alarms.Watch(ctx, p.Interval, time.Duration(settings.ScaleDownWaitPeriod)*time.Second); err != nil {
+		if errors.Is(err, deploy.ErrAlarm) {
+			logging.From(ctx).Error("Alarm fired before scaling down blue. Scaling green down and marking deployment a failure", logging.Err(err))
+			removeGreen(ctx, dc, p, determinedGreenService, serviceUsesAppAutoscaling)
+			return errors.New("deploy failed")
+		}
 
-	time.Sleep(time.Duration(scaleDownPause) * time.Second)
+		logging.From(ctx).Info("Deploy deadline passed or the deploy was cancelled before scaling down blue")
+		return errors.New("deploy failed")
+	}
 
 	err = scaleDownInPercentages(
+		ctx,
 		dc,
+		p,
 		determinedBlueService,
 		serviceUsesAppAutoscaling,
-		os.Getenv("PLUGIN_SCALE_DOWN_PERCENT"),
-		os.Getenv("PLUGIN_SCALE_DOWN_INTERVAL"),
+		settings.ScaleDownPercent,
+		settings.ScaleDownInterval,
 		int(currBlueDesiredCount),
 	)
 
+	if err == nil && dc.BakePeriod > 0 {
+		logging.From(ctx).Info(fmt.Sprintf("Watching alarms for %v before the deployment succeeds", dc.BakePeriod))
+
+		err = dc.Alarms.Watch(ctx, p.Interval, dc.BakePeriod)
+	}
+
+	if errors.Is(err, deploy.ErrAlarm) {
+		logging.From(ctx).Error("Alarm fired after green went live", logging.Err(err))
+		rollbackCtx := rollbackStarted(ctx)
+		rollbackFinished(rollbackCtx, restoreBlue(rollbackCtx, dc, p, determinedBlueService, determinedGreenService, serviceUsesAppAutoscaling, currBlueDesiredCount, serviceMinCount, serviceMaxCount))
+		return errors.New("deploy failed")
+	}
+
 	return err
 }
 
-func scaleDownInPercentages(dc deploy.DeployConfig, service string, serviceUsesAppAutoscaling bool, scalePercent string, scaleDownInterval string, desiredCount int) error {
-	scalePercentString, err := strconv.Atoi(scalePercent)
+// restoreBlue scales blue back up to its count before the deployment and then scales green down
+// Green is left running if blue does not scale back up, so traffic is never left without a service. It returns false if blue was not restored or green was not scaled down
+func restoreBlue(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, blueService string, greenService string, serviceUsesAppAutoscaling bool, desiredCount int32, minCount int32, maxCount int32) bool {
+	// Blue must be restored even if the deploy deadline has passed
+	ctx, cancel := p.CleanupContext(ctx)
+	defer cancel()
 
-	if err != nil {
-		log.Println("Error converting scale down percent", scalePercent, "to integer. Failing.")
-		return err
+	logging.From(ctx).Info(fmt.Sprintf("Scaling blue service '%s' back up to %d", blueService, desiredCount))
+
+	if err := dc.ScaleUp(ctx, desiredCount, minCount, maxCount, blueService); err != nil {
+		logging.From(ctx).Error("Error scaling blue back up. Leaving green running", logging.Err(err))
+		return false
 	}
 
-	scaleDownWait, err := strconv.Atoi(scaleDownInterval)
+	err := p.Poll(ctx, "blue service to scale back up", func(ctx context.Context) (bool, error) {
+		dc.Events.Print(ctx)
+
+		return dc.GreenScaleUpFinished(ctx, blueService)
+	})
 
 	if err != nil {
-		log.Println("Error converting scale down interval", scaleDownInterval, "to integer. Failing.")
-		return err
+		logging.From(ctx).Error("Blue service did not scale back up. Leaving green running", logging.Err(err))
+		return false
 	}
 
-	scalePercentAsFloat := float64(scalePercentString)
+	logging.From(ctx).Info(fmt.Sprintf("Blue service '%s' scaled back up. Scaling down green service '%s'", blueService, greenService))
+	deployResult.SetLive(blueService, "")
 
-	// Convert int to decimal
-	percent := float64(scalePercentAsFloat) / float64(100)
+	return scaleDownGreen(ctx, dc, p, greenService, serviceUsesAppAutoscaling) == nil
+}
 
-	log.Printf("Scaling down by %d percent\n", int(percent*100))
+// removeGreen rolls back a deploy by scaling green down. Blue keeps serving traffic
+func removeGreen(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, service string, serviceUsesAppAutoscaling bool) {
+	ctx = rollbackStarted(ctx)
+	rollbackFinished(ctx, scaleDownGreen(ctx, dc, p, service, serviceUsesAppAutoscaling) == nil)
+}
 
-	var scaleDownBy int
-	var newDesiredCount int32
-	var lastScaleDownEvent bool
+// scaleDownGreen removes a green service that failed to scale up. Blue keeps serving traffic
+func scaleDownGreen(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, service string, serviceUsesAppAutoscaling bool) error {
+	// Green must be scaled down even if the deploy deadline has passed
+	ctx, cancel := p.CleanupContext(ctx)
+	defer cancel()
 
-	scaleDownNumber := float64(initialDesiredCount) * percent
+	return dc.ScaleDown(ctx, 0, 0, 0, service, serviceUsesAppAutoscaling)
+}
 
-	if scaleDownNumber < 0 {
-		scaleDownBy = 1
-	} else if scaleDownNumber > 0 && scaleDownNumber < 1 {
-		// Handle a bug where, if running count is less than 10
-		// the desired count would be set to 0
-		log.Println("Number of containers to remove is a decimal between 0 and 1. Removing one container")
-		scaleDownBy = 1
-	} else {
-		// int() will give us a round number
-		scaleDownBy = int(scaleDownNumber)
-	}
+// scaleDownInPercentages scales blue down by scalePercent of its initial count every scaleDownInterval seconds until it reaches 0
+func scaleDownInPercentages(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, service string, serviceUsesAppAutoscaling bool, scalePercent int, scaleDownInterval int, desiredCount int) error {
+	// Convert int to decimal
+	percent := float64(scalePercent) / float64(100)
 
-	calculatedDesiredCount := desiredCount - scaleDownBy
+	logging.From(ctx).Info(fmt.Sprintf("Scaling down by %d percent", int(percent*100)))
 
-	if calculatedDesiredCount <= 0 {
-		newDesiredCount = 0
-		lastScaleDownEvent = true
-	} else {
-		newDesiredCount = int32(calculatedDesiredCount)
-		lastScaleDownEvent = false
+	if scaleDownNumber := float64(initialDesiredCount) * percent; scaleDownNumber > 0 && scaleDownNumber < 1 {
+		logging.From(ctx).Info("Number of containers to remove is a decimal between 0 and 1. Removing one container")
 	}
 
-	err = dc.ScaleDown(newDesiredCount, 0, newDesiredCount, service, serviceUsesAppAutoscaling)
+	newDesiredCount, lastScaleDownEvent := nextScaleDownCount(initialDesiredCount, desiredCount, percent)
+
+	err := dc.ScaleDown(ctx, newDesiredCount, 0, newDesiredCount, service, serviceUsesAppAutoscaling)
 
 	if err != nil {
-		log.Println("Error scaling down service", err.Error())
+		logging.From(ctx).Error("Error scaling down service", logging.Err(err))
 		return err
 	}
 
-	status, err := dc.GreenScaleUpFinished(context.Background(), service)
+	err = p.Poll(ctx, deploy.PhaseBlueScaleDown, func(ctx context.Context) (bool, error) {
+		dc.Events.Print(ctx)
+
+		if err := dc.Alarms.Check(ctx); err != nil {
+			return false, err
+		}
+
+		status, err := dc.GreenScaleUpFinished(ctx, service)
+
+		if err != nil {
+			logging.From(ctx).Error("Error checking scale down status", logging.Err(err))
+		}
+
+		return status, err
+	})
 
 	if err != nil {
-		log.Println("Error checking scale down status", err.Error())
 		return err
 	}
 
-	for !status {
-		status, err = dc.GreenScaleUpFinished(context.Background(), service)
+	logging.From(ctx).Info(fmt.Sprintf("Finished scaling blue service down to %v", newDesiredCount))
 
-		if err != nil {
-			log.Println("Error checking scale down status", err.Error())
+	if lastScaleDownEvent {
+		logging.From(ctx).Info("Scale down complete")
+		return nil
+	} else {
+		logging.From(ctx).Info(fmt.Sprintf("Waiting %v seconds before scaling down again", scaleDownInterval))
+
+		if err := dc.Alarms.Watch(ctx, p.Interval, time.Duration(scaleDownInterval)*time.Second); err != nil {
 			return err
 		}
-		log.Println("Waiting 15 seconds for blue service to finish scaling down")
-		time.Sleep(15 * time.Second)
+
+		return scaleDownInPercentages(ctx, dc, p, service, serviceUsesAppAutoscaling, scalePercent, scaleDownInterval, int(newDesiredCount))
 	}
+}
 
-	log.Println("Finished scaling blue service down to", newDesiredCount)
+// nextScaleDownCount returns the desired count after removing percent of initialCount from desiredCount, and whether that is the last scale down
+func nextScaleDownCount(initialCount int, desiredCount int, percent float64) (int32, bool) {
+	var scaleDownBy int
 
-	if lastScaleDownEvent {
-		log.Println("Scale down complete")
-		return nil
+	scaleDownNumber := float64(initialCount) * percent
+
+	if scaleDownNumber < 1 {
+		// Handle a bug where, if running count is less than 10
+		// the desired count would be set to 0
+		scaleDownBy = 1
 	} else {
-		log.Println("Waiting", scaleDownWait, "seconds before scaling down again")
-		time.Sleep(time.Duration(scaleDownWait) * time.Second)
+		// int() will give us a round number
+		scaleDownBy = int(scaleDownNumber)
+	}
 
-		scaleDownInPercentages(dc, service, serviceUsesAppAutoscaling, scalePercent, scaleDownInterval, int(newDesiredCount))
+	calculatedDesiredCount := desiredCount - scaleDownBy
+
+	if calculatedDesiredCount <= 0 {
+		return 0, true
 	}
 
-	return nil
+	return int32(calculatedDesiredCount), false
+}
+
+// scaleDownSchedule returns every desired count that scaleDownInPercentages sets, in order
+func scaleDownSchedule(initialCount int, percent float64) []int32 {
+	var schedule []int32
+
+	desiredCount := initialCount
+
+	for {
+		newDesiredCount, last := nextScaleDownCount(initialCount, desiredCount, percent)
+		schedule = append(schedule, newDesiredCount)
+
+		if last {
+			return schedule
+		}
+
+		desiredCount = int(newDesiredCount)
+	}
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	pluginTypes "github.com/assemblyai/drone-deploy-ecs/pkg/types"
+)
+
+// blueGreenCluster is the same as rolling except that it deploys to the inactive color, and makes it live if promote is set
+// It must run while both colors are locked, so the inactive color can not change before it is deployed
+func blueGreenCluster(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, manager pluginTypes.SecretmanagerClient) error {
+	//get the inactive env. Either service name (blue/green) can be used since it does a partial match.
+	inactiveEnv, err := getGlobalInactiveEnvironment(ctx, manager, settings.Branch, settings.SecretService)
+
+	if err != nil {
+		logging.From(ctx).Error(err.Error())
+		return errors.New("deploy failed")
+	}
+
+	liveEnv := "blue"
+
+	if inactiveEnv == "blue" {
+		liveEnv = "green"
+	}
+
+	deployResult.SetBlueGreen(settings.BlueService, settings.GreenService)
+	deployResult.SetLive("", liveEnv)
+
+	//pick the image/service to deploy to based of configured live env
+	dc.Image = settings.BlueImage
+	service := settings.BlueService
+
+	if inactiveEnv == "green" {
+		dc.Image = settings.GreenImage
+		service = settings.GreenService
+	}
+
+	count, err := deploy.GetServiceDesiredCount(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("could not get desired count of service %s", service), logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	if count != 0 {
+		logging.From(ctx).Error(fmt.Sprintf("inactive environment for service %s has tasks running, this likely means we are attempting to deploy to the wrong env", service))
+		return errors.New("deploy failed")
+	}
+
+	if dc, err = pinImages(ctx, dc); err != nil {
+		return err
+	}
+
+	// Set promote to true in order to make the inactive color live once it has been deployed
+	promote := settings.Promote
+
+	if dryRun {
+		if err := planRolling(ctx, dc.ECS, dc.Cluster, dc.TaskDefinitionChanges(), service); err != nil {
+			return err
+		}
+
+		if promote {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: the live environment would be promoted to '%s'", inactiveEnv))
+		}
+
+		return nil
+	}
+
+	if err := rolling(ctx, dc, p, service); err != nil {
+		return err
+	}
+
+	if !promote {
+		return nil
+	}
+
+	if err := promoteLiveEnvironment(ctx, manager, settings.Branch, settings.SecretService, inactiveEnv); err != nil {
+		logging.From(ctx).Error(err.Error())
+		return errors.New("deploy failed")
+	}
+
+	deployResult.SetLive("", inactiveEnv)
+
+	return nil
+}
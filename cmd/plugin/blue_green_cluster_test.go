@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"gotest.tools/assert"
+)
+
+func Test_blueGreenCluster(t *testing.T) {
+	settings = Config{Branch: "dev1", SecretService: "rnnt-global", BlueService: "webapp-blue", GreenService: "test-service"}
+	defer func() { settings = Config{} }()
+
+	var updated []string
+
+	dc := deploy.DeployConfig{
+		ECS:     deploy.MockECSClient{TestingT: t, UpdatedServices: &updated},
+		Cluster: "test-cluster",
+	}
+
+	// blue is live in dev1, and the mock green service has tasks running
+	err := blueGreenCluster(context.TODO(), dc, testPoller(3), &secretManagerMock{})
+
+	assert.ErrorContains(t, err, "deploy failed")
+	assert.Equal(t, 0, len(updated))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The exit codes of the plugin and the command line
+const (
+	exitOK = 0
+	// exitFailed is used when a deploy, rollback or scale fails
+	exitFailed = 1
+	// exitUsage is used for unknown commands or flags and invalid settings
+	exitUsage = 2
+	// exitNotSteady is used when status finds a service that is still deploying or is not running its desired count
+	exitNotSteady = 3
+)
+
+// errUsage is returned once a command line error and the usage have been printed
+var errUsage = errors.New("invalid command line")
+
+// command is a subcommand of the command line
+type command struct {
+	name        string
+	summary     string
+	description string
+	// mode is the mode the command always runs in. Commands without one read mode from the settings
+	mode string
+	// defaultMode is used when mode is not set
+	defaultMode string
+	// dryRun is set for commands that never change anything
+	dryRun bool
+}
+
+var commands = []command{
+	{
+		name:        "deploy",
+		summary:     "Deploy an image or task definition revision to services",
+		description: "Deploys to services in --mode, which defaults to rolling.",
+		defaultMode: modeRolling,
+	},
+	{
+		name:        "plan",
+		summary:     "Print what a deploy would change without changing anything",
+		description: "Plans a deploy in --mode without changing anything. If --mode is not set, the mode is picked the same way as mode plan.",
+		defaultMode: modePlan,
+		dryRun:      true,
+	},
+	{
+		name:        "status",
+		summary:     "Print the state of services and their deployments",
+		description: fmt.Sprintf("Prints the state of --service, or of --blue-service and --green-service, and their deployments. Exits with %d if a service is still deploying or is not running its desired count.", exitNotSteady),
+		mode:        modeStatus,
+	},
+	{
+		name:        "rollback",
+		summary:     "Roll services back to a previous task definition revision",
+		description: "Rolls --service back to --rollback-revision, or to the previous revision of its task definition. If --blue-service and --green-service are set, the previous color is made live again.",
+		mode:        modeRollback,
+	},
+	{
+		name:        "scale",
+		summary:     "Set the desired count of a service",
+		description: "Sets the desired count of --service to --count and waits for the tasks to run. If the service uses Application Auto Scaling, its min and max are widened to include --count.",
+		mode:        modeScale,
+	},
+}
+
+// flagName returns the command line flag of a setting, such as aws-region for aws_region
+func flagName(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+// settingFlag is a command line flag that sets a setting
+type settingFlag struct {
+	values  map[string]string
+	name    string
+	boolean bool
+}
+
+func (f *settingFlag) String() string {
+	if f == nil || f.values == nil {
+		return ""
+	}
+
+	return f.values[f.name]
+}
+
+func (f *settingFlag) Set(v string) error {
+	f.values[f.name] = v
+	return nil
+}
+
+// IsBoolFlag lets settings that turn a feature on be set without a value, such as --dry-run
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.boolean
+}
+
+// printUsage prints the commands of the command line and its exit codes
+func printUsage(w io.Writer, program string) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\n", program)
+	fmt.Fprintf(w, "Deploys services to Amazon ECS. Run without a command in order to read the PLUGIN_* settings set by Drone.\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s%s\n", c.name, c.summary)
+	}
+
+	fmt.Fprintf(w, "  %-10s%s\n\n", "help", "Print the help of the command line or of a command")
+	fmt.Fprintf(w, "Flags are the plugin settings with - instead of _, such as --aws-region. Flags take precedence over the PLUGIN_* env vars, which take precedence over --config-file.\n\n")
+	fmt.Fprintf(w, "Exit codes:\n")
+	fmt.Fprintf(w, "  %d  success\n", exitOK)
+	fmt.Fprintf(w, "  %d  the deploy, rollback or scale failed\n", exitFailed)
+	fmt.Fprintf(w, "  %d  unknown command or flag, or invalid settings\n", exitUsage)
+	fmt.Fprintf(w, "  %d  status found a service that is not steady\n\n", exitNotSteady)
+	fmt.Fprintf(w, "Run '%s <command> -h' for the flags of a command.\n", program)
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return command{}, false
+}
+
+// flagSet returns the flags of a command, which set values
+func (c command) flagSet(program string, values map[string]string, w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(program+" "+c.name, flag.ContinueOnError)
+	fs.SetOutput(w)
+
+	fs.Var(&settingFlag{values: values, name: "config_file"}, flagName("config_file"), "the path to a YAML or JSON file of settings")
+
+	for _, s := range knownSettings {
+		// The mode of commands such as status is fixed
+		if s.name == "mode" && c.mode != "" {
+			continue
+		}
+
+		fs.Var(&settingFlag{values: values, name: s.name, boolean: s.flag}, flagName(s.name), s.usage)
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintf(w, "Usage: %s %s [flags]\n\n%s\n\nFlags:\n", program, c.name, c.description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// commandLineSettings reads the command and flags in args and returns the lookup of the settings to run with
+// Settings not set by a flag are read from the PLUGIN_* env vars and then config_file, so other CI systems can set either
+// flag.ErrHelp is returned once help has been printed, and errUsage once a command line error has been printed
+func commandLineSettings(program string, args []string, w io.Writer) (func(name string) string, error) {
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if c, ok := findCommand(args[1]); ok {
+				c.flagSet(program, map[string]string{}, w).Usage()
+				return nil, flag.ErrHelp
+			}
+		}
+
+		printUsage(w, program)
+		return nil, flag.ErrHelp
+	}
+
+	c, ok := findCommand(args[0])
+
+	if !ok {
+		fmt.Fprintf(w, "Unknown command '%s'\n\n", args[0])
+		printUsage(w, program)
+		return nil, errUsage
+	}
+
+	values := make(map[string]string)
+	fs := c.flagSet(program, values, w)
+
+	// The flag package prints the error and the usage
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+
+		return nil, errUsage
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(w, "Unexpected argument '%s'\n\n", fs.Arg(0))
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	get, err := settingsLookup(func(name string) string {
+		if v, ok := values[name]; ok {
+			return v
+		}
+
+		return envSetting(name)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(name string) string {
+		switch {
+		case name == "mode" && c.mode != "":
+			return c.mode
+		case name == "mode" && get(name) == "":
+			return c.defaultMode
+		case name == "dry_run" && c.dryRun:
+			return "true"
+		}
+
+		return get(name)
+	}, nil
+}
+
+// exitCode returns the exit code of a deploy that finished with err
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errNotSteady):
+		return exitNotSteady
+	default:
+		return exitFailed
+	}
+}
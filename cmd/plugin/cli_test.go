@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_commandLineSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want map[string]string
+	}{
+		{
+			name: "deploy defaults to rolling",
+			args: []string{"deploy", "--cluster", "prod-ecs-cluster", "--service=webapp", "--dry-run"},
+			want: map[string]string{"mode": modeRolling, "cluster": "prod-ecs-cluster", "service": "webapp", "dry_run": "true"},
+		},
+		{
+			name: "deploy mode",
+			args: []string{"deploy", "--mode", "blue-green", "--scale-down-percent", "50"},
+			want: map[string]string{"mode": modeBlueGreen, "scale_down_percent": "50", "dry_run": ""},
+		},
+		{
+			name: "plan",
+			args: []string{"plan"},
+			want: map[string]string{"mode": modePlan, "dry_run": "true"},
+		},
+		{
+			name: "plan mode",
+			args: []string{"plan", "--mode", "codedeploy", "--dry-run=false"},
+			want: map[string]string{"mode": modeCodeDeploy, "dry_run": "true"},
+		},
+		{
+			name: "status",
+			args: []string{"status", "--service", "webapp"},
+			env:  map[string]string{"PLUGIN_MODE": "rolling", "PLUGIN_CLUSTER": "prod-ecs-cluster"},
+			want: map[string]string{"mode": modeStatus, "cluster": "prod-ecs-cluster", "service": "webapp"},
+		},
+		{
+			name: "flags take precedence over env",
+			args: []string{"scale", "--cluster", "staging-ecs-cluster", "--count", "3", "--disable-rollbacks=false"},
+			env:  map[string]string{"PLUGIN_CLUSTER": "prod-ecs-cluster", "PLUGIN_DISABLE_ROLLBACKS": "true"},
+			want: map[string]string{"mode": modeScale, "cluster": "staging-ecs-cluster", "count": "3", "disable_rollbacks": "false"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			var out bytes.Buffer
+
+			get, err := commandLineSettings("deploy", tt.args, &out)
+
+			assert.NilError(t, err)
+			assert.Equal(t, "", out.String())
+
+			for name, want := range tt.want {
+				assert.Equal(t, want, get(name), name)
+			}
+		})
+	}
+}
+
+func Test_commandLineSettingsConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	assert.NilError(t, ioutil.WriteFile(path, []byte("mode: blue-green\nservice: webapp\ncluster: staging-ecs-cluster\n"), 0644))
+
+	get, err := commandLineSettings("deploy", []string{"plan", "--config-file", path, "--cluster", "prod-ecs-cluster"}, ioutil.Discard)
+
+	assert.NilError(t, err)
+	// plan plans the mode in config_file, and flags take precedence over it
+	assert.Equal(t, modeBlueGreen, get("mode"))
+	assert.Equal(t, "true", get("dry_run"))
+	assert.Equal(t, "prod-ecs-cluster", get("cluster"))
+	assert.Equal(t, "webapp", get("service"))
+}
+
+func Test_commandLineSettingsErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    error
+		wantOutput string
+	}{
+		{
+			name:       "help",
+			args:       []string{"help"},
+			wantErr:    flag.ErrHelp,
+			wantOutput: "Usage: deploy <command> [flags]",
+		},
+		{
+			name:       "help command",
+			args:       []string{"help", "scale"},
+			wantErr:    flag.ErrHelp,
+			wantOutput: "Usage: deploy scale [flags]",
+		},
+		{
+			name:       "command help",
+			args:       []string{"status", "-h"},
+			wantErr:    flag.ErrHelp,
+			wantOutput: "Usage: deploy status [flags]",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"deplyo"},
+			wantErr:    errUsage,
+			wantOutput: "Unknown command 'deplyo'",
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"deploy", "--servce", "webapp"},
+			wantErr:    errUsage,
+			wantOutput: "flag provided but not defined: -servce",
+		},
+		{
+			name:       "mode of status",
+			args:       []string{"status", "--mode", "rolling"},
+			wantErr:    errUsage,
+			wantOutput: "flag provided but not defined: -mode",
+		},
+		{
+			name:       "argument",
+			args:       []string{"rollback", "--service", "webapp", "3"},
+			wantErr:    errUsage,
+			wantOutput: "Unexpected argument '3'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			_, err := commandLineSettings("deploy", tt.args, &out)
+
+			assert.Assert(t, errors.Is(err, tt.wantErr))
+			assert.Assert(t, strings.Contains(out.String(), tt.wantOutput), out.String())
+		})
+	}
+}
+
+func Test_exitCode(t *testing.T) {
+	assert.Equal(t, exitOK, exitCode(nil))
+	assert.Equal(t, exitFailed, exitCode(errors.New("deploy failed")))
+	assert.Equal(t, exitNotSteady, exitCode(errNotSteady))
+}
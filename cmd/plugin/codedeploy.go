@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cdtypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+)
+
+// codeDeploy deploys a service that uses the CODE_DEPLOY deployment controller
+// CodeDeploy shifts traffic between the original and replacement task sets, so the plugin only registers the revision and waits
+func codeDeploy(ctx context.Context, dc deploy.DeployConfig, cd types.CodeDeployClient, service string, application string, deploymentGroup string, hooks map[string]string, p deploy.Poller) error {
+	ctx = logging.With(ctx, logging.Service(service))
+	logging.From(ctx).Info("Beginning CodeDeploy deployment")
+
+	td, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	currTD, err := deploy.RetrieveTaskDefinition(ctx, dc.ECS, td)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	newTD, err := deploy.CreateNewTaskDefinitionRevision(ctx, dc.ECS, currTD, dc.TaskDefinitionChanges())
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the creating a new task definition revision", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Created new task definition revision %v", newTD.Revision))
+	deployStarted(currTD, *newTD)
+
+	showTaskDefinitionDiff(currTD, *newTD)
+
+	if err := runPreDeployTask(ctx, dc.ECS, dc.Cluster, service, *newTD.TaskDefinitionArn, p); err != nil {
+		return errors.New("deploy failed")
+	}
+
+	loadBalancers, err := deploy.GetServiceLoadBalancers(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the service load balancers", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	appSpec, err := deploy.GenerateAppSpec(*newTD.TaskDefinitionArn, loadBalancers, hooks)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error generating the AppSpec", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Generated AppSpec %v", appSpec))
+
+	deploymentID, err := deploy.CreateCodeDeployDeployment(ctx, cd, application, deploymentGroup, appSpec)
+
+	if err != nil {
+		return errors.New("deploy failed")
+	}
+
+	ctx = logging.With(ctx, logging.DeploymentID(deploymentID))
+	logging.From(ctx).Info(fmt.Sprintf("Started CodeDeploy deployment with ID %v", deploymentID))
+	deployResult.AddDeployment(service, deploymentID)
+
+	seenEvents := make(map[string]cdtypes.LifecycleEventStatus)
+
+	err = p.Poll(ctx, "CodeDeploy deployment to complete", func(ctx context.Context) (bool, error) {
+		showLifecycleEvents(ctx, cd, deploymentID, seenEvents)
+
+		return deploy.CheckCodeDeployDeploymentStatus(ctx, cd, deploymentID)
+	})
+
+	if pollTimedOut(err) {
+		logging.From(ctx).Info("Stopping CodeDeploy deployment")
+
+		cleanupCtx, cancel := p.CleanupContext(ctx)
+		defer cancel()
+
+		// CodeDeploy rolls traffic back to the original task set when the deployment is stopped with rollbacks enabled
+		if !disableRollbacks {
+			cleanupCtx = rollbackStarted(cleanupCtx)
+		}
+
+		err := deploy.StopCodeDeployDeployment(cleanupCtx, cd, deploymentID, !disableRollbacks)
+
+		if err != nil {
+			logging.From(ctx).Error("Error stopping CodeDeploy deployment")
+		}
+
+		if !disableRollbacks {
+			rollbackFinished(cleanupCtx, err == nil)
+		}
+
+		return errors.New("deploy failed")
+	}
+
+	if err != nil {
+		logging.From(ctx).Error("CodeDeploy deployment failed", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("CodeDeploy deployment succeeded for service '%s'", service))
+
+	return nil
+}
+
+// showLifecycleEvents logs every lifecycle hook event whose status changed since the last check
+func showLifecycleEvents(ctx context.Context, cd types.CodeDeployClient, deploymentID string, seen map[string]cdtypes.LifecycleEventStatus) {
+	events, err := deploy.GetCodeDeployLifecycleEvents(ctx, cd, deploymentID)
+
+	if err != nil {
+		return
+	}
+
+	for _, event := range events {
+		name := aws.ToString(event.LifecycleEventName)
+
+		if seen[name] == event.Status {
+			continue
+		}
+
+		seen[name] = event.Status
+		logging.From(ctx).Info(fmt.Sprintf("Lifecycle event '%s' is %s", name, event.Status))
+
+		if event.Status == cdtypes.LifecycleEventStatusFailed && event.Diagnostics != nil {
+			logging.From(ctx).Error(fmt.Sprintf("Lifecycle event '%s' failed with %s: %s", name, event.Diagnostics.ErrorCode, aws.ToString(event.Diagnostics.Message)))
+
+			if event.Diagnostics.LogTail != nil {
+				logging.From(ctx).Info(*event.Diagnostics.LogTail)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdtypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"gotest.tools/assert"
+)
+
+func Test_codeDeploy(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          cdtypes.DeploymentStatus
+		maxDeployChecks int
+		wantStopped     bool
+		wantErr         bool
+	}{
+		{
+			name:            "test-success",
+			status:          cdtypes.DeploymentStatusSucceeded,
+			maxDeployChecks: 3,
+			wantStopped:     false,
+			wantErr:         false,
+		},
+		{
+			name:            "test-timeout",
+			status:          cdtypes.DeploymentStatusInProgress,
+			maxDeployChecks: 0,
+			wantStopped:     true,
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := []*codedeploy.CreateDeploymentInput{}
+			stopped := []*codedeploy.StopDeploymentInput{}
+
+			dc := deploy.DeployConfig{
+				ECS:       deploy.MockECSClient{TestingT: t, DeploymentState: "COMPLETED"},
+				Cluster:   "test-cluster",
+				Container: "app",
+				Image:     "foo/app:3",
+			}
+
+			cd := deploy.MockCodeDeployClient{
+				TestingT:           t,
+				DeploymentStatus:   tt.status,
+				CreatedDeployments: &created,
+				StoppedDeployments: &stopped,
+			}
+
+			err := codeDeploy(context.Background(), dc, cd, "test-service", "webapp", "webapp-dg", nil, testPoller(tt.maxDeployChecks))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("codeDeploy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, 1, len(created))
+			assert.Equal(t, tt.wantStopped, len(stopped) == 1)
+
+			if tt.wantStopped {
+				assert.Equal(t, true, *stopped[0].AutoRollbackEnabled)
+			}
+		})
+	}
+}
+
+func Test_showLifecycleEvents(t *testing.T) {
+	seen := make(map[string]cdtypes.LifecycleEventStatus)
+
+	cd := deploy.MockCodeDeployClient{
+		TestingT: t,
+		LifecycleEvents: []cdtypes.LifecycleEvent{
+			{LifecycleEventName: aws.String("BeforeInstall"), Status: cdtypes.LifecycleEventStatusSucceeded},
+			{LifecycleEventName: aws.String("Install"), Status: cdtypes.LifecycleEventStatusInProgress},
+		},
+	}
+
+	showLifecycleEvents(context.Background(), cd, "d-TESTDEPLOY", seen)
+
+	assert.Equal(t, cdtypes.LifecycleEventStatusSucceeded, seen["BeforeInstall"])
+	assert.Equal(t, cdtypes.LifecycleEventStatusInProgress, seen["Install"])
+}
@@ -0,0 +1,675 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	modeRolling          = "rolling"
+	modeBlueGreen        = "blue-green"
+	modeBlueGreenCluster = "blue-green-cluster"
+	modeCodeDeploy       = "codedeploy"
+	modeTaskSet          = "taskset"
+	modeDiff             = "diff"
+	modeRollback         = "rollback"
+	modePlan             = "plan"
+	modeValidate         = "validate"
+	modeStatus           = "status"
+	modeScale            = "scale"
+)
+
+var (
+	// deployModes are the modes that plan and validate can stand in for
+	deployModes = []string{modeRolling, modeBlueGreen, modeBlueGreenCluster, modeCodeDeploy, modeTaskSet, modeDiff, modeRollback, modeStatus, modeScale}
+	modes       = []string{modeRolling, modeBlueGreen, modeBlueGreenCluster, modeCodeDeploy, modeTaskSet, modeDiff, modeRollback, modeStatus, modeScale, modePlan, modeValidate}
+)
+
+// setting is a setting that can be set in the step settings, in config_file or as a command line flag
+type setting struct {
+	name  string
+	usage string
+	// flag is set for settings that turn a feature on, which are boolean command line flags
+	flag bool
+}
+
+// knownSettings is every setting, in the order they are listed in the command line help
+var knownSettings = []setting{
+	{name: "mode", usage: "the kind of deploy: " + strings.Join(modes, ", ")},
+	{name: "validate_mode", usage: "the mode that modes validate and plan stand in for"},
+	{name: "aws_region", usage: "the AWS region of the cluster"},
+	{name: "aws_role_arn", usage: "the ARN of an IAM role to assume"},
+	{name: "cluster", usage: "the name of the ECS cluster"},
+	{name: "service", usage: "the name of the service, or a comma separated list of services"},
+	{name: "blue_service", usage: "the name of the blue service of a blue / green deploy"},
+	{name: "green_service", usage: "the name of the green service of a blue / green deploy"},
+	{name: "secret_service", usage: "the service suffix of the secret that holds the live color of a blue-green-cluster deploy"},
+	{name: "branch", usage: "the branch that selects the live color secret of a blue-green-cluster deploy. Defaults to DRONE_COMMIT_BRANCH"},
+	{name: "promote", usage: "make the inactive color live once a blue-green-cluster deploy succeeds", flag: true},
+	{name: "container", usage: "the name of the container to update"},
+	{name: "image", usage: "the image to deploy to container"},
+	{name: "blue_image", usage: "the image to deploy to the blue service of a blue-green-cluster deploy"},
+	{name: "green_image", usage: "the image to deploy to the green service of a blue-green-cluster deploy"},
+	{name: "containers", usage: "a JSON object of container names to images"},
+	{name: "environment", usage: "a JSON object of environment variables to set in container. null removes a variable"},
+	{name: "secrets", usage: "a JSON object of environment variables to Secrets Manager or SSM ARNs to set in container"},
+	{name: "poll_interval", usage: "how long to wait between checks, such as 10s"},
+	{name: "phase_timeout", usage: "how long each phase of the deploy may take, such as 10m"},
+	{name: "deploy_timeout", usage: "how long the whole deploy may take, such as 30m"},
+	{name: "max_deploy_checks", usage: "how many checks each phase may take"},
+	{name: "fail_fast_threshold", usage: "how many tasks may fail to start before the deployment fails"},
+	{name: "disable_rollbacks", usage: "do not roll back a failed rolling deployment", flag: true},
+	{name: "check_target_health", usage: "wait for new tasks to be healthy in every target group", flag: true},
+	{name: "alarms", usage: "a comma separated list of CloudWatch alarms that abort the deploy"},
+	{name: "bake_period", usage: "how long to watch alarms after the rollout, such as 5m"},
+	{name: "log_lines", usage: "how many log lines of each stopped task to print when a deployment fails"},
+	{name: "scale_down_percent", usage: "the percent of tasks scaled down at a time in a blue / green deploy"},
+	{name: "scale_down_interval", usage: "the seconds between scaling down in a blue / green deploy"},
+	{name: "scale_down_wait_period", usage: "the seconds to wait before scaling down in a blue / green deploy"},
+	{name: "checks_to_pass", usage: "the health checks the new color must pass in a blue / green deploy"},
+	{name: "count", usage: "the desired count of mode scale"},
+	{name: "codedeploy_application", usage: "the CodeDeploy application"},
+	{name: "codedeploy_deployment_group", usage: "the CodeDeploy deployment group"},
+	{name: "codedeploy_hooks", usage: "a JSON object of CodeDeploy lifecycle events to Lambda functions"},
+	{name: "taskset_initial_percent", usage: "the percent of traffic the first task set of a taskset deploy receives"},
+	{name: "dry_run", usage: "print what the deploy would change without changing anything", flag: true},
+	{name: "diff_format", usage: "the format of task definition diffs: unified or json"},
+	{name: "diff_revision", usage: "the task definition revision mode diff compares the service to"},
+	{name: "rollback_revision", usage: "the task definition revision to roll back to. Defaults to the previous revision"},
+	{name: "resolve_digests", usage: "pin images to their digest", flag: true},
+	{name: "registry_username", usage: "the username of the registry that images are resolved in"},
+	{name: "registry_password", usage: "the password of the registry that images are resolved in"},
+	{name: "pre_deploy_task", usage: "the container of a one-off task to run before the services are updated"},
+	{name: "pre_deploy_command", usage: "the command of the pre-deploy task, as a JSON array, a comma separated list or a string split on whitespace"},
+	{name: "lock_backend", usage: "the deployment lock backend: dynamodb or ecs-tags"},
+	{name: "lock_table", usage: "the DynamoDB table of the dynamodb lock backend"},
+	{name: "lock_ttl", usage: "the lease of a deployment lock, such as 10m"},
+	{name: "wait_for_lock", usage: "how long to wait for a lock held by another deploy, such as 15m"},
+	{name: "result_file", usage: "the path to write the result of the deploy to as JSON"},
+	{name: "result_dotenv", usage: "the path to write the result of the deploy to as a dotenv file"},
+	{name: "notify_webhook", usage: "a comma separated list of webhook URLs to notify"},
+	{name: "notify_slack_webhook", usage: "a comma separated list of Slack incoming webhook URLs to notify"},
+	{name: "metrics_pushgateway", usage: "the URL of a Prometheus Pushgateway to push metrics to"},
+	{name: "metrics_statsd", usage: "the host:port of a StatsD server to send metrics to"},
+	{name: "metrics_dogstatsd", usage: "the host:port of a DogStatsD server to send metrics to"},
+	{name: "metrics_cloudwatch_namespace", usage: "the CloudWatch namespace to put metrics in"},
+	{name: "otlp_endpoint", usage: "the URL of an OTLP HTTP collector to export the trace to"},
+	{name: "otlp_headers", usage: "a JSON object of headers to send to otlp_endpoint"},
+	{name: "log_format", usage: "the format of log lines: text or json"},
+	{name: "debug", usage: "log every AWS API call", flag: true},
+}
+
+// Config is every setting of a deploy
+type Config struct {
+	Mode string
+	// ValidateMode is the mode that modes validate and plan stand in for
+	ValidateMode string
+	AWSRegion    string
+	AWSRoleARN   string
+	Cluster      string
+
+	// Service is a service name, or a comma separated list of service names for rolling deployments
+	Service       string
+	BlueService   string
+	GreenService  string
+	SecretService string
+	Branch        string
+	Promote       bool
+
+	Container   string
+	Image       string
+	BlueImage   string
+	GreenImage  string
+	Containers  map[string]string
+	Environment map[string]*string
+	Secrets     map[string]*string
+
+	PollInterval  time.Duration
+	PhaseTimeout  time.Duration
+	DeployTimeout time.Duration
+	// MaxDeployChecks is negative if checks are only limited by PhaseTimeout and DeployTimeout
+	MaxDeployChecks   int
+	FailFastThreshold int
+	DisableRollbacks  bool
+	CheckTargetHealth bool
+	Alarms            []string
+	BakePeriod        time.Duration
+	LogLines          int
+
+	// The scale down settings of blue / green deployments are in percent and seconds
+	ScaleDownPercent    int
+	ScaleDownInterval   int
+	ScaleDownWaitPeriod int
+	ChecksToPass        int
+	// Count is the desired count of mode scale
+	Count int
+
+	CodeDeployApplication     string
+	CodeDeployDeploymentGroup string
+	CodeDeployHooks           map[string]string
+	TaskSetInitialPercent     float64
+
+	DryRun           bool
+	DiffFormat       string
+	DiffRevision     string
+	RollbackRevision string
+
+	ResolveDigests   bool
+	RegistryUsername string
+	RegistryPassword string
+	PreDeployTask    string
+	PreDeployCommand []string
+
+	LockBackend  string
+	LockTable    string
+	LockTTL      time.Duration
+	WaitForLock  time.Duration
+	ResultFile   string
+	ResultDotenv string
+
+	NotifyWebhooks             []string
+	NotifySlackWebhooks        []string
+	MetricsPushgateway         string
+	MetricsStatsD              string
+	MetricsDogStatsD           string
+	MetricsCloudWatchNamespace string
+	OTLPEndpoint               string
+	OTLPHeaders                map[string]string
+	LogFormat                  string
+	Debug                      bool
+}
+
+// ConfigError lists every problem with the settings of a deploy
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("invalid settings: %s", strings.Join(e, "; "))
+}
+
+// envSetting returns the PLUGIN_* env var Drone sets for a setting
+func envSetting(name string) string {
+	return os.Getenv("PLUGIN_" + strings.ToUpper(name))
+}
+
+// settingsLookup returns a function that looks up a setting with get, and then in config_file if it is set
+// get reads the PLUGIN_* env vars, or the command line flags, so a pipeline can override the file kept in the repo
+func settingsLookup(get func(name string) string) (func(name string) string, error) {
+	path := get("config_file")
+
+	if path == "" {
+		return get, nil
+	}
+
+	file, err := readConfigFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(name string) string {
+		if v := get(name); v != "" {
+			return v
+		}
+
+		return file[name]
+	}, nil
+}
+
+// readConfigFile reads a YAML or JSON file of setting names to values
+// Values are converted the way Drone passes step settings to plugins: lists are joined by commas and maps are encoded as JSON objects
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read config_file %v", err)
+	}
+
+	raw := make(map[string]interface{})
+
+	// YAML is a superset of JSON, so this reads both
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not decode config_file '%s' %v", path, err)
+	}
+
+	known := make(map[string]bool, len(knownSettings))
+
+	for _, s := range knownSettings {
+		known[s.name] = true
+	}
+
+	values := make(map[string]string, len(raw))
+	var problems ConfigError
+
+	for name, value := range raw {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("unknown setting '%s' in config_file '%s'", name, path))
+			continue
+		}
+
+		s, err := settingString(value)
+
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("could not read %s from config_file '%s' %v", name, path, err))
+			continue
+		}
+
+		values[name] = s
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, problems
+	}
+
+	return values, nil
+}
+
+// settingString converts a value decoded from config_file to the string Drone would pass for it
+func settingString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+
+		for _, item := range v {
+			s, err := settingString(item)
+
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, s)
+		}
+
+		return strings.Join(items, ","), nil
+	case map[interface{}]interface{}:
+		b, err := json.Marshal(jsonValue(v))
+
+		if err != nil {
+			return "", err
+		}
+
+		return string(b), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// jsonValue converts the maps decoded from YAML, which may have keys of any type, to maps that can be encoded as JSON
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonValue(item)
+		}
+
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+
+		for i, item := range v {
+			items[i] = jsonValue(item)
+		}
+
+		return items
+	default:
+		return v
+	}
+}
+
+// configParser reads typed settings and keeps every problem, so they can all be reported at once
+type configParser struct {
+	get      func(name string) string
+	problems ConfigError
+}
+
+func (p *configParser) problem(format string, a ...interface{}) {
+	p.problems = append(p.problems, fmt.Sprintf(format, a...))
+}
+
+func (p *configParser) string(name string) string {
+	return strings.TrimSpace(p.get(name))
+}
+
+// flag is true if a setting is set to anything but false
+func (p *configParser) flag(name string) bool {
+	v := p.string(name)
+
+	return v != "" && v != "false"
+}
+
+func (p *configParser) intBetween(name string, def int, min int, max int) int {
+	v := p.string(name)
+
+	if v == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(v)
+
+	if err != nil || i < min || i > max {
+		p.problem("%s must be a whole number between %d and %d, not '%s'", name, min, max, v)
+		return def
+	}
+
+	return i
+}
+
+func (p *configParser) intAtLeast(name string, def int, min int) int {
+	v := p.string(name)
+
+	if v == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(v)
+
+	if err != nil || i < min {
+		p.problem("%s must be a whole number of at least %d, not '%s'", name, min, v)
+		return def
+	}
+
+	return i
+}
+
+func (p *configParser) duration(name string, def time.Duration) time.Duration {
+	d, err := parseDuration(p.string(name), def)
+
+	if err != nil {
+		p.problem("%s %v", name, err)
+		return def
+	}
+
+	return d
+}
+
+func (p *configParser) oneOf(name string, def string, values ...string) string {
+	v := p.string(name)
+
+	if v == "" {
+		return def
+	}
+
+	if !isOneOf(v, values) {
+		p.problem("%s must be one of '%s', not '%s'", name, strings.Join(values, "', '"), v)
+		return def
+	}
+
+	return v
+}
+
+// require reports every setting in names that is not set. mode is empty for settings every mode needs
+func (p *configParser) require(mode string, names ...string) {
+	for _, name := range names {
+		if p.string(name) != "" {
+			continue
+		}
+
+		if mode == "" {
+			p.problem("%s must be set", name)
+		} else {
+			p.problem("%s must be set in mode '%s'", name, mode)
+		}
+	}
+}
+
+func isOneOf(s string, values []string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadConfig reads every setting with get and checks the settings needed by the mode
+// The returned error is a ConfigError that lists every problem found
+func loadConfig(get func(name string) string) (Config, error) {
+	p := &configParser{get: get}
+
+	c := Config{
+		Mode:                       p.string("mode"),
+		ValidateMode:               p.string("validate_mode"),
+		AWSRegion:                  p.string("aws_region"),
+		AWSRoleARN:                 p.string("aws_role_arn"),
+		Cluster:                    p.string("cluster"),
+		Service:                    p.string("service"),
+		BlueService:                p.string("blue_service"),
+		GreenService:               p.string("green_service"),
+		SecretService:              p.string("secret_service"),
+		Branch:                     p.string("branch"),
+		Promote:                    p.flag("promote"),
+		Container:                  p.string("container"),
+		Image:                      p.string("image"),
+		BlueImage:                  p.string("blue_image"),
+		GreenImage:                 p.string("green_image"),
+		PollInterval:               p.duration("poll_interval", defaultPollInterval),
+		PhaseTimeout:               p.duration("phase_timeout", 0),
+		DeployTimeout:              p.duration("deploy_timeout", 0),
+		FailFastThreshold:          p.intAtLeast("fail_fast_threshold", defaultFailFastThreshold, 0),
+		DisableRollbacks:           p.flag("disable_rollbacks"),
+		CheckTargetHealth:          p.flag("check_target_health"),
+		Alarms:                     parseList(p.string("alarms")),
+		BakePeriod:                 p.duration("bake_period", 0),
+		LogLines:                   p.intBetween("log_lines", defaultLogLines, 0, 10000),
+		ScaleDownPercent:           p.intBetween("scale_down_percent", 0, 1, 100),
+		ScaleDownInterval:          p.intAtLeast("scale_down_interval", 0, 0),
+		ScaleDownWaitPeriod:        p.intAtLeast("scale_down_wait_period", 0, 0),
+		ChecksToPass:               p.intAtLeast("checks_to_pass", 0, 1),
+		Count:                      p.intAtLeast("count", 0, 0),
+		CodeDeployApplication:      p.string("codedeploy_application"),
+		CodeDeployDeploymentGroup:  p.string("codedeploy_deployment_group"),
+		TaskSetInitialPercent:      defaultTaskSetInitialPercent,
+		DryRun:                     p.flag("dry_run"),
+		DiffFormat:                 p.oneOf("diff_format", diffFormatUnified, diffFormatUnified, diffFormatJSON),
+		DiffRevision:               p.string("diff_revision"),
+		RollbackRevision:           p.string("rollback_revision"),
+		ResolveDigests:             p.flag("resolve_digests"),
+		RegistryUsername:           p.string("registry_username"),
+		RegistryPassword:           p.string("registry_password"),
+		PreDeployTask:              p.string("pre_deploy_task"),
+		LockBackend:                p.oneOf("lock_backend", "", lockBackendDynamoDB, lockBackendECSTags),
+		LockTable:                  p.string("lock_table"),
+		LockTTL:                    p.duration("lock_ttl", defaultLockTTL),
+		WaitForLock:                p.duration("wait_for_lock", 0),
+		ResultFile:                 p.string("result_file"),
+		ResultDotenv:               p.string("result_dotenv"),
+		NotifyWebhooks:             parseList(p.string("notify_webhook")),
+		NotifySlackWebhooks:        parseList(p.string("notify_slack_webhook")),
+		MetricsPushgateway:         p.string("metrics_pushgateway"),
+		MetricsStatsD:              p.string("metrics_statsd"),
+		MetricsDogStatsD:           p.string("metrics_dogstatsd"),
+		MetricsCloudWatchNamespace: p.string("metrics_cloudwatch_namespace"),
+		OTLPEndpoint:               p.string("otlp_endpoint"),
+		LogFormat:                  p.oneOf("log_format", logFormatText, logFormatText, logFormatJSON),
+		Debug:                      p.flag("debug"),
+	}
+
+	// Drone sets the branch of the build, which selects the global secret of blue-green-cluster deployments
+	if c.Branch == "" {
+		c.Branch = os.Getenv("DRONE_COMMIT_BRANCH")
+	}
+
+	if c.PollInterval == 0 {
+		p.problem("poll_interval must be longer than 0s")
+	}
+
+	// max_deploy_checks defaults to no limit when a timeout limits how long the deploy waits
+	if c.PhaseTimeout > 0 || c.DeployTimeout > 0 {
+		c.MaxDeployChecks = p.intAtLeast("max_deploy_checks", -1, 0)
+	} else {
+		c.MaxDeployChecks = p.intAtLeast("max_deploy_checks", defaultMaxChecksUntilFailed, 0)
+	}
+
+	if v := p.string("taskset_initial_percent"); v != "" {
+		percent, err := strconv.ParseFloat(v, 64)
+
+		if err != nil || percent < 0 || percent > 100 {
+			p.problem("taskset_initial_percent must be a number between 0 and 100, not '%s'", v)
+		} else {
+			c.TaskSetInitialPercent = percent
+		}
+	}
+
+	var err error
+
+	if c.Containers, err = parseContainers(p.get("containers")); err != nil {
+		p.problem("containers %v", err)
+	}
+
+	if c.Environment, err = parseEnvironmentMap(p.get("environment")); err != nil {
+		p.problem("environment %v", err)
+	}
+
+	if c.Secrets, err = parseEnvironmentMap(p.get("secrets")); err != nil {
+		p.problem("secrets %v", err)
+	}
+
+	if c.CodeDeployHooks, err = parseStringMap(p.get("codedeploy_hooks")); err != nil {
+		p.problem("could not decode codedeploy_hooks %v", err)
+	}
+
+	if c.OTLPHeaders, err = parseStringMap(p.get("otlp_headers")); err != nil {
+		p.problem("could not decode otlp_headers %v", err)
+	}
+
+	if c.PreDeployTask != "" {
+		if c.PreDeployCommand, err = parseCommand(p.get("pre_deploy_command")); err != nil {
+			p.problem("could not decode pre_deploy_command %v", err)
+		}
+	}
+
+	if c.LockBackend != "" {
+		if c.LockTTL < time.Minute {
+			p.problem("lock_ttl must be at least 1m")
+		}
+
+		if c.LockBackend == lockBackendDynamoDB && c.LockTable == "" {
+			p.problem("lock_table must be set when lock_backend is '%s'", lockBackendDynamoDB)
+		}
+	}
+
+	p.validateMode(c)
+
+	if len(p.problems) > 0 {
+		return c, p.problems
+	}
+
+	return c, nil
+}
+
+// checkedMode returns the mode whose settings are checked. Modes plan and validate check the settings of the mode they stand in for
+func (c Config) checkedMode() string {
+	switch c.Mode {
+	case modePlan, modeValidate:
+		return planMode(c)
+	default:
+		return c.Mode
+	}
+}
+
+// validateMode checks the settings every mode needs and the settings of the mode being deployed
+func (p *configParser) validateMode(c Config) {
+	p.require("", "aws_region", "cluster", "mode")
+
+	if c.Mode == "" {
+		return
+	}
+
+	if !isOneOf(c.Mode, modes) {
+		p.problem("mode must be one of '%s', not '%s'", strings.Join(modes, "', '"), c.Mode)
+		return
+	}
+
+	mode := c.checkedMode()
+
+	if !isOneOf(mode, deployModes) {
+		p.problem("validate_mode must be one of '%s', not '%s'", strings.Join(deployModes, "', '"), mode)
+		return
+	}
+
+	// A task set service can not be told apart from a rolling one by its settings, so it would be planned as the wrong kind of deploy
+	if isOneOf(c.Mode, []string{modePlan, modeValidate}) && c.ValidateMode == "" && p.string("taskset_initial_percent") != "" {
+		p.problem("validate_mode must be set to '%s' in mode '%s' when taskset_initial_percent is set", modeTaskSet, c.Mode)
+	}
+
+	// A single container or a map of containers must be set, except in the modes that do not deploy an image
+	if !isOneOf(mode, []string{modeDiff, modeRollback, modeStatus, modeScale}) && c.Container == "" && len(c.Containers) == 0 {
+		p.problem("container or containers must be set in mode '%s'", mode)
+	}
+
+	// Environment variables and secrets are applied to the single container
+	if (len(c.Environment) > 0 || len(c.Secrets) > 0) && c.Container == "" {
+		p.problem("container must be set when using environment or secrets")
+	}
+
+	switch mode {
+	case modeRolling, modeTaskSet:
+		p.require(mode, "service")
+
+		// The image is only needed when updating a single container
+		if c.Container != "" {
+			p.require(mode, "image")
+		}
+	case modeBlueGreen:
+		p.require(mode, "blue_service", "green_service", "scale_down_percent", "scale_down_interval", "scale_down_wait_period", "checks_to_pass")
+	case modeBlueGreenCluster:
+		// secret_service is the service tag in terraform for the secret with the color
+		p.require(mode, "blue_service", "green_service", "secret_service")
+
+		if c.Branch == "" {
+			p.problem("branch must be set in mode '%s' when DRONE_COMMIT_BRANCH is not", mode)
+		}
+
+		if c.Container != "" {
+			p.require(mode, "blue_image", "green_image")
+		}
+	case modeCodeDeploy:
+		p.require(mode, "service", "codedeploy_application", "codedeploy_deployment_group")
+
+		if c.Container != "" {
+			p.require(mode, "image")
+		}
+	case modeDiff:
+		p.require(mode, "service", "diff_revision")
+	case modeRollback, modeStatus:
+		if c.Service == "" && (c.BlueService == "" || c.GreenService == "") {
+			p.problem("service, or both blue_service and green_service, must be set in mode '%s'", mode)
+		}
+	case modeScale:
+		p.require(mode, "service", "count")
+
+		if strings.Contains(c.Service, ",") {
+			p.problem("service must be a single service in mode '%s'", mode)
+		}
+	}
+}
+
+// parseDuration reads a Go duration such as "10s" or "30m". def is returned if s is empty
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(s)
+
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("must be a Go duration such as '10s' or '30m', not '%s'", s)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// lookup returns a settings lookup that reads from values
+func lookup(values map[string]string) func(name string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func Test_loadConfig(t *testing.T) {
+	os.Unsetenv("DRONE_COMMIT_BRANCH")
+
+	rolling := map[string]string{
+		"mode":       "rolling",
+		"aws_region": "us-east-2",
+		"cluster":    "prod-ecs-cluster",
+		"service":    "webapp",
+		"container":  "nginx",
+		"image":      "myorg/nginx:1",
+	}
+
+	with := func(values map[string]string, changes map[string]string) map[string]string {
+		merged := make(map[string]string)
+
+		for k, v := range values {
+			merged[k] = v
+		}
+
+		for k, v := range changes {
+			merged[k] = v
+		}
+
+		return merged
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]string
+		problems ConfigError
+	}{
+		{
+			name:   "rolling",
+			values: rolling,
+		},
+		{
+			name:     "missing",
+			values:   map[string]string{},
+			problems: ConfigError{"aws_region must be set", "cluster must be set", "mode must be set"},
+		},
+		{
+			name:     "unknown mode",
+			values:   with(rolling, map[string]string{"mode": "Rolling"}),
+			problems: ConfigError{"mode must be one of 'rolling', 'blue-green', 'blue-green-cluster', 'codedeploy', 'taskset', 'diff', 'rollback', 'status', 'scale', 'plan', 'validate', not 'Rolling'"},
+		},
+		{
+			name:   "rolling without an image",
+			values: with(rolling, map[string]string{"image": "", "max_deploy_checks": "ten", "poll_interval": "10"}),
+			problems: ConfigError{
+				"poll_interval must be a Go duration such as '10s' or '30m', not '10'",
+				"max_deploy_checks must be a whole number of at least 0, not 'ten'",
+				"image must be set in mode 'rolling'",
+			},
+		},
+		{
+			name:   "blue green",
+			values: with(rolling, map[string]string{"mode": "blue-green", "blue_service": "webapp-blue", "scale_down_percent": "150", "scale_down_interval": "30s", "checks_to_pass": "3"}),
+			problems: ConfigError{
+				"scale_down_percent must be a whole number between 1 and 100, not '150'",
+				"scale_down_interval must be a whole number of at least 0, not '30s'",
+				"green_service must be set in mode 'blue-green'",
+				"scale_down_wait_period must be set in mode 'blue-green'",
+			},
+		},
+		{
+			name:   "blue green cluster",
+			values: with(rolling, map[string]string{"mode": "blue-green-cluster", "blue_service": "webapp-blue", "green_service": "webapp-green"}),
+			problems: ConfigError{
+				"secret_service must be set in mode 'blue-green-cluster'",
+				"branch must be set in mode 'blue-green-cluster' when DRONE_COMMIT_BRANCH is not",
+				"blue_image must be set in mode 'blue-green-cluster'",
+				"green_image must be set in mode 'blue-green-cluster'",
+			},
+		},
+		{
+			name:     "rollback",
+			values:   map[string]string{"mode": "rollback", "aws_region": "us-east-2", "cluster": "prod-ecs-cluster", "blue_service": "webapp-blue"},
+			problems: ConfigError{"service, or both blue_service and green_service, must be set in mode 'rollback'"},
+		},
+		{
+			name:   "scale",
+			values: map[string]string{"mode": "scale", "aws_region": "us-east-2", "cluster": "prod-ecs-cluster", "service": "webapp,worker", "count": "-1"},
+			problems: ConfigError{
+				"count must be a whole number of at least 0, not '-1'",
+				"service must be a single service in mode 'scale'",
+			},
+		},
+		{
+			name:     "environment without a container",
+			values:   with(rolling, map[string]string{"container": "", "containers": `{"nginx": "myorg/nginx:1"}`, "environment": `{"LOG_LEVEL": "debug"}`}),
+			problems: ConfigError{"container must be set when using environment or secrets"},
+		},
+		{
+			name:   "invalid values",
+			values: with(rolling, map[string]string{"containers": `["nginx"]`, "diff_format": "yaml", "lock_backend": "dynamodb", "lock_ttl": "30s", "taskset_initial_percent": "110"}),
+			problems: ConfigError{
+				"diff_format must be one of 'unified', 'json', not 'yaml'",
+				"taskset_initial_percent must be a number between 0 and 100, not '110'",
+				"containers could not decode containers setting json: cannot unmarshal array into Go value of type map[string]string",
+				"lock_ttl must be at least 1m",
+				"lock_table must be set when lock_backend is 'dynamodb'",
+			},
+		},
+		{
+			name:   "validate",
+			values: with(rolling, map[string]string{"mode": "validate", "validate_mode": "codedeploy"}),
+			problems: ConfigError{
+				"codedeploy_application must be set in mode 'codedeploy'",
+				"codedeploy_deployment_group must be set in mode 'codedeploy'",
+			},
+		},
+		{
+			name:     "plan",
+			values:   with(rolling, map[string]string{"mode": "plan", "service": ""}),
+			problems: ConfigError{"service must be set in mode 'rolling'"},
+		},
+		{
+			name:     "plan-codedeploy",
+			values:   with(rolling, map[string]string{"mode": "plan", "codedeploy_application": "webapp"}),
+			problems: ConfigError{"codedeploy_deployment_group must be set in mode 'codedeploy'"},
+		},
+		{
+			name:   "plan-blue-green-cluster",
+			values: with(rolling, map[string]string{"mode": "plan", "blue_service": "webapp-blue", "green_service": "webapp-green", "secret_service": "webapp", "branch": "main", "blue_image": "myorg/nginx:1", "green_image": "myorg/nginx:1"}),
+		},
+		{
+			name:     "plan-taskset",
+			values:   with(rolling, map[string]string{"mode": "plan", "taskset_initial_percent": "20"}),
+			problems: ConfigError{"validate_mode must be set to 'taskset' in mode 'plan' when taskset_initial_percent is set"},
+		},
+		{
+			name:   "plan-validate-mode",
+			values: with(rolling, map[string]string{"mode": "plan", "validate_mode": "taskset", "taskset_initial_percent": "20"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(lookup(tt.values))
+
+			if tt.problems == nil {
+				assert.NilError(t, err)
+				return
+			}
+
+			assert.DeepEqual(t, tt.problems, err)
+		})
+	}
+}
+
+func Test_loadConfigValues(t *testing.T) {
+	c, err := loadConfig(lookup(map[string]string{
+		"mode":                "blue-green",
+		"aws_region":          "us-east-2",
+		"cluster":             "prod-ecs-cluster",
+		"blue_service":        "webapp-blue",
+		"green_service":       "webapp-green",
+		"container":           "nginx",
+		"environment":         `{"LOG_LEVEL": "debug", "OLD": null}`,
+		"scale_down_percent":  "50",
+		"scale_down_interval": "0",
+		// Whitespace around numbers is ignored
+		"scale_down_wait_period": " 300 ",
+		"checks_to_pass":         "3",
+		"deploy_timeout":         "30m",
+		"disable_rollbacks":      "false",
+		"dry_run":                "true",
+		"alarms":                 "webapp-5xx, webapp-latency",
+		// Settings that turn a feature on take any value but false
+		"promote": "True",
+	}))
+
+	assert.NilError(t, err)
+	assert.Equal(t, 50, c.ScaleDownPercent)
+	assert.Equal(t, 0, c.ScaleDownInterval)
+	assert.Equal(t, 300, c.ScaleDownWaitPeriod)
+	assert.Equal(t, 3, c.ChecksToPass)
+	assert.Equal(t, 30*time.Minute, c.DeployTimeout)
+	assert.Equal(t, defaultPollInterval, c.PollInterval)
+	// A timeout is set, so checks are not limited by default
+	assert.Equal(t, -1, c.MaxDeployChecks)
+	assert.Equal(t, false, c.DisableRollbacks)
+	assert.Equal(t, true, c.DryRun)
+	assert.Equal(t, true, c.Promote)
+	assert.DeepEqual(t, []string{"webapp-5xx", "webapp-latency"}, c.Alarms)
+	assert.Equal(t, "debug", *c.Environment["LOG_LEVEL"])
+	assert.Assert(t, c.Environment["OLD"] == nil)
+	assert.Equal(t, diffFormatUnified, c.DiffFormat)
+	assert.Equal(t, logFormatText, c.LogFormat)
+}
+
+func Test_readConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "deploy.yml")
+	assert.NilError(t, ioutil.WriteFile(yamlPath, []byte(`
+mode: rolling
+aws_region: us-east-2
+cluster: prod-ecs-cluster
+service: webapp
+container: app
+image: myorg/app:1
+containers:
+  nginx: myorg/nginx:1
+environment:
+  LOG_LEVEL: debug
+  WORKERS: 4
+  OLD: null
+alarms:
+  - webapp-5xx
+  - webapp-latency
+max_deploy_checks: 30
+check_target_health: true
+`), 0644))
+
+	values, err := readConfigFile(yamlPath)
+
+	assert.NilError(t, err)
+	assert.Equal(t, "30", values["max_deploy_checks"])
+	assert.Equal(t, "true", values["check_target_health"])
+	assert.Equal(t, "webapp-5xx,webapp-latency", values["alarms"])
+	assert.Equal(t, `{"nginx":"myorg/nginx:1"}`, values["containers"])
+	assert.Equal(t, `{"LOG_LEVEL":"debug","OLD":null,"WORKERS":4}`, values["environment"])
+
+	c, err := loadConfig(lookup(values))
+
+	assert.NilError(t, err)
+	assert.Equal(t, 30, c.MaxDeployChecks)
+	assert.Equal(t, true, c.CheckTargetHealth)
+	assert.Equal(t, "4", *c.Environment["WORKERS"])
+
+	jsonPath := filepath.Join(dir, "deploy.json")
+	assert.NilError(t, ioutil.WriteFile(jsonPath, []byte(`{"mode": "rolling", "servce": "webapp", "containers": {"nginx": "myorg/nginx:1"}}`), 0644))
+
+	_, err = readConfigFile(jsonPath)
+
+	assert.DeepEqual(t, ConfigError{"unknown setting 'servce' in config_file '" + jsonPath + "'"}, err)
+
+	_, err = readConfigFile(filepath.Join(dir, "missing.yml"))
+
+	assert.ErrorContains(t, err, "could not read config_file")
+}
+
+func Test_settingsLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	assert.NilError(t, ioutil.WriteFile(path, []byte("service: webapp\ncluster: staging-ecs-cluster\n"), 0644))
+
+	os.Setenv("PLUGIN_CONFIG_FILE", path)
+	os.Setenv("PLUGIN_CLUSTER", "prod-ecs-cluster")
+	defer os.Unsetenv("PLUGIN_CONFIG_FILE")
+	defer os.Unsetenv("PLUGIN_CLUSTER")
+
+	get, err := settingsLookup(envSetting)
+
+	assert.NilError(t, err)
+	// Step settings take precedence over config_file
+	assert.Equal(t, "prod-ecs-cluster", get("cluster"))
+	assert.Equal(t, "webapp", get("service"))
+	assert.Equal(t, "", get("image"))
+}
+
+func Test_parseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "unset", value: "", want: 10 * time.Second},
+		{name: "minutes", value: "30m", want: 30 * time.Minute},
+		{name: "seconds", value: "15s", want: 15 * time.Second},
+		{name: "no-unit", value: "30", wantErr: true},
+		{name: "negative", value: "-1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDuration(tt.value, 10*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
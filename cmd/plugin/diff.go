@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	diffFormatUnified = "unified"
+	diffFormatJSON    = "json"
+)
+
+// showTaskDefinitionDiff logs every field that differs between two task definitions in diffFormat
+func showTaskDefinitionDiff(from ecstypes.TaskDefinition, to ecstypes.TaskDefinition) {
+	d, err := deploy.DiffTaskDefinitions(from, to)
+
+	if err != nil {
+		logger.Error("Unable to diff task definitions", logging.Err(err))
+		return
+	}
+
+	if d.IsEmpty() {
+		logger.Info(fmt.Sprintf("Task definitions '%s' and '%s' are the same", d.From, d.To))
+		return
+	}
+
+	if diffFormat == diffFormatJSON {
+		out, err := d.JSON()
+
+		if err != nil {
+			logger.Error("Unable to encode task definition diff", logging.Err(err))
+			return
+		}
+
+		logger.Info(out)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Task definition diff:\n%s", d.Unified()))
+}
+
+// diffRevisions logs the difference between the task definition a service is running and another revision
+// It does not change anything and is used to debug drift
+func diffRevisions(ctx context.Context, e types.ECSClient, cluster string, service string, revision string) error {
+	running, err := deploy.GetServiceRunningTaskDefinition(ctx, e, service, cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+		return errors.New("diff failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Service '%s' is running task definition '%s'", service, running))
+
+	from, err := deploy.RetrieveTaskDefinition(ctx, e, running)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
+		return errors.New("diff failed")
+	}
+
+	to, err := deploy.RetrieveTaskDefinition(ctx, e, revision)
+
+	if err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Failing because of an error retrieving task definition '%s'", revision), logging.Err(err))
+		return errors.New("diff failed")
+	}
+
+	showTaskDefinitionDiff(from, to)
+
+	return nil
+}
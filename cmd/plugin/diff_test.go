@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"gotest.tools/assert"
+)
+
+func Test_diffRevisions(t *testing.T) {
+	e := deploy.MockECSClient{TestingT: t}
+
+	err := diffRevisions(context.Background(), e, "test-cluster", "test-service", "amazon-ecs-sample:2")
+
+	assert.NilError(t, err)
+
+	err = diffRevisions(context.Background(), deploy.MockECSClient{TestingT: t, WantError: true}, "test-cluster", "test-service", "amazon-ecs-sample:2")
+
+	assert.ErrorContains(t, err, "diff failed")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+)
+
+const (
+	// originalImageTagPrefix is the task definition tag key prefix used to record the image tag a digest was resolved from
+	originalImageTagPrefix = "drone-deploy-ecs:image:"
+)
+
+type imageResolver interface {
+	Resolve(ctx context.Context, image string) (string, error)
+}
+
+// pinImageDigests replaces every image in dc with its immutable digest form
+// The original image reference of each container is recorded in a task definition tag
+func pinImageDigests(ctx context.Context, r imageResolver, dc deploy.DeployConfig) (deploy.DeployConfig, error) {
+	tags := make(map[string]string)
+
+	for k, v := range dc.TaskDefinitionTags {
+		tags[k] = v
+	}
+
+	resolve := func(container string, image string) (string, error) {
+		pinned, err := r.Resolve(ctx, image)
+
+		if err != nil {
+			logging.From(ctx).Error(fmt.Sprintf("Error resolving digest of image '%s' for container '%s'", image, container), logging.Err(err))
+			return "", err
+		}
+
+		if pinned != image {
+			logging.From(ctx).Info(fmt.Sprintf("Resolved image '%s' for container '%s' to '%s'", image, container, pinned))
+			tags[originalImageTagPrefix+container] = image
+		}
+
+		return pinned, nil
+	}
+
+	containers := make(map[string]string)
+
+	for container, image := range dc.Containers {
+		pinned, err := resolve(container, image)
+
+		if err != nil {
+			return dc, err
+		}
+
+		containers[container] = pinned
+	}
+
+	if dc.Container != "" && dc.Image != "" {
+		pinned, err := resolve(dc.Container, dc.Image)
+
+		if err != nil {
+			return dc, err
+		}
+
+		dc.Image = pinned
+	}
+
+	dc.Containers = containers
+	dc.TaskDefinitionTags = tags
+
+	return dc, nil
+}
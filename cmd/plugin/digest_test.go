@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+)
+
+type mockResolver struct {
+	digests map[string]string
+}
+
+func (m mockResolver) Resolve(ctx context.Context, image string) (string, error) {
+	pinned, ok := m.digests[image]
+
+	if !ok {
+		return "", errors.New("manifest unknown")
+	}
+
+	return pinned, nil
+}
+
+func Test_pinImageDigests(t *testing.T) {
+	r := mockResolver{
+		digests: map[string]string{
+			"myorg/app:abc123":             "myorg/app@sha256:1111",
+			"myorg/migrations:abc123":      "myorg/migrations@sha256:2222",
+			"myorg/log-router@sha256:3333": "myorg/log-router@sha256:3333",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		dc      deploy.DeployConfig
+		want    deploy.DeployConfig
+		wantErr bool
+	}{
+		{
+			name: "pin-all-images",
+			dc: deploy.DeployConfig{
+				Container: "app",
+				Image:     "myorg/app:abc123",
+				Containers: map[string]string{
+					"migrations": "myorg/migrations:abc123",
+					"log-router": "myorg/log-router@sha256:3333",
+				},
+				TaskDefinitionTags: map[string]string{"team": "platform"},
+			},
+			want: deploy.DeployConfig{
+				Container: "app",
+				Image:     "myorg/app@sha256:1111",
+				Containers: map[string]string{
+					"migrations": "myorg/migrations@sha256:2222",
+					"log-router": "myorg/log-router@sha256:3333",
+				},
+				TaskDefinitionTags: map[string]string{
+					"team":                              "platform",
+					"drone-deploy-ecs:image:app":        "myorg/app:abc123",
+					"drone-deploy-ecs:image:migrations": "myorg/migrations:abc123",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown-image",
+			dc: deploy.DeployConfig{
+				Container: "app",
+				Image:     "myorg/app:unknown",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pinImageDigests(context.Background(), r, tt.dc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("pinImageDigests() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pinImageDigests() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,158 +5,148 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/registry"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
 	pluginTypes "github.com/assemblyai/drone-deploy-ecs/pkg/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
-	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
-	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"log"
-	"os"
 	"strings"
 )
 
-// checkEnvVars checks the vars needed for each mode
-func checkEnvVars() error {
-	requiredVars := []string{
-		"PLUGIN_AWS_REGION",
-		"PLUGIN_CLUSTER",
-		"PLUGIN_CONTAINER",
-		"PLUGIN_MODE",
-	}
-
-	for _, v := range requiredVars {
-		if os.Getenv(v) == "" {
-			log.Printf("Required environment variable '%s' is missing\n", v)
-			return errors.New("env var not set")
-		}
-	}
+const (
+	// liveEnvironmentKey is the key in the global secret that holds the current live color
+	liveEnvironmentKey = "CURRENT_LIVE_ENVIRONMENT"
+	// liveEnvironmentVersionStage is the version stage given to the secret when the live color is promoted
+	liveEnvironmentVersionStage = "AWSCURRENT"
+)
 
-	return nil
-}
+// loadAWSConfig returns the AWS configuration every client is built from, with tracing and logging of every API call
+// If roleARN is set, the role is assumed and its credentials are cached for every client built from the configuration
+func loadAWSConfig(region string, roleARN string) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion(region),
+	)
 
-func checkRollingVars() error {
-	requiredVars := []string{
-		"PLUGIN_SERVICE",
-		"PLUGIN_IMAGE",
+	if err != nil {
+		return cfg, err
 	}
 
-	for _, v := range requiredVars {
-		if os.Getenv(v) == "" {
-			log.Printf("Required environment variable '%s' is missing\n", v)
-			return errors.New("env var not set")
-		}
+	tracing.AppendMiddlewares(&cfg.APIOptions)
+	logging.AppendMiddlewares(&cfg.APIOptions)
+
+	if roleARN != "" {
+		stsClient := sts.NewFromConfig(cfg)
+		provider := stscreds.NewAssumeRoleProvider(stsClient, roleARN)
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+		cfg.Credentials.Retrieve(context.Background())
 	}
 
-	return nil
+	return cfg, nil
 }
 
-func checkBlueGreenVars() error {
-	requiredVars := []string{
-		"PLUGIN_BLUE_SERVICE",
-		"PLUGIN_GREEN_SERVICE",
-		"PLUGIN_SCALE_DOWN_PERCENT",
-		"PLUGIN_SCALE_DOWN_INTERVAL",
-		"PLUGIN_SCALE_DOWN_WAIT_PERIOD",
-		"PLUGIN_CHECKS_TO_PASS",
-	}
+// parseList splits a comma separated list, such as alarm names. Drone passes lists to plugins this way
+func parseList(s string) []string {
+	var names []string
 
-	for _, v := range requiredVars {
-		if os.Getenv(v) == "" {
-			log.Printf("Required environment variable '%s' is missing\n", v)
-			return errors.New("env var not set")
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
 		}
 	}
 
-	return nil
+	return names
 }
 
-// checkBlueGreenClusterVars validates that the settings in the drone file are correct
-func checkBlueGreenClusterVars() error {
-	requiredVars := []string{
-		"PLUGIN_BLUE_SERVICE",
-		"PLUGIN_GREEN_SERVICE",
-		"PLUGIN_BLUE_IMAGE",
-		"PLUGIN_GREEN_IMAGE",
-		"PLUGIN_SECRET_SERVICE", // this is the service tag in terraform for the secret with the color
-	}
-
-	hasError := false
+// parseStringMap decodes a map setting. Drone passes maps to plugins as JSON objects
+func parseStringMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
 
-	for _, v := range requiredVars {
-		if os.Getenv(v) == "" {
-			log.Printf("Required environment variable '%s' is missing\n", v)
-			hasError = true
-		}
+	if s == "" {
+		return m, nil
 	}
 
-	if hasError {
-		return errors.New("env var not set")
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return m, nil
 }
 
-func newECSClient(region string, role_arn string) *ecs.Client {
-	cfg, err := config.LoadDefaultConfig(
-		context.TODO(),
-		config.WithRegion(region),
-	)
+// parseContainers decodes the containers setting, a JSON object of container names to images
+func parseContainers(s string) (map[string]string, error) {
+	containers, err := parseStringMap(s)
 
 	if err != nil {
-		log.Fatalf("Failed to load SDK configuration, %v", err)
+		return nil, fmt.Errorf("could not decode containers setting %v", err)
 	}
 
-	if role_arn != "" {
-		stsClient := sts.NewFromConfig(cfg)
-		provider := stscreds.NewAssumeRoleProvider(stsClient, role_arn)
-		cfg.Credentials = aws.NewCredentialsCache(provider)
-		cfg.Credentials.Retrieve(context.Background())
+	for container, image := range containers {
+		if image == "" {
+			return nil, fmt.Errorf("no image set for container '%s'", container)
+		}
 	}
 
-	return ecs.NewFromConfig(cfg)
+	return containers, nil
 }
 
-func newSecretsManagerClient(region string, role_arn string) *secretsmanager.Client {
-	cfg, err := config.LoadDefaultConfig(
-		context.TODO(),
-		config.WithRegion(region),
-	)
+// parseEnvironmentMap decodes the environment and secrets settings, a JSON object of names to values
+// A null value means the entry should be removed. Numbers and booleans are converted to strings
+func parseEnvironmentMap(s string) (map[string]*string, error) {
+	values := make(map[string]*string)
 
-	if err != nil {
-		log.Fatalf("Failed to load SDK configuration, %v", err)
+	if s == "" {
+		return values, nil
 	}
 
-	if role_arn != "" {
-		stsClient := sts.NewFromConfig(cfg)
-		provider := stscreds.NewAssumeRoleProvider(stsClient, role_arn)
-		cfg.Credentials = aws.NewCredentialsCache(provider)
-		cfg.Credentials.Retrieve(context.Background())
+	raw := make(map[string]json.RawMessage)
+
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("could not decode setting %v", err)
 	}
 
-	return secretsmanager.NewFromConfig(cfg)
-}
+	for name, value := range raw {
+		if string(value) == "null" {
+			values[name] = nil
+			continue
+		}
 
-func newAppAutoscalingClient(region string, role_arn string) *applicationautoscaling.Client {
-	cfg, err := config.LoadDefaultConfig(
-		context.TODO(),
-		config.WithRegion(region),
-	)
+		var str string
 
-	if err != nil {
-		log.Fatalf("Failed to load SDK configuration, %v", err)
+		if err := json.Unmarshal(value, &str); err != nil {
+			// Not a string, use the literal JSON value
+			str = string(value)
+		}
+
+		values[name] = &str
 	}
 
-	if role_arn != "" {
-		stsClient := sts.NewFromConfig(cfg)
-		provider := stscreds.NewAssumeRoleProvider(stsClient, role_arn)
-		cfg.Credentials = aws.NewCredentialsCache(provider)
-		cfg.Credentials.Retrieve(context.Background())
+	return values, nil
+}
+
+// newImageResolver returns a resolver that authenticates against ECR with the plugin's AWS credentials
+// and against any other registry with the registry_username and registry_password settings
+func newImageResolver(cfg aws.Config, username string, password string) *registry.Resolver {
+	var fallback registry.Authenticator = registry.Anonymous{}
+
+	if username != "" || password != "" {
+		fallback = registry.StaticCredentials{Username: username, Password: password}
 	}
 
-	return applicationautoscaling.NewFromConfig(cfg)
+	return registry.NewResolver(&registry.ECRCredentials{
+		NewClient: func(region string) pluginTypes.ECRClient {
+			return ecr.NewFromConfig(cfg, func(o *ecr.Options) {
+				o.Region = region
+			})
+		},
+		Fallback: fallback,
+	})
 }
 
 func getServiceNames(s string) []string {
@@ -164,21 +154,26 @@ func getServiceNames(s string) []string {
 	return strings.Split(s, ",")
 }
 
-// getGlobalInactiveEnvironment finds the appropriate global secret store that holds the current live color
-func getGlobalInactiveEnvironment(manager pluginTypes.SecretmanagerClient, branch string, serviceSuffix string) (string, error) {
+// liveEnvironmentSecretName returns the name of the global secret that holds the current live color
+func liveEnvironmentSecretName(branch string, serviceSuffix string) string {
 	environment := branch
 
 	if branch == "main" {
 		environment = "production"
 	}
 
-	secretName := fmt.Sprintf("%s-%s", environment, serviceSuffix)
+	return fmt.Sprintf("%s-%s", environment, serviceSuffix)
+}
+
+// getGlobalInactiveEnvironment finds the appropriate global secret store that holds the current live color
+func getGlobalInactiveEnvironment(ctx context.Context, manager pluginTypes.SecretmanagerClient, branch string, serviceSuffix string) (string, error) {
+	secretName := liveEnvironmentSecretName(branch, serviceSuffix)
 
 	getParams := &secretsmanager.GetSecretValueInput{
 		SecretId: &secretName,
 	}
 
-	getOut, err := manager.GetSecretValue(context.Background(), getParams)
+	getOut, err := manager.GetSecretValue(ctx, getParams)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve secret value (name: %s) for live environment %v", secretName, err)
@@ -199,9 +194,62 @@ func getGlobalInactiveEnvironment(manager pluginTypes.SecretmanagerClient, branc
 
 	inactiveEnv := "blue"
 
-	if jsonMap["CURRENT_LIVE_ENVIRONMENT"] == "blue" {
+	if jsonMap[liveEnvironmentKey] == "blue" {
 		inactiveEnv = "green"
 	}
 
 	return inactiveEnv, nil
 }
+
+// promoteLiveEnvironment sets the live color in the global secret to color
+// Every other key in the secret is kept. The new version is given the AWSCURRENT stage so it is read by the next deploy
+func promoteLiveEnvironment(ctx context.Context, manager pluginTypes.SecretmanagerClient, branch string, serviceSuffix string, color string) error {
+	secretName := liveEnvironmentSecretName(branch, serviceSuffix)
+
+	getOut, err := manager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretName),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to retrieve secret value (name: %s) for live environment %v", secretName, err)
+	}
+
+	if getOut.SecretString == nil {
+		return errors.New("no secret found")
+	}
+
+	// Other keys may hold any JSON value, so they are decoded without a fixed type
+	jsonMap := make(map[string]interface{})
+
+	if err := json.Unmarshal([]byte(*getOut.SecretString), &jsonMap); err != nil {
+		return fmt.Errorf("could not decode json secret %v", err)
+	}
+
+	previous := jsonMap[liveEnvironmentKey]
+	jsonMap[liveEnvironmentKey] = color
+
+	secretString, err := json.Marshal(jsonMap)
+
+	if err != nil {
+		return fmt.Errorf("could not encode json secret %v", err)
+	}
+
+	_, err = manager.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(secretName),
+		SecretString:  aws.String(string(secretString)),
+		VersionStages: []string{liveEnvironmentVersionStage},
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to update secret value (name: %s) for live environment %v", secretName, err)
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Promoted live environment in secret '%s' from '%v' to '%s'", secretName, previous, color))
+
+	return nil
+}
+
+// pollTimedOut returns true if polling stopped because a phase ran out of checks or time, the deploy deadline passed or the deploy was cancelled
+func pollTimedOut(err error) bool {
+	return errors.Is(err, deploy.ErrPhaseTimeout) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
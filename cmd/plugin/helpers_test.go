@@ -43,6 +43,113 @@ func Test_getServiceNames(t *testing.T) {
 	}
 }
 
+func Test_parseList(t *testing.T) {
+	got := parseList(" webapp-5xx, webapp-latency-*,,")
+	want := []string{"webapp-5xx", "webapp-latency-*"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseList() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseContainers(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			s:       "",
+			want:    map[string]string{},
+			wantErr: false,
+		},
+		{
+			name: "multiple-containers",
+			s:    `{"app": "myorg/app:abc123", "migrations": "myorg/migrations:abc123"}`,
+			want: map[string]string{
+				"app":        "myorg/app:abc123",
+				"migrations": "myorg/migrations:abc123",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "missing-image",
+			s:       `{"app": ""}`,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-json",
+			s:       "app=myorg/app:abc123",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseContainers(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseContainers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseContainers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseEnvironmentMap(t *testing.T) {
+	sha := "abc123"
+	release := "42"
+	debug := "true"
+
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]*string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			s:       "",
+			want:    map[string]*string{},
+			wantErr: false,
+		},
+		{
+			name: "mixed-values",
+			s:    `{"GIT_SHA": "abc123", "RELEASE": 42, "DEBUG": true, "LOG_LEVEL": null}`,
+			want: map[string]*string{
+				"GIT_SHA":   &sha,
+				"RELEASE":   &release,
+				"DEBUG":     &debug,
+				"LOG_LEVEL": nil,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid-json",
+			s:       "GIT_SHA=abc123",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEnvironmentMap(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseEnvironmentMap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEnvironmentMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_getGlobalInactiveEnvironment(t *testing.T) {
 	tests := []struct {
 		branch  string
@@ -57,7 +164,7 @@ func Test_getGlobalInactiveEnvironment(t *testing.T) {
 	manager := &secretManagerMock{}
 
 	for _, test := range tests {
-		env, err := getGlobalInactiveEnvironment(manager, test.branch, test.service)
+		env, err := getGlobalInactiveEnvironment(context.Background(), manager, test.branch, test.service)
 
 		if test.err != nil {
 			if err.Error() != test.err.Error() {
@@ -78,6 +185,63 @@ func Test_getGlobalInactiveEnvironment(t *testing.T) {
 
 }
 
+func Test_promoteLiveEnvironment(t *testing.T) {
+	tests := []struct {
+		branch           string
+		service          string
+		color            string
+		wantSecret       string
+		wantSecretString string
+		err              error
+	}{
+		{branch: "dev1", service: "rnnt-global", color: "green", wantSecret: "dev1-rnnt-global", wantSecretString: `{"CURRENT_LIVE_ENVIRONMENT":"green","OTHER_KEY":"kept"}`},
+		{branch: "main", service: "rnnt-global", color: "blue", wantSecret: "production-rnnt-global", wantSecretString: `{"CURRENT_LIVE_ENVIRONMENT":"blue"}`},
+		{branch: "arst", service: "rnnt-global", color: "blue", err: errors.New("no secret found")},
+	}
+
+	for _, test := range tests {
+		manager := &promoteSecretMock{}
+
+		err := promoteLiveEnvironment(context.Background(), manager, test.branch, test.service, test.color)
+
+		if test.err != nil {
+			if err == nil || err.Error() != test.err.Error() {
+				t.Errorf("err should match expected. Expected %v, got %v", test.err, err)
+			}
+
+			if len(manager.puts) != 0 {
+				t.Errorf("secret should not be updated, got %d updates", len(manager.puts))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("err should be nil, got %v", err)
+			continue
+		}
+
+		if len(manager.puts) != 1 {
+			t.Errorf("secret should be updated once, got %d updates", len(manager.puts))
+			continue
+		}
+
+		put := manager.puts[0]
+
+		if *put.SecretId != test.wantSecret {
+			t.Errorf("secret should match expected. Expected %v, got %v", test.wantSecret, *put.SecretId)
+		}
+
+		if *put.SecretString != test.wantSecretString {
+			t.Errorf("secret string should match expected. Expected %v, got %v", test.wantSecretString, *put.SecretString)
+		}
+
+		if !reflect.DeepEqual(put.VersionStages, []string{"AWSCURRENT"}) {
+			t.Errorf("version stages should be AWSCURRENT, got %v", put.VersionStages)
+		}
+	}
+}
+
 type listSecretsMock struct {
 }
 
@@ -132,7 +296,35 @@ func (l *getSecretMock) GetSecretValue(ctx context.Context, params *secretsmanag
 	}, nil
 }
 
+type putSecretMock struct {
+	puts []*secretsmanager.PutSecretValueInput
+}
+
+func (l *putSecretMock) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	l.puts = append(l.puts, params)
+
+	return &secretsmanager.PutSecretValueOutput{Name: params.SecretId}, nil
+}
+
 type secretManagerMock struct {
 	getSecretMock
 	listSecretsMock
+	putSecretMock
+}
+
+// promoteSecretMock is a secretManagerMock whose dev1 secret has a key besides the live environment
+type promoteSecretMock struct {
+	secretManagerMock
+}
+
+func (l *promoteSecretMock) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if *params.SecretId == "dev1-rnnt-global" {
+		secretString := "{\"CURRENT_LIVE_ENVIRONMENT\": \"blue\", \"OTHER_KEY\": \"kept\"}"
+
+		return &secretsmanager.GetSecretValueOutput{
+			SecretString: &secretString,
+		}, nil
+	}
+
+	return l.secretManagerMock.GetSecretValue(ctx, params, optFns...)
 }
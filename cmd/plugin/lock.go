@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/lock"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	lockBackendDynamoDB = "dynamodb"
+	lockBackendECSTags  = "ecs-tags"
+
+	defaultLockTTL = 10 * time.Minute
+	// ecsTagsLockSettle is how long the ecs-tags backend waits before checking that it still holds a lock
+	ecsTagsLockSettle = 5 * time.Second
+)
+
+// ECS tag values only allow letters, numbers, spaces and + - = . _ : / @
+var unsafeLockOwnerChars = regexp.MustCompile(`[^a-zA-Z0-9+\-=._:/@ ]`)
+
+// newLocker builds a deployment locker from the lock settings. It returns nil if locking is disabled
+func newLocker(e types.ECSClient, c Config) (*lock.Locker, error) {
+	if c.LockBackend == "" {
+		return nil, nil
+	}
+
+	var backend lock.Backend
+
+	switch c.LockBackend {
+	case lockBackendDynamoDB:
+		backend = lock.DynamoDB{
+			Client: dynamodb.NewFromConfig(awsConfig),
+			Table:  c.LockTable,
+		}
+	case lockBackendECSTags:
+		backend = lock.ECSTags{Client: e, Settle: ecsTagsLockSettle}
+	default:
+		return nil, fmt.Errorf("invalid lock_backend '%s'. Must be '%s' or '%s'", c.LockBackend, lockBackendDynamoDB, lockBackendECSTags)
+	}
+
+	owner, err := lockOwner()
+
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info(fmt.Sprintf("Deployments will be locked using the '%s' backend as '%s'", c.LockBackend, owner))
+
+	return &lock.Locker{
+		Backend:       backend,
+		Owner:         owner,
+		TTL:           c.LockTTL,
+		Wait:          c.WaitForLock,
+		RetryInterval: c.PollInterval,
+	}, nil
+}
+
+// lockOwner identifies this deployment by its repository and build number
+// A random suffix keeps the owner unique when a build is restarted
+func lockOwner() (string, error) {
+	suffix := make([]byte, 4)
+
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	repo := os.Getenv("DRONE_REPO")
+
+	if repo == "" {
+		repo = "unknown"
+	}
+
+	owner := fmt.Sprintf("%s:%s:%s", repo, os.Getenv("DRONE_BUILD_NUMBER"), hex.EncodeToString(suffix))
+
+	return unsafeLockOwnerChars.ReplaceAllString(owner, "_"), nil
+}
+
+// withDeployLock locks services for the duration of fn. fn runs without a lock if locker is nil
+// The context passed to fn is cancelled and the deploy fails if a lock is lost while fn runs
+func withDeployLock(ctx context.Context, locker *lock.Locker, cluster string, services []string, fn func(ctx context.Context) error) error {
+	if locker == nil {
+		return fn(ctx)
+	}
+
+	keys := make([]lock.Key, 0, len(services))
+
+	for _, service := range services {
+		keys = append(keys, lock.Key{Cluster: cluster, Service: service})
+	}
+
+	if err := locker.Lock(ctx, keys); err != nil {
+		logging.From(ctx).Error("Failing because the deployment lock could not be acquired", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-locker.Lost():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := fn(ctx)
+
+	locker.Unlock()
+
+	if lostErr := locker.Err(); lostErr != nil {
+		logging.From(ctx).Error("Failing because the deployment lock was lost", logging.Err(lostErr))
+		return errors.New("deploy failed")
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/lock"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"gotest.tools/assert"
+)
+
+func TestWithDeployLock(t *testing.T) {
+	items := map[string]map[string]ddbtypes.AttributeValue{}
+	backend := lock.DynamoDB{Client: lock.MockDynamoDBClient{Items: items}, Table: "locks"}
+
+	held := lock.Locker{Backend: backend, Owner: "other-build", TTL: time.Minute}
+	assert.NilError(t, held.Lock(context.Background(), []lock.Key{{Cluster: "test-cluster", Service: "test-service"}}))
+
+	locker := &lock.Locker{Backend: backend, Owner: "this-build", TTL: time.Minute, RetryInterval: time.Millisecond}
+	called := false
+
+	err := withDeployLock(context.Background(), locker, "test-cluster", []string{"test-service"}, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	assert.Error(t, err, "deploy failed")
+	assert.Assert(t, !called, "the deploy ran without the lock")
+
+	held.Unlock()
+
+	err = withDeployLock(context.Background(), locker, "test-cluster", []string{"test-service"}, func(ctx context.Context) error {
+		called = true
+
+		_, locked := items["test-cluster/test-service"]
+		assert.Assert(t, locked)
+
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Assert(t, called)
+	assert.Equal(t, 0, len(items))
+
+	// Locking is disabled when there is no locker
+	assert.NilError(t, withDeployLock(context.Background(), nil, "test-cluster", []string{"test-service"}, func(ctx context.Context) error { return nil }))
+}
+
+// stolenLockBackend acquires a lock once. Every renewal finds it held by another owner
+type stolenLockBackend struct {
+	lock.DynamoDB
+	acquired *bool
+}
+
+func (b stolenLockBackend) Acquire(ctx context.Context, key lock.Key, owner string, ttl time.Duration) error {
+	if *b.acquired {
+		return &lock.HeldError{Key: key, Owner: "other-build", Expires: time.Now().Add(ttl)}
+	}
+
+	*b.acquired = true
+
+	return b.DynamoDB.Acquire(ctx, key, owner, ttl)
+}
+
+func TestWithDeployLockLost(t *testing.T) {
+	backend := stolenLockBackend{
+		DynamoDB: lock.DynamoDB{Client: lock.MockDynamoDBClient{Items: map[string]map[string]ddbtypes.AttributeValue{}}, Table: "locks"},
+		acquired: new(bool),
+	}
+	locker := &lock.Locker{Backend: backend, Owner: "this-build", TTL: 30 * time.Millisecond, RetryInterval: time.Millisecond}
+
+	err := withDeployLock(context.Background(), locker, "test-cluster", []string{"test-service"}, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	assert.Error(t, err, "deploy failed")
+	assert.Assert(t, errors.Is(locker.Err(), lock.ErrLeaseLost), "got error %v", locker.Err())
+}
+
+func TestLockOwner(t *testing.T) {
+	os.Setenv("DRONE_REPO", "octocat/hello#world")
+	os.Setenv("DRONE_BUILD_NUMBER", "42")
+	defer os.Unsetenv("DRONE_REPO")
+	defer os.Unsetenv("DRONE_BUILD_NUMBER")
+
+	owner, err := lockOwner()
+
+	assert.NilError(t, err)
+	assert.Assert(t, regexp.MustCompile(`^octocat/hello_world:42:[0-9a-f]{8}$`).MatchString(owner), "got owner %s", owner)
+}
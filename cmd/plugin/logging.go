@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logger is used by code that has no context, such as reading settings. Code with a context logs to the logger of its context, which adds fields such as the service
+var logger = logging.Default()
+
+// newLogger returns a logger in log_format, text by default, that logs debug entries such as AWS request IDs if debug is set
+func newLogger(c Config) logging.Logger {
+	level := logging.LevelInfo
+
+	// Set debug in order to log every AWS API call with its request ID
+	if c.Debug {
+		level = logging.LevelDebug
+	}
+
+	if c.LogFormat == logFormatJSON {
+		return logging.NewJSONLogger(os.Stderr, level)
+	}
+
+	return logging.NewTextLogger(os.Stderr, level)
+}
@@ -2,114 +2,401 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/lock"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 )
 
 const (
 	defaultMaxChecksUntilFailed = 60 // 10 second between checks + 60 checks = 600 seconds = 10 minutes
+	defaultPollInterval         = 10 * time.Second
+	defaultFailFastThreshold    = 3
+	defaultLogLines             = 20
+	resultDotenvPrefix          = "DEPLOY_"
 )
 
 var (
-	disableRollbacks bool
-	maxDeployChecks  int
+	disableRollbacks   bool
+	maxDeployChecks    int
+	failFastThreshold  int
+	resolveDigests     bool
+	dryRun             bool
+	diffFormat         string
+	preDeployContainer string
+	preDeployCommand   []string
+	// settings are the settings of the deploy, loaded in main
+	settings Config
+	// deployResult records what the deploy did. It is nil in tests
+	deployResult *deploy.Result
+	// awsConfig is the AWS configuration every client is built from, loaded in main
+	awsConfig aws.Config
 )
 
 func main() {
-	// Ensure all required env vars are present
-	if err := checkEnvVars(); err != nil {
-		os.Exit(1)
-	}
+	get, err := settingsLookup(envSetting)
 
-	if os.Getenv("PLUGIN_MAX_DEPLOY_CHECKS") == "" {
-		log.Println("PLUGIN_MAX_DEPLOY_CHECKS environment variable not set. Defaulting to", defaultMaxChecksUntilFailed)
-		maxDeployChecks = defaultMaxChecksUntilFailed
-	} else {
-		convertResult, err := strconv.Atoi(os.Getenv("PLUGIN_MAX_DEPLOY_CHECKS"))
-		if err != nil {
-			log.Printf("Error converting '%s' to int. Defaulting to 60 checks, which is 10 minutes\n", os.Getenv("PLUGIN_MAX_DEPLOY_CHECKS"))
-			maxDeployChecks = defaultMaxChecksUntilFailed
-		} else {
-			maxDeployChecks = convertResult
+	// Drone runs the plugin without arguments. A command, such as deploy or status, runs it from the command line
+	if len(os.Args) > 1 {
+		get, err = commandLineSettings(filepath.Base(os.Args[0]), os.Args[1:], os.Stderr)
+
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+
+		if errors.Is(err, errUsage) {
+			os.Exit(exitUsage)
 		}
 	}
 
-	// Set disable_rollbacks to any string in order to disable them
-	if os.Getenv("PLUGIN_DISABLE_ROLLBACKS") == "" {
-		log.Println("Rollbacks are enabled. Note: this setting only applies to rolling deployments")
-		disableRollbacks = false
+	if err != nil {
+		logConfigError(err)
+		os.Exit(exitUsage)
+	}
+
+	run(get)
+}
+
+// run deploys with the settings read by get and exits with the exit code of the deploy
+func run(get func(name string) string) {
+	var err error
+
+	// Every setting is checked before anything is deployed, and every problem is reported at once
+	settings, err = loadConfig(get)
+
+	if err != nil {
+		logConfigError(err)
+		os.Exit(exitUsage)
+	}
+
+	logger = newLogger(settings)
+	logging.SetDefault(logger)
+
+	mode := settings.Mode
+
+	// mode validate only checks the settings, so nothing in AWS is touched
+	if mode == modeValidate {
+		logger.Info(fmt.Sprintf("Settings are valid for mode '%s'", settings.checkedMode()))
+		os.Exit(exitOK)
+	}
+
+	if settings.MaxDeployChecks < 0 {
+		logger.Info("max_deploy_checks not set. Checks are only limited by phase_timeout and deploy_timeout")
+	} else if get("max_deploy_checks") == "" {
+		logger.Info(fmt.Sprintf("max_deploy_checks not set. Defaulting to %v", settings.MaxDeployChecks))
+	}
+
+	maxDeployChecks = settings.MaxDeployChecks
+	failFastThreshold = settings.FailFastThreshold
+	disableRollbacks = settings.DisableRollbacks
+
+	if disableRollbacks {
+		logger.Info("Rollbacks are disabled. Note: this setting only applies to rolling deployments")
 	} else {
-		log.Println("Rollbacks are disabled. Note: this setting only applies to rolling deployments")
-		disableRollbacks = true
+		logger.Info("Rollbacks are enabled. Note: this setting only applies to rolling deployments")
+	}
+
+	diffFormat = settings.DiffFormat
+
+	// Set dry_run, or use mode plan, in order to print what a deploy would change without changing anything
+	if mode == modePlan || settings.DryRun {
+		logger.Info("Dry run. The deploy will be planned but nothing will be changed")
+		dryRun = true
+	}
+
+	if mode == modePlan {
+		mode = planMode(settings)
+		logger.Info(fmt.Sprintf("Planning a '%s' deploy", mode))
+	}
+
+	// Set resolve_digests in order to pin images to their digest
+	if settings.ResolveDigests {
+		logger.Info("Image tags will be resolved to digests before registering the new task definition revision")
+		resolveDigests = true
+	}
+
+	// Set pre_deploy_task to the name of a container in order to run a one-off task before the services are updated
+	preDeployContainer = settings.PreDeployTask
+	preDeployCommand = settings.PreDeployCommand
+
+	if preDeployContainer != "" {
+		logger.Info(fmt.Sprintf("A pre-deploy task will run container '%s' before the services are updated", preDeployContainer))
+	}
+
+	poller := deploy.Poller{
+		Interval:     settings.PollInterval,
+		PhaseTimeout: settings.PhaseTimeout,
+		MaxChecks:    maxDeployChecks,
+		// Rollbacks and clean up run after deploy_timeout has passed, so they get up to the same budget again
+		CleanupTimeout: settings.DeployTimeout,
+	}
+
+	// The deploy context is cancelled when Drone cancels the build or deploy_timeout passes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx = logging.WithLogger(ctx, logger.With(logging.Cluster(settings.Cluster)))
+
+	if settings.DeployTimeout > 0 {
+		logging.From(ctx).Info(fmt.Sprintf("The deploy will fail if it does not finish within %v", settings.DeployTimeout))
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.DeployTimeout)
+		defer cancel()
+	}
+
+	awsConfig, err = loadAWSConfig(settings.AWSRegion, settings.AWSRoleARN)
+
+	if err != nil {
+		logging.From(ctx).Error("Failed to load SDK configuration", logging.Err(err))
+		os.Exit(exitFailed)
 	}
 
 	dc := deploy.DeployConfig{
-		ECS:            newECSClient(os.Getenv("PLUGIN_AWS_REGION"), os.Getenv("PLUGIN_AWS_ROLE_ARN")),
-		AppAutoscaling: newAppAutoscalingClient(os.Getenv("PLUGIN_AWS_REGION"), os.Getenv("PLUGIN_AWS_ROLE_ARN")),
-		Cluster:        os.Getenv("PLUGIN_CLUSTER"),
-		Container:      os.Getenv("PLUGIN_CONTAINER"),
-		Image:          os.Getenv("PLUGIN_IMAGE"),
+		ECS:            ecs.NewFromConfig(awsConfig),
+		AppAutoscaling: applicationautoscaling.NewFromConfig(awsConfig),
+		Cluster:        settings.Cluster,
+		Container:      settings.Container,
+		Image:          settings.Image,
+		Containers:     settings.Containers,
+		Environment:    settings.Environment,
+		Secrets:        settings.Secrets,
+		BakePeriod:     settings.BakePeriod,
+		Logger:         logger,
+	}
+
+	// Set check_target_health in order to wait for new tasks to be healthy in every target group of the service
+	if settings.CheckTargetHealth {
+		logging.From(ctx).Info("Deployments will only succeed once their tasks are healthy in every target group")
+		dc.ELBv2 = elasticloadbalancingv2.NewFromConfig(awsConfig)
+	}
+
+	// Set alarms to CloudWatch alarm names in order to abort the deploy if any of them fires during the rollout or bake_period
+	if len(settings.Alarms) > 0 {
+		logging.From(ctx).Info(fmt.Sprintf("The deploy will be aborted if any of these alarms fires: %v", strings.Join(settings.Alarms, ", ")))
+
+		dc.Alarms = &deploy.AlarmMonitor{
+			Client: cloudwatch.NewFromConfig(awsConfig),
+			Alarms: settings.Alarms,
+		}
+	}
+
+	// log_lines is how many lines of each container's logs are printed for the stopped tasks of a failed deployment. 0 disables it
+	if settings.LogLines > 0 {
+		dc.Logs = &deploy.LogTail{
+			Client: cloudwatchlogs.NewFromConfig(awsConfig),
+			Lines:  int32(settings.LogLines),
+		}
+	}
+
+	if dc.BakePeriod > 0 && dc.Alarms == nil {
+		logging.From(ctx).Warn(fmt.Sprintf("bake_period is set but alarms is not. The deploy will wait %v without watching anything", dc.BakePeriod))
+	}
+
+	// Set lock_backend in order to stop two deployments from updating the same service at the same time
+	// A dry run changes nothing, so it does not take a lock
+	var locker *lock.Locker
+
+	if !dryRun {
+		locker, err = newLocker(dc.ECS, settings)
+
+		if err != nil {
+			logging.From(ctx).Error("Error configuring the deployment lock", logging.Err(err))
+			os.Exit(exitFailed)
+		}
+	}
+
+	// The result is written to result_file and / or result_dotenv for later pipeline steps, and describes the deploy in notifications
+	deployResult = deploy.NewResult(mode, dc.Cluster, rollbackServices(settings), dryRun)
+	poller.Observer = deployResult
+
+	// Set otlp_endpoint in order to export the deploy as a trace, with a span for every phase and AWS API call
+	tracer = newTracer(settings)
+	ctx = startTrace(ctx, mode, dc.Cluster, rollbackServices(settings))
+
+	// Set notify_webhook and / or notify_slack_webhook in order to be notified when a deploy or rollback starts and finishes
+	if !dryRun {
+		notifier = newNotifier(settings)
 	}
 
 	// check which deployment method to use based on the mode, default to rolling
-	switch os.Getenv("PLUGIN_MODE") {
-	case "blue-green":
-		if err := checkBlueGreenVars(); err != nil {
-			os.Exit(1)
+	switch mode {
+	case modeDiff:
+		if err := diffRevisions(ctx, dc.ECS, dc.Cluster, settings.Service, settings.DiffRevision); err != nil {
+			finish(err)
+		}
+	case modeStatus:
+		if err := status(ctx, dc.ECS, dc.Cluster, rollbackServices(settings)); err != nil {
+			finish(err)
 		}
-		if err := blueGreen(dc, maxDeployChecks); err != nil {
-			os.Exit(1)
+	case modeScale:
+		if err := withDeployLock(ctx, locker, dc.Cluster, []string{settings.Service}, func(ctx context.Context) error {
+			return scale(ctx, dc, poller, settings.Service, int32(settings.Count))
+		}); err != nil {
+			finish(err)
 		}
-	case "blue-green-cluster":
-		// this is the same as rolling except that it deploys to the off color
+	case modeRollback:
+		revision := settings.RollbackRevision
 
-		if err := checkBlueGreenClusterVars(); err != nil {
-			os.Exit(1)
+		if err := withDeployLock(ctx, locker, dc.Cluster, rollbackServices(settings), func(ctx context.Context) error {
+			if settings.BlueService != "" && settings.GreenService != "" {
+				return blueGreenRollback(ctx, dc, poller, revision)
+			}
+
+			return rollback(ctx, dc.ECS, dc.ELBv2, dc.Logs, dc.Cluster, poller, settings.Service, revision)
+		}); err != nil {
+			finish(err)
+		}
+	case modeBlueGreen:
+		if dc, err = pinImages(ctx, dc); err != nil {
+			finish(err)
+		}
+		if dryRun {
+			if err := planBlueGreen(ctx, dc); err != nil {
+				finish(err)
+			}
+			break
 		}
+		services := []string{settings.BlueService, settings.GreenService}
 
-		manager := newSecretsManagerClient(os.Getenv("PLUGIN_AWS_REGION"), os.Getenv("PLUGIN_AWS_ROLE_ARN"))
-		//get the inactive env. Either service name (blue/green) can be used since it does a partial match.
-		inactiveEnv, err := getGlobalInactiveEnvironment(manager, os.Getenv("DRONE_COMMIT_BRANCH"), os.Getenv("PLUGIN_SECRET_SERVICE"))
+		if err := withDeployLock(ctx, locker, dc.Cluster, services, func(ctx context.Context) error {
+			return blueGreen(ctx, dc, poller)
+		}); err != nil {
+			finish(err)
+		}
+	case modeBlueGreenCluster:
+		manager := secretsmanager.NewFromConfig(awsConfig)
 
-		if err != nil {
-			log.Println(err)
-			os.Exit(1)
+		// Both colors are locked before the inactive one is picked, so two builds can not both deploy to the same color
+		if err := withDeployLock(ctx, locker, dc.Cluster, []string{settings.BlueService, settings.GreenService}, func(ctx context.Context) error {
+			return blueGreenCluster(ctx, dc, poller, manager)
+		}); err != nil {
+			finish(err)
 		}
+	case modeCodeDeploy:
+		hooks := settings.CodeDeployHooks
 
-		//pick the image/service to deploy to based of configured live env
-		image := os.Getenv("PLUGIN_BLUE_IMAGE")
-		service := os.Getenv("PLUGIN_BLUE_SERVICE")
+		if dc, err = pinImages(ctx, dc); err != nil {
+			finish(err)
+		}
 
-		if inactiveEnv == "green" {
-			image = os.Getenv("PLUGIN_GREEN_IMAGE")
-			service = os.Getenv("PLUGIN_GREEN_SERVICE")
+		if dryRun {
+			if err := planCodeDeploy(ctx, dc, settings.Service, settings.CodeDeployApplication, settings.CodeDeployDeploymentGroup, hooks); err != nil {
+				finish(err)
+			}
+			break
 		}
 
-		count, err := deploy.GetServiceDesiredCount(context.Background(), dc.ECS, service, dc.Cluster)
+		cd := codedeploy.NewFromConfig(awsConfig)
 
-		if err != nil {
-			log.Printf("could not get desired count of service %s: %v\n", service, err)
-			os.Exit(1)
+		if err := withDeployLock(ctx, locker, dc.Cluster, []string{settings.Service}, func(ctx context.Context) error {
+			return codeDeploy(ctx, dc, cd, settings.Service, settings.CodeDeployApplication, settings.CodeDeployDeploymentGroup, hooks, poller)
+		}); err != nil {
+			finish(err)
+		}
+	case modeTaskSet:
+		initialPercent := settings.TaskSetInitialPercent
+
+		if dc, err = pinImages(ctx, dc); err != nil {
+			finish(err)
 		}
 
-		if count != 0 {
-			log.Printf("inactive environment for service %s has tasks running, this likely means we are attempting to deploy to the wrong env\n", service)
-			os.Exit(1)
+		if dryRun {
+			if err := planTaskSet(ctx, dc, settings.Service, initialPercent); err != nil {
+				finish(err)
+			}
+			break
 		}
 
-		if err := rolling(dc.ECS, dc.Cluster, dc.Container, image, maxDeployChecks, service); err != nil {
-			os.Exit(1)
+		if err := withDeployLock(ctx, locker, dc.Cluster, []string{settings.Service}, func(ctx context.Context) error {
+			return taskSet(ctx, dc, settings.Service, initialPercent, poller)
+		}); err != nil {
+			finish(err)
 		}
 	default:
-		if err := checkRollingVars(); err != nil {
-			os.Exit(1)
+		if dc, err = pinImages(ctx, dc); err != nil {
+			finish(err)
 		}
 
-		if err := rolling(dc.ECS, dc.Cluster, dc.Container, dc.Image, maxDeployChecks, os.Getenv("PLUGIN_SERVICE")); err != nil {
-			os.Exit(1)
+		if dryRun {
+			if err := planRolling(ctx, dc.ECS, dc.Cluster, dc.TaskDefinitionChanges(), settings.Service); err != nil {
+				finish(err)
+			}
+			break
 		}
+
+		if err := withDeployLock(ctx, locker, dc.Cluster, getServiceNames(settings.Service), func(ctx context.Context) error {
+			return rolling(ctx, dc, poller, settings.Service)
+		}); err != nil {
+			finish(err)
+		}
+	}
+
+	finish(nil)
+}
+
+// finish writes the deploy result, notifies the outcome and sends the metrics of a deploy that started, exports the trace and exits with the exit code of err if it is set
+func finish(err error) {
+	deployResult.Finish(err)
+	notifyFinished()
+	emitMetrics()
+	endTrace(err)
+
+	if path := settings.ResultFile; path != "" {
+		if werr := deployResult.WriteJSON(path); werr != nil {
+			logger.Error("Error writing result_file", logging.Err(werr))
+		}
+	}
+
+	if path := settings.ResultDotenv; path != "" {
+		if werr := deployResult.WriteDotenv(path, resultDotenvPrefix); werr != nil {
+			logger.Error("Error writing result_dotenv", logging.Err(werr))
+		}
+	}
+
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
+// logConfigError logs every problem with the settings on its own line
+func logConfigError(err error) {
+	var problems ConfigError
+
+	if !errors.As(err, &problems) {
+		logger.Error(err.Error())
+		return
+	}
+
+	for _, problem := range problems {
+		logger.Error(fmt.Sprintf("Invalid settings: %s", problem))
+	}
+}
+
+// pinImages resolves every image to its digest if resolve_digests is set
+func pinImages(ctx context.Context, dc deploy.DeployConfig) (deploy.DeployConfig, error) {
+	if !resolveDigests {
+		return dc, nil
 	}
+
+	resolver := newImageResolver(awsConfig, settings.RegistryUsername, settings.RegistryPassword)
+
+	return pinImageDigests(ctx, resolver, dc)
 }
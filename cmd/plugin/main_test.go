@@ -1,43 +1,62 @@
 package main
 
 import (
-	"os"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
 	"gotest.tools/assert"
 )
 
-func TestNewECSClient(t *testing.T) {
-	newECSClient("us-east-2", "arn:aws:iam::123456789012:role/some-role")
+// testPoller returns a poller that does not wait between checks
+func testPoller(maxDeployChecks int) deploy.Poller {
+	return deploy.Poller{Interval: time.Millisecond, MaxChecks: maxDeployChecks}
 }
 
-func TestCheckEnvVarsAllVarsSet(t *testing.T) {
-	os.Setenv("PLUGIN_AWS_REGION", "us-east-2")
-	os.Setenv("PLUGIN_SERVICE", "some-service")
-	os.Setenv("PLUGIN_CLUSTER", "some-cluster")
-	os.Setenv("PLUGIN_CONTAINER", "some-container-name")
-	os.Setenv("PLUGIN_IMAGE", "some/image:with-tag")
-	os.Setenv("PLUGIN_MODE", "rolling")
+func TestLoadAWSConfig(t *testing.T) {
+	cfg, err := loadAWSConfig("us-east-2", "arn:aws:iam::123456789012:role/some-role")
 
-	err := checkEnvVars()
+	assert.NilError(t, err)
+	assert.Equal(t, "us-east-2", cfg.Region)
 
-	assert.Equal(t, nil, err)
+	ecs.NewFromConfig(cfg)
 }
 
-func TestCheckEnvVarsMissing(t *testing.T) {
-	os.Unsetenv("PLUGIN_CLUSTER")
+const testTGARN = "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/web/abc"
 
-	err := checkEnvVars()
-
-	assert.Error(t, err, "env var not set")
+var (
+	testLoadBalancers = []ecstypes.LoadBalancer{
+		{TargetGroupArn: aws.String(testTGARN), ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+	}
+	testAwsvpcTask = ecstypes.Task{
+		TaskArn:           aws.String("arn-1"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"),
+		Containers: []ecstypes.Container{
+			{
+				Name:              aws.String("app"),
+				NetworkInterfaces: []ecstypes.NetworkInterface{{PrivateIpv4Address: aws.String("10.0.0.1")}},
+			},
+		},
+	}
+)
 
+func testTargetHealth(state elbtypes.TargetHealthStateEnum) elbtypes.TargetHealthDescription {
+	return elbtypes.TargetHealthDescription{
+		Target:       &elbtypes.TargetDescription{Id: aws.String("10.0.0.1"), Port: aws.Int32(8080)},
+		TargetHealth: &elbtypes.TargetHealth{State: state},
+	}
 }
 
 func Test_release(t *testing.T) {
 	type args struct {
 		e                 types.ECSClient
+		lb                types.ELBv2Client
 		service           string
 		cluster           string
 		maxDeployChecks   int
@@ -97,10 +116,54 @@ func Test_release(t *testing.T) {
 			want:    false,
 			wantErr: true,
 		},
+		{
+			name: "test-targets-healthy",
+			args: args{
+				e: deploy.MockECSClient{
+					DeploymentState: "COMPLETED",
+					TestingT:        t,
+					LoadBalancers:   testLoadBalancers,
+					Tasks:           []ecstypes.Task{testAwsvpcTask},
+				},
+				lb: deploy.MockELBv2Client{
+					TargetHealth: map[string][]elbtypes.TargetHealthDescription{
+						testTGARN: {testTargetHealth(elbtypes.TargetHealthStateEnumHealthy)},
+					},
+				},
+				service:           "test-service",
+				cluster:           "test-cluster",
+				maxDeployChecks:   3,
+				taskDefinitionARN: "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1",
+			},
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name: "test-targets-unhealthy",
+			args: args{
+				e: deploy.MockECSClient{
+					DeploymentState: "COMPLETED",
+					TestingT:        t,
+					LoadBalancers:   testLoadBalancers,
+					Tasks:           []ecstypes.Task{testAwsvpcTask},
+				},
+				lb: deploy.MockELBv2Client{
+					TargetHealth: map[string][]elbtypes.TargetHealthDescription{
+						testTGARN: {testTargetHealth(elbtypes.TargetHealthStateEnumUnhealthy)},
+					},
+				},
+				service:           "test-service",
+				cluster:           "test-cluster",
+				maxDeployChecks:   3,
+				taskDefinitionARN: "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1",
+			},
+			want:    false,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := release(tt.args.e, tt.args.service, tt.args.cluster, tt.args.maxDeployChecks, tt.args.taskDefinitionARN)
+			got, err := release(context.Background(), tt.args.e, tt.args.lb, nil, nil, tt.args.service, tt.args.cluster, testPoller(tt.args.maxDeployChecks), tt.args.taskDefinitionARN)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("release() error = %v, wantErr %v", err, tt.wantErr)
 				return
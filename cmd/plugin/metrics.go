@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/metrics"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// metricsJob is the Pushgateway job deploy metrics are pushed under
+const metricsJob = "drone_deploy_ecs"
+
+// newMetricSinks returns a sink for every metrics backend that is set
+func newMetricSinks(c Config) []metrics.Sink {
+	var sinks []metrics.Sink
+
+	if url := c.MetricsPushgateway; url != "" {
+		sinks = append(sinks, metrics.Pushgateway{URL: url, Job: metricsJob, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+
+	if address := c.MetricsStatsD; address != "" {
+		sinks = append(sinks, metrics.StatsD{Address: address})
+	}
+
+	if address := c.MetricsDogStatsD; address != "" {
+		sinks = append(sinks, metrics.StatsD{Address: address, DogStatsD: true})
+	}
+
+	if namespace := c.MetricsCloudWatchNamespace; namespace != "" {
+		sinks = append(sinks, metrics.CloudWatch{
+			Client:    cloudwatch.NewFromConfig(awsConfig),
+			Namespace: namespace,
+		})
+	}
+
+	return sinks
+}
+
+// emitMetrics sends the metrics of a deploy that started. deployResult must already be finished
+func emitMetrics() {
+	if deployResult == nil || deployResult.TaskDefinition == nil || deployResult.DryRun {
+		return
+	}
+
+	sinks := newMetricSinks(settings)
+
+	if len(sinks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	metrics.Emit(ctx, sinks, metrics.FromResult(deployResult))
+}
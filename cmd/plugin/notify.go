@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/notify"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// notifier sends lifecycle notifications if notify_webhook or notify_slack_webhook is set. It is nil otherwise
+var notifier *notify.Notifier
+
+// newNotifier returns a notifier for every URL in notify_webhook and notify_slack_webhook, or nil if neither is set
+func newNotifier(c Config) *notify.Notifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var targets []notify.Target
+
+	for _, url := range c.NotifyWebhooks {
+		targets = append(targets, notify.Webhook{URL: url, Client: client})
+	}
+
+	for _, url := range c.NotifySlackWebhooks {
+		targets = append(targets, notify.Slack{URL: url, Client: client})
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	return notify.NewNotifier(targets...)
+}
+
+// newEvent describes the deploy so far from deployResult
+func newEvent(t notify.EventType) notify.Event {
+	e := notify.Event{
+		Type:      t,
+		BuildLink: os.Getenv("DRONE_BUILD_LINK"),
+	}
+
+	if deployResult == nil {
+		return e
+	}
+
+	e.Mode = deployResult.Mode
+	e.Cluster = deployResult.Cluster
+	e.Services = deployResult.Services
+	e.FailureCategory = deployResult.FailureCategory
+	e.Error = deployResult.Error
+
+	if deployResult.TaskDefinition == nil {
+		return e
+	}
+
+	var previous map[string]string
+
+	if deployResult.PreviousTaskDefinition != nil {
+		previous = deployResult.PreviousTaskDefinition.Images
+	}
+
+	for container, image := range deployResult.TaskDefinition.Images {
+		if previous[container] != image {
+			e.Images = append(e.Images, notify.ImageChange{Container: container, Previous: previous[container], Image: image})
+		}
+	}
+
+	sort.Slice(e.Images, func(i, j int) bool {
+		return e.Images[i].Container < e.Images[j].Container
+	})
+
+	return e
+}
+
+// deployStarted records the task definitions of a deploy and notifies that it started
+// A rollback mode deploy notifies that its rollback started instead
+func deployStarted(previous ecstypes.TaskDefinition, next ecstypes.TaskDefinition) {
+	started := deployResult != nil && deployResult.TaskDefinition != nil
+
+	deployResult.SetTaskDefinitions(previous, next)
+
+	// Services of a multi-service deploy all start the same revision, so only the first one is notified
+	if started {
+		return
+	}
+
+	if deployResult != nil && deployResult.Mode == "rollback" {
+		notifier.Notify(newEvent(notify.EventRollbackStarted))
+		return
+	}
+
+	notifier.Notify(newEvent(notify.EventStarted))
+}
+
+// rollbackStarted notifies that a failed deploy is being rolled back
+// It returns a context whose rollback span is ended by rollbackFinished
+func rollbackStarted(ctx context.Context) context.Context {
+	notifier.Notify(newEvent(notify.EventRollbackStarted))
+
+	ctx, _ = tracing.Start(ctx, "rollback")
+
+	return ctx
+}
+
+// rollbackFinished records whether a failed deploy was rolled back and notifies it. ctx is the context returned by rollbackStarted
+func rollbackFinished(ctx context.Context, ok bool) {
+	deployResult.SetRollback(ok)
+
+	if ok {
+		tracing.SpanFromContext(ctx).End(nil)
+	} else {
+		tracing.SpanFromContext(ctx).End(errors.New("rollback failed"))
+	}
+
+	e := newEvent(notify.EventRollbackFinished)
+	e.Rollback = deploy.RollbackFailed
+
+	if ok {
+		e.Rollback = deploy.RollbackSucceeded
+	}
+
+	notifier.Notify(e)
+}
+
+// notifyFinished notifies the outcome of a deploy that started. deployResult must already be finished
+func notifyFinished() {
+	if deployResult == nil || deployResult.TaskDefinition == nil {
+		return
+	}
+
+	if deployResult.Mode == "rollback" {
+		e := newEvent(notify.EventRollbackFinished)
+		e.Rollback = deployResult.Outcome
+		notifier.Notify(e)
+		return
+	}
+
+	if deployResult.Outcome == deploy.OutcomeSucceeded {
+		notifier.Notify(newEvent(notify.EventSucceeded))
+	} else {
+		notifier.Notify(newEvent(notify.EventFailed))
+	}
+}
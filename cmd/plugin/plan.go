@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// planMode returns the mode that mode plan runs the discovery steps of
+// validate_mode is planned if it is set. Otherwise a CodeDeploy deployment is planned if its settings are set,
+// a blue / green cluster deployment if secret_service is set, a blue / green deployment if both services are set
+// and a rolling deployment if none are
+func planMode(c Config) string {
+	if c.ValidateMode != "" {
+		return c.ValidateMode
+	}
+
+	if c.CodeDeployApplication != "" || c.CodeDeployDeploymentGroup != "" {
+		return modeCodeDeploy
+	}
+
+	if c.SecretService != "" {
+		return modeBlueGreenCluster
+	}
+
+	if c.BlueService != "" && c.GreenService != "" {
+		return modeBlueGreen
+	}
+
+	return modeRolling
+}
+
+// planTaskDefinition prints the task definition revision that would be cloned and the changes that would be made to it
+// Nothing is registered
+func planTaskDefinition(ctx context.Context, e types.ECSClient, taskDefinitionARN string, changes deploy.TaskDefinitionChanges) (ecstypes.TaskDefinition, error) {
+	currTD, err := deploy.RetrieveTaskDefinition(ctx, e, taskDefinitionARN)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
+		return currTD, errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: revision %d of task definition family '%s' would be cloned", currTD.Revision, aws.ToString(currTD.Family)))
+
+	i, err := deploy.NewTaskDefinitionRevisionInput(ctx, e, currTD, changes)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because the new task definition revision is invalid", logging.Err(err))
+		return currTD, errors.New("plan failed")
+	}
+
+	for _, container := range i.ContainerDefinitions {
+		logging.From(ctx).Info(fmt.Sprintf("Plan: container '%s' would use image '%s'", aws.ToString(container.Name), aws.ToString(container.Image)))
+	}
+
+	showTaskDefinitionDiff(currTD, deploy.PlannedTaskDefinition(currTD, i))
+
+	if preDeployContainer != "" {
+		logging.From(ctx).Info(fmt.Sprintf("Plan: a pre-deploy task would run container '%s' with the new revision before any service is updated", preDeployContainer))
+
+		if len(preDeployCommand) > 0 {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: the pre-deploy task command would be '%s'", strings.Join(preDeployCommand, " ")))
+		}
+	}
+
+	return currTD, nil
+}
+
+// planRolling prints what a rolling deployment would change without changing anything
+func planRolling(ctx context.Context, e types.ECSClient, cluster string, changes deploy.TaskDefinitionChanges, service string) error {
+	services := getServiceNames(service)
+
+	td, err := deploy.GetServiceRunningTaskDefinition(ctx, e, services[0], cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' is running task definition '%s'", services[0], td))
+
+	currTD, err := planTaskDefinition(ctx, e, td, changes)
+
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' would be updated to the new revision", service))
+
+		if disableRollbacks {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' would not be rolled back if its deployment fails", service))
+		} else {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' would be rolled back to '%s' if its deployment fails", service, aws.ToString(currTD.TaskDefinitionArn)))
+		}
+	}
+
+	logging.From(ctx).Info("Plan complete. Nothing was changed")
+
+	return nil
+}
+
+// planBlueGreen prints what a blue / green deployment would change without changing anything
+func planBlueGreen(ctx context.Context, dc deploy.DeployConfig) error {
+	determinedBlueService, determinedGreenService, err := determineBlueGreen(ctx, dc.ECS, settings.BlueService, settings.GreenService, dc.Cluster)
+
+	if err != nil {
+		return err
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' is blue and '%s' is green", determinedBlueService, determinedGreenService))
+
+	td, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, determinedBlueService, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: blue service '%s' is running task definition '%s'", determinedBlueService, td))
+
+	if _, err := planTaskDefinition(ctx, dc.ECS, td, dc.TaskDefinitionChanges()); err != nil {
+		return err
+	}
+
+	currBlueDesiredCount, err := deploy.GetServiceDesiredCount(ctx, dc.ECS, determinedBlueService, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining desired count for blue service", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	serviceUsesAppAutoscaling, err := deploy.AppAutoscalingTargetExists(ctx, dc.AppAutoscaling, dc.Cluster, determinedBlueService)
+
+	if err != nil {
+		logging.From(ctx).Error("Error determining if service uses application autoscaling", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: green service '%s' would be updated to the new revision", determinedGreenService))
+
+	if serviceUsesAppAutoscaling {
+		serviceMaxCount, serviceMinCount, err := deploy.GetServiceMinMaxCount(ctx, dc.AppAutoscaling, dc.Cluster, determinedBlueService)
+
+		if err != nil {
+			logging.From(ctx).Error("Error determining service max count", logging.Err(err))
+			return errors.New("plan failed")
+		}
+
+		logging.From(ctx).Info(fmt.Sprintf("Plan: autoscaling of green service '%s' would be set to min %d and max %d", determinedGreenService, serviceMinCount, serviceMaxCount))
+	} else {
+		logging.From(ctx).Info(fmt.Sprintf("Plan: blue service '%s' does not use application autoscaling", determinedBlueService))
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: desired count of green service '%s' would be set to %d", determinedGreenService, currBlueDesiredCount))
+	logging.From(ctx).Info(fmt.Sprintf("Plan: green must pass %d consecutive healthy checks, then blue would be scaled down after %d seconds", settings.ChecksToPass, settings.ScaleDownWaitPeriod))
+
+	schedule := scaleDownSchedule(int(currBlueDesiredCount), float64(settings.ScaleDownPercent)/float64(100))
+
+	for idx, count := range schedule {
+		if idx > 0 {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: wait %d seconds", settings.ScaleDownInterval))
+		}
+
+		if serviceUsesAppAutoscaling {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: scale down step %d: desired count of blue service '%s' would be set to %d with autoscaling min 0 and max %d", idx+1, determinedBlueService, count, count))
+		} else {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: scale down step %d: desired count of blue service '%s' would be set to %d", idx+1, determinedBlueService, count))
+		}
+	}
+
+	logging.From(ctx).Info("Plan complete. Nothing was changed")
+
+	return nil
+}
+
+// planCodeDeploy prints what a CodeDeploy deployment would change without changing anything
+func planCodeDeploy(ctx context.Context, dc deploy.DeployConfig, service string, application string, deploymentGroup string, hooks map[string]string) error {
+	td, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' is running task definition '%s'", service, td))
+
+	if _, err := planTaskDefinition(ctx, dc.ECS, td, dc.TaskDefinitionChanges()); err != nil {
+		return err
+	}
+
+	loadBalancers, err := deploy.GetServiceLoadBalancers(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the service load balancers", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	// The ARN of the new revision is only known once it is registered
+	appSpec, err := deploy.GenerateAppSpec("<new task definition revision>", loadBalancers, hooks)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error generating the AppSpec", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: a deployment would be created in CodeDeploy application '%s' and deployment group '%s' with AppSpec %s", application, deploymentGroup, appSpec))
+	logging.From(ctx).Info("Plan complete. Nothing was changed")
+
+	return nil
+}
+
+// planTaskSet prints what a task set deployment would change without changing anything
+func planTaskSet(ctx context.Context, dc deploy.DeployConfig, service string, initialPercent float64) error {
+	primary, err := deploy.GetPrimaryTaskSet(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the primary task set", logging.Err(err))
+		return errors.New("plan failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: primary task set '%s' of service '%s' is running task definition '%s'", aws.ToString(primary.Id), service, aws.ToString(primary.TaskDefinition)))
+
+	if _, err := planTaskDefinition(ctx, dc.ECS, aws.ToString(primary.TaskDefinition), dc.TaskDefinitionChanges()); err != nil {
+		return err
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Plan: a task set would be created at %.0f percent, scaled to 100 percent and promoted to primary", initialPercent))
+	logging.From(ctx).Info(fmt.Sprintf("Plan: task set '%s' would then be deleted", aws.ToString(primary.Id)))
+	logging.From(ctx).Info("Plan complete. Nothing was changed")
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"gotest.tools/assert"
+)
+
+func Test_planRolling(t *testing.T) {
+	registered := []*ecs.RegisterTaskDefinitionInput{}
+	updated := []string{}
+
+	e := deploy.MockECSClient{
+		TestingT:                  t,
+		RegisteredTaskDefinitions: &registered,
+		UpdatedServices:           &updated,
+	}
+
+	changes := deploy.TaskDefinitionChanges{Images: map[string]string{"app": "foo/app:3"}}
+
+	err := planRolling(context.Background(), e, "test-cluster", changes, "test-service")
+
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(registered))
+	assert.Equal(t, 0, len(updated))
+
+	// A plan fails if the deploy would fail to register the new revision
+	changes = deploy.TaskDefinitionChanges{Images: map[string]string{"missing": "foo/app:3"}}
+
+	err = planRolling(context.Background(), e, "test-cluster", changes, "test-service")
+
+	assert.ErrorContains(t, err, "plan failed")
+}
+
+func Test_planMode(t *testing.T) {
+	assert.Equal(t, "rolling", planMode(Config{Service: "webapp"}))
+	assert.Equal(t, "blue-green", planMode(Config{BlueService: "webapp-blue", GreenService: "webapp-green"}))
+	assert.Equal(t, "blue-green-cluster", planMode(Config{BlueService: "webapp-blue", GreenService: "webapp-green", SecretService: "webapp"}))
+	assert.Equal(t, "codedeploy", planMode(Config{Service: "webapp", CodeDeployApplication: "webapp", CodeDeployDeploymentGroup: "webapp-prod"}))
+	assert.Equal(t, "taskset", planMode(Config{Service: "webapp", ValidateMode: "taskset"}))
+}
+
+func Test_scaleDownSchedule(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialCount int
+		percent      float64
+		want         []int32
+	}{
+		{
+			name:         "quarters",
+			initialCount: 8,
+			percent:      0.25,
+			want:         []int32{6, 4, 2, 0},
+		},
+		{
+			name:         "uneven",
+			initialCount: 10,
+			percent:      0.3,
+			want:         []int32{7, 4, 1, 0},
+		},
+		{
+			name:         "less-than-one-container",
+			initialCount: 3,
+			percent:      0.1,
+			want:         []int32{2, 1, 0},
+		},
+		{
+			name:         "all-at-once",
+			initialCount: 4,
+			percent:      1,
+			want:         []int32{0},
+		},
+		{
+			name:         "zero-percent",
+			initialCount: 2,
+			percent:      0,
+			want:         []int32{1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaleDownSchedule(tt.initialCount, tt.percent); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scaleDownSchedule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
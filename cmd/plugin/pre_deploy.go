@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// runPreDeployTask runs a one-off task with the new task definition revision before any service is updated
+// The deployment fails unless preDeployContainer exits with code 0
+func runPreDeployTask(ctx context.Context, e types.ECSClient, cluster string, service string, taskDefinitionARN string, p deploy.Poller) error {
+	if preDeployContainer == "" {
+		return nil
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Running pre-deploy task with container '%s' using the network configuration of service '%s'", preDeployContainer, service))
+
+	taskARN, err := deploy.RunServiceTask(ctx, e, service, cluster, taskDefinitionARN, preDeployContainer, preDeployCommand)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error starting the pre-deploy task", logging.Err(err))
+		return errors.New("pre-deploy task failed")
+	}
+
+	return waitForPreDeployTask(ctx, e, cluster, taskARN, p)
+}
+
+// runTaskSetPreDeployTask runs the pre-deploy task of a service that uses the EXTERNAL deployment controller
+// The network configuration and launch type are those of the primary task set, since the service has none
+func runTaskSetPreDeployTask(ctx context.Context, e types.ECSClient, cluster string, primary ecstypes.TaskSet, taskDefinitionARN string, p deploy.Poller) error {
+	if preDeployContainer == "" {
+		return nil
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Running pre-deploy task with container '%s' using the network configuration of task set '%s'", preDeployContainer, aws.ToString(primary.Id)))
+
+	taskARN, err := deploy.RunTaskSetTask(ctx, e, primary, cluster, taskDefinitionARN, preDeployContainer, preDeployCommand)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error starting the pre-deploy task", logging.Err(err))
+		return errors.New("pre-deploy task failed")
+	}
+
+	return waitForPreDeployTask(ctx, e, cluster, taskARN, p)
+}
+
+// waitForPreDeployTask waits for the pre-deploy task to stop and fails unless preDeployContainer exited with code 0
+func waitForPreDeployTask(ctx context.Context, e types.ECSClient, cluster string, taskARN string, p deploy.Poller) error {
+	logging.From(ctx).Info(fmt.Sprintf("Started pre-deploy task %v", taskARN))
+
+	var task *ecstypes.Task
+	var err error
+
+	err = p.Poll(ctx, "pre-deploy task to finish", func(ctx context.Context) (bool, error) {
+		task, err = deploy.CheckTaskStopped(ctx, e, cluster, taskARN)
+		return task != nil, err
+	})
+
+	if pollTimedOut(err) {
+		logging.From(ctx).Info("Stopping pre-deploy task")
+
+		cleanupCtx, cancel := p.CleanupContext(ctx)
+		defer cancel()
+
+		deploy.StopTask(cleanupCtx, e, cluster, taskARN, "drone-deploy-ecs pre-deploy task timed out")
+		return errors.New("pre-deploy task failed")
+	}
+
+	if err != nil {
+		logging.From(ctx).Error("Error checking pre-deploy task", logging.Err(err))
+		return errors.New("pre-deploy task failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Pre-deploy task stopped: %s", aws.ToString(task.StoppedReason)))
+
+	for _, container := range task.Containers {
+		if container.Reason != nil {
+			logging.From(ctx).Info(fmt.Sprintf("Container '%s' reason: %s", aws.ToString(container.Name), *container.Reason))
+		}
+	}
+
+	exitCode, err := deploy.ContainerExitCode(*task, preDeployContainer)
+
+	if err != nil {
+		logging.From(ctx).Error("Pre-deploy task failed", logging.Err(err))
+		return errors.New("pre-deploy task failed")
+	}
+
+	if exitCode != 0 {
+		logging.From(ctx).Error(fmt.Sprintf("Pre-deploy container '%s' exited with code %d", preDeployContainer, exitCode))
+		return errors.New("pre-deploy task failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Pre-deploy container '%s' exited with code 0", preDeployContainer))
+	return nil
+}
+
+// parseCommand decodes a command setting, which is a JSON array, a list or a string
+// Drone passes lists of strings to plugins joined by commas, so a value with a comma is a list. A string, such as
+// bundle exec rake db:migrate, is split on whitespace. A JSON array can be used for arguments that contain commas or spaces
+func parseCommand(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(s), "[") {
+		var command []string
+
+		if err := json.Unmarshal([]byte(s), &command); err != nil {
+			return nil, err
+		}
+
+		return command, nil
+	}
+
+	if strings.Contains(s, ",") {
+		return strings.Split(s, ","), nil
+	}
+
+	return strings.Fields(s), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+func Test_runPreDeployTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode *int32
+		wantErr  bool
+	}{
+		{
+			name:     "test-success",
+			exitCode: aws.Int32(0),
+			wantErr:  false,
+		},
+		{
+			name:     "test-non-zero-exit",
+			exitCode: aws.Int32(1),
+			wantErr:  true,
+		},
+	}
+
+	preDeployContainer = "app"
+	preDeployCommand = []string{"rake", "db:migrate"}
+
+	defer func() {
+		preDeployContainer = ""
+		preDeployCommand = nil
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := deploy.MockECSClient{
+				TestingT: t,
+				Tasks: []ecstypes.Task{
+					{
+						TaskArn:       aws.String("arn-run-task"),
+						LastStatus:    aws.String("STOPPED"),
+						StoppedReason: aws.String("Essential container in task exited"),
+						Containers: []ecstypes.Container{
+							{Name: aws.String("app"), ExitCode: tt.exitCode},
+						},
+					},
+				},
+			}
+
+			err := runPreDeployTask(context.Background(), e, "test-cluster", "test-service", "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:2", testPoller(3))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runPreDeployTask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", s: "", want: nil},
+		{name: "drone-list", s: "bundle,exec,rake,db:migrate", want: []string{"bundle", "exec", "rake", "db:migrate"}},
+		{name: "string", s: " bundle exec  rake db:migrate ", want: []string{"bundle", "exec", "rake", "db:migrate"}},
+		{name: "drone-list-with-spaces", s: "sh,-c,echo hello", want: []string{"sh", "-c", "echo hello"}},
+		{name: "json-list", s: `["sh", "-c", "echo a,b"]`, want: []string{"sh", "-c", "echo a,b"}},
+		{name: "invalid-json", s: `["sh"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCommand(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+var revisionNumber = regexp.MustCompile(`^[0-9]+$`)
+
+// rollbackServices returns the services a rollback moves back. Blue / green services are rolled back if both are set
+func rollbackServices(c Config) []string {
+	if c.BlueService != "" && c.GreenService != "" {
+		return []string{c.BlueService, c.GreenService}
+	}
+
+	return getServiceNames(c.Service)
+}
+
+// rollbackTarget returns the ARN of the task definition a service running current is rolled back to
+// revision may be empty for the previous active revision of the family, a revision number, a family:revision or an ARN
+func rollbackTarget(ctx context.Context, e types.ECSClient, current ecstypes.TaskDefinition, revision string) (ecstypes.TaskDefinition, error) {
+	target := revision
+
+	if revision == "" {
+		previous, err := deploy.PreviousTaskDefinitionRevision(ctx, e, current)
+
+		if err != nil {
+			return ecstypes.TaskDefinition{}, err
+		}
+
+		target = previous
+	} else if revisionNumber.MatchString(revision) {
+		target = aws.ToString(current.Family) + ":" + revision
+	}
+
+	return deploy.RetrieveTaskDefinition(ctx, e, target)
+}
+
+// rollback moves each service back to the previous revision of its family, or to revision if it is set
+// Each service is released the same way as a rolling deployment. A failed rollback is not rolled back
+func rollback(ctx context.Context, e types.ECSClient, lb types.ELBv2Client, logs *deploy.LogTail, cluster string, p deploy.Poller, service string, revision string) error {
+	for _, service := range getServiceNames(service) {
+		ctx := logging.With(ctx, logging.Service(service))
+
+		running, err := deploy.GetServiceRunningTaskDefinition(ctx, e, service, cluster)
+
+		if err != nil {
+			logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+			return errors.New("rollback failed")
+		}
+
+		currTD, err := deploy.RetrieveTaskDefinition(ctx, e, running)
+
+		if err != nil {
+			logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
+			return errors.New("rollback failed")
+		}
+
+		targetTD, err := rollbackTarget(ctx, e, currTD, revision)
+
+		if err != nil {
+			logging.From(ctx).Error(fmt.Sprintf("Failing because of an error finding the revision to roll service '%s' back to", service), logging.Err(err))
+			return errors.New("rollback failed")
+		}
+
+		if aws.ToString(targetTD.TaskDefinitionArn) == running {
+			logging.From(ctx).Info(fmt.Sprintf("Service '%s' is already running task definition '%s'", service, running))
+			continue
+		}
+
+		showTaskDefinitionDiff(currTD, targetTD)
+
+		if dryRun {
+			logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' would be rolled back to task definition '%s'", service, aws.ToString(targetTD.TaskDefinitionArn)))
+			continue
+		}
+
+		logging.From(ctx).Info(fmt.Sprintf("Rolling back service '%s' to task definition '%s'", service, aws.ToString(targetTD.TaskDefinitionArn)))
+		deployStarted(currTD, targetTD)
+
+		if ok, _ := release(ctx, e, lb, nil, logs, service, cluster, p, *targetTD.TaskDefinitionArn); !ok {
+			logging.From(ctx).Error(fmt.Sprintf("Rollback failed for service '%s'", service))
+			return errors.New("rollback failed")
+		}
+
+		logging.From(ctx).Info(fmt.Sprintf("Rollback succeeded for service '%s'", service))
+	}
+
+	return nil
+}
+
+// blueGreenRollback scales the previous color back up and then scales the current color down
+// The previous color keeps the task definition it was running unless revision is set
+func blueGreenRollback(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, revision string) error {
+	active, previous, err := determineBlueGreen(ctx, dc.ECS, settings.BlueService, settings.GreenService, dc.Cluster)
+
+	if err != nil {
+		return errors.New("rollback failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Rolling back from service '%s' to service '%s'", active, previous))
+
+	activeARN, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, active, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	activeTD, err := deploy.RetrieveTaskDefinition(ctx, dc.ECS, activeARN)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	previousARN, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, previous, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the task definition of the previous service", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	var targetTD ecstypes.TaskDefinition
+
+	if revision == "" {
+		targetTD, err = deploy.RetrieveTaskDefinition(ctx, dc.ECS, previousARN)
+	} else {
+		targetTD, err = rollbackTarget(ctx, dc.ECS, activeTD, revision)
+	}
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the task definition to roll back to", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	showTaskDefinitionDiff(activeTD, targetTD)
+
+	if dryRun {
+		logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' would be scaled up with task definition '%s' and service '%s' would be scaled down to 0", previous, aws.ToString(targetTD.TaskDefinitionArn), active))
+		return nil
+	}
+
+	deployResult.SetLive(active, "")
+	deployStarted(activeTD, targetTD)
+
+	if aws.ToString(targetTD.TaskDefinitionArn) != previousARN {
+		// The previous service has no tasks, so there is no deployment to wait for
+		if _, err := deploy.UpdateServiceTaskDefinitionVersion(ctx, dc.ECS, previous, dc.Cluster, *targetTD.TaskDefinitionArn); err != nil {
+			logging.From(ctx).Error("Error updating task definition for service", logging.Err(err))
+			return errors.New("rollback failed")
+		}
+	}
+
+	activeDesiredCount, err := deploy.GetServiceDesiredCount(ctx, dc.ECS, active, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining desired count for the current service", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	serviceUsesAppAutoscaling, err := deploy.AppAutoscalingTargetExists(ctx, dc.AppAutoscaling, dc.Cluster, active)
+
+	if err != nil {
+		logging.From(ctx).Error("Error determining if service uses application autoscaling", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	var serviceMaxCount int32 = -1
+	var serviceMinCount int32
+
+	if serviceUsesAppAutoscaling {
+		serviceMaxCount, serviceMinCount, err = deploy.GetServiceMinMaxCount(ctx, dc.AppAutoscaling, dc.Cluster, active)
+
+		if err != nil {
+			logging.From(ctx).Error("Error determining service max count", logging.Err(err))
+			return errors.New("rollback failed")
+		}
+	}
+
+	if err := dc.ScaleUp(ctx, activeDesiredCount, serviceMinCount, serviceMaxCount, previous); err != nil {
+		logging.From(ctx).Error("Error scaling up previous service", logging.Err(err))
+		scaleDownGreen(ctx, dc, p, previous, serviceUsesAppAutoscaling)
+		return errors.New("rollback failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Pausing for %v while ECS schedules %v containers", greenSchedulingPause, activeDesiredCount))
+
+	if err := deploy.Sleep(ctx, greenSchedulingPause); err != nil {
+		scaleDownGreen(ctx, dc, p, previous, serviceUsesAppAutoscaling)
+		return errors.New("rollback failed")
+	}
+
+	err = p.Poll(ctx, "previous service to scale up", func(ctx context.Context) (bool, error) {
+		finished, err := dc.GreenScaleUpFinished(ctx, previous)
+
+		if err != nil || !finished || dc.ELBv2 == nil {
+			return finished, err
+		}
+
+		return deploy.CheckTargetsHealthy(ctx, dc.ECS, dc.ELBv2, previous, dc.Cluster, "")
+	})
+
+	if err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Previous service '%s' did not scale up. Scaling it down and leaving service '%s' running", previous, active))
+		scaleDownGreen(ctx, dc, p, previous, serviceUsesAppAutoscaling)
+		return errors.New("rollback failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Service '%s' finished scaling up! Scaling down service '%s'", previous, active))
+	deployResult.SetLive(previous, "")
+
+	// The current color is broken, so it is scaled down at once instead of in percentages
+	if err := dc.ScaleDown(ctx, 0, 0, 0, active, serviceUsesAppAutoscaling); err != nil {
+		logging.From(ctx).Error("Error scaling down service", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	err = p.Poll(ctx, "current service to finish scaling down", func(ctx context.Context) (bool, error) {
+		return dc.GreenScaleUpFinished(ctx, active)
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Error waiting for service to scale down", logging.Err(err))
+		return errors.New("rollback failed")
+	}
+
+	logging.From(ctx).Info("Rollback complete")
+
+	return nil
+}
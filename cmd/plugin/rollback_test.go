@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func Test_rollback(t *testing.T) {
+	const (
+		runningARN  = "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"
+		previousARN = "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:4"
+		olderARN    = "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:3"
+		newerARN    = "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:6"
+	)
+
+	// The mock service runs the ARN of revision 1. It is described as revision 5 so there are older revisions to roll back to
+	taskDefinitions := map[string]ecstypes.TaskDefinition{
+		runningARN:            {Family: aws.String("amazon-ecs-sample"), Revision: 5, TaskDefinitionArn: aws.String(runningARN)},
+		previousARN:           {Family: aws.String("amazon-ecs-sample"), Revision: 4, TaskDefinitionArn: aws.String(previousARN)},
+		"amazon-ecs-sample:3": {Family: aws.String("amazon-ecs-sample"), Revision: 3, TaskDefinitionArn: aws.String(olderARN)},
+	}
+
+	tests := []struct {
+		name            string
+		revision        string
+		arns            []string
+		deploymentState ecstypes.DeploymentRolloutState
+		wantUpdated     []string
+		wantErr         bool
+	}{
+		{
+			name:            "previous-revision",
+			arns:            []string{newerARN, previousARN, olderARN},
+			deploymentState: ecstypes.DeploymentRolloutStateCompleted,
+			wantUpdated:     []string{"test-service"},
+		},
+		{
+			name:            "revision-number",
+			revision:        "3",
+			deploymentState: ecstypes.DeploymentRolloutStateCompleted,
+			wantUpdated:     []string{"test-service"},
+		},
+		{
+			name:            "already-running",
+			revision:        runningARN,
+			deploymentState: ecstypes.DeploymentRolloutStateCompleted,
+		},
+		{
+			name:            "no-previous-revision",
+			arns:            []string{newerARN},
+			deploymentState: ecstypes.DeploymentRolloutStateCompleted,
+			wantErr:         true,
+		},
+		{
+			name:            "rollback-fails",
+			arns:            []string{newerARN, previousARN, olderARN},
+			deploymentState: ecstypes.DeploymentRolloutStateFailed,
+			wantUpdated:     []string{"test-service"},
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated []string
+
+			e := deploy.MockECSClient{
+				TestingT:           t,
+				DeploymentState:    tt.deploymentState,
+				TaskDefinitions:    taskDefinitions,
+				TaskDefinitionARNs: tt.arns,
+				UpdatedServices:    &updated,
+			}
+
+			err := rollback(context.TODO(), e, nil, nil, "test-cluster", testPoller(3), "test-service", tt.revision)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rollback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.DeepEqual(t, tt.wantUpdated, updated)
+		})
+	}
+}
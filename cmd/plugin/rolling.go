@@ -3,121 +3,254 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
 	"time"
 
 	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
 // Return values -> success (bool), error
-func release(e types.ECSClient, service string, cluster string, maxDeployChecks int, taskDefinitionARN string) (bool, error) {
-	var err error
+// If lb is set, the deployment only succeeds once its tasks are healthy in every target group of the service
+// If alarms is set, the deployment fails as soon as a watched alarm fires
+// A deployment that fails in a way that can never succeed, such as unplaceable tasks, fails without waiting out its checks
+// The stopped tasks of a failed deployment are reported, with the tail of each container's logs if logs is set
+func release(ctx context.Context, e types.ECSClient, lb types.ELBv2Client, alarms *deploy.AlarmMonitor, logs *deploy.LogTail, service string, cluster string, p deploy.Poller, taskDefinitionARN string) (bool, error) {
+	ctx = logging.With(ctx, logging.Service(service))
 
-	deployCounter := 0
-	deployFinished := false
-	deployFailed := false
+	// The checks of every poll share one description of the service
+	cache := deploy.NewServiceCache(e)
+	events := deploy.NewEventStream(cache, cluster, service)
 
-	deploymentID, err := deploy.UpdateServiceTaskDefinitionVersion(context.TODO(), e, service, cluster, taskDefinitionARN)
+	deploymentID, err := deploy.UpdateServiceTaskDefinitionVersion(ctx, e, service, cluster, taskDefinitionARN)
 
 	if err != nil {
-		log.Println("Error updating task definition for service", err.Error())
+		logging.From(ctx).Error("Error updating task definition for service", logging.Err(err))
 		return true, errors.New("deploy failed")
 	}
 
-	log.Println("Started deployment with ID", deploymentID)
+	ctx = logging.With(ctx, logging.DeploymentID(deploymentID))
+	logging.From(ctx).Info(fmt.Sprintf("Started deployment with ID %v", deploymentID))
+	deployResult.AddDeployment(service, deploymentID)
 
-	for !deployFinished {
-		// Ensure that we haven't hit this limit
-		// We want to rollback quickly
-		if deployCounter > maxDeployChecks {
-			log.Println("Reached max check limit. Will attempt rollback")
-			deployFinished = true
-			deployFailed = true
-			break
+	detector := &deploy.FailureDetector{
+		Client:       cache,
+		Cluster:      cluster,
+		Service:      service,
+		DeploymentID: deploymentID,
+		Since:        events.Since,
+		Threshold:    failFastThreshold,
+	}
+
+	newTD := deployedRevision(ctx, e, taskDefinitionARN)
+
+	err = p.Poll(ctx, "deployment to complete", func(ctx context.Context) (bool, error) {
+		cache.Reset()
+		events.Print(ctx)
+
+		if err := alarms.Check(ctx); err != nil {
+			return true, err
 		}
 
-		log.Println("Waiting for deployment to complete. Check number:", deployCounter)
-		time.Sleep(10 * time.Second)
-		deployCounter++
-
-		deployFinished, err = deploy.CheckDeploymentStatus(
-			context.TODO(),
-			e,
-			service,
-			cluster,
-			deploymentID,
-		)
-
-		if err != nil {
-			log.Println("Deployment failed: ", err.Error())
-			deployFinished = true
-			deployFailed = true
-			break
+		if err := detector.Check(ctx); err != nil {
+			return true, err
 		}
 
+		recordFirstHealthyTask(ctx, cache, lb, service, cluster, newTD)
+
+		return deploy.CheckDeploymentStatus(ctx, cache, service, cluster, deploymentID)
+	})
+
+	if err != nil {
+		// We want to rollback quickly, so a timeout is treated like any other failure
+		logging.From(ctx).Error("Deployment failed", logging.Err(err))
+		recordFailureCategory(err)
+		reportStoppedTasks(ctx, e, logs, p, cluster, service, deploymentID, events.Since)
+		return false, errors.New("deploy failed")
 	}
 
-	if deployFailed {
+	if lb == nil {
+		return true, nil
+	}
+
+	err = p.Poll(ctx, "targets to become healthy", func(ctx context.Context) (bool, error) {
+		cache.Reset()
+		events.Print(ctx)
+
+		if err := alarms.Check(ctx); err != nil {
+			return true, err
+		}
+
+		if err := detector.Check(ctx); err != nil {
+			return true, err
+		}
+
+		recordFirstHealthyTask(ctx, cache, lb, service, cluster, newTD)
+
+		return deploy.CheckTargetsHealthy(ctx, cache, lb, service, cluster, taskDefinitionARN)
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Deployment failed because its targets did not become healthy", logging.Err(err))
+		recordFailureCategory(err)
+		reportStoppedTasks(ctx, e, logs, p, cluster, service, deploymentID, events.Since)
 		return false, errors.New("deploy failed")
 	}
 
+	logging.From(ctx).Info(fmt.Sprintf("All targets of service '%s' are healthy", service))
+
 	return true, nil
 }
 
-func rolling(e types.ECSClient, cluster string, container string, image string, maxDeployChecks int, service string) error {
+func rolling(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, service string) error {
 	services := getServiceNames(service)
 
 	// Retrieve the task definition that the first service is using. The first service may be the only service
 	// In the event that len(services) > 1, we can reasonably assume that all services in the array use the same task definition
 	// That's the entire point of this feature
-	td, err := deploy.GetServiceRunningTaskDefinition(context.TODO(), e, services[0], cluster)
+	td, err := deploy.GetServiceRunningTaskDefinition(ctx, dc.ECS, services[0], dc.Cluster)
 
 	if err != nil {
-		log.Println("Failing because of an error determining the currently in-use task definition:", err.Error())
+		logging.From(ctx).Error("Failing because of an error determining the currently in-use task definition", logging.Err(err))
 		return errors.New("deploy failed")
 	}
 
-	currTD, err := deploy.RetrieveTaskDefinition(context.TODO(), e, td)
+	currTD, err := deploy.RetrieveTaskDefinition(ctx, dc.ECS, td)
 
 	if err != nil {
-		log.Println("Failing because of an error retrieving the currently in-use task definition:", err.Error())
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
 		return errors.New("deploy failed")
 	}
 
-	newTD, err := deploy.CreateNewTaskDefinitionRevision(context.TODO(), e, currTD, container, image)
+	newTD, err := deploy.CreateNewTaskDefinitionRevision(ctx, dc.ECS, currTD, dc.TaskDefinitionChanges())
 
 	if err != nil {
-		log.Println("Failing because of an error retrieving the creating a new task definition revision:", err.Error())
+		logging.From(ctx).Error("Failing because of an error retrieving the creating a new task definition revision", logging.Err(err))
 		return errors.New("deploy failed")
 	}
 
-	log.Println("Created new task definition revision", newTD.Revision)
+	logging.From(ctx).Info(fmt.Sprintf("Created new task definition revision %v", newTD.Revision))
+	deployStarted(currTD, *newTD)
 
-	for _, service := range services {
-		log.Printf("Starting deployment for service '%s'\n", service)
+	showTaskDefinitionDiff(currTD, *newTD)
 
-		deploymentOK, _ := release(e, service, cluster, maxDeployChecks, *newTD.TaskDefinitionArn)
+	if err := runPreDeployTask(ctx, dc.ECS, dc.Cluster, services[0], *newTD.TaskDefinitionArn, p); err != nil {
+		return errors.New("deploy failed")
+	}
+
+	for i, service := range services {
+		logging.From(ctx).Info(fmt.Sprintf("Starting deployment for service '%s'", service))
+
+		deploymentOK, _ := release(ctx, dc.ECS, dc.ELBv2, dc.Alarms, dc.Logs, service, dc.Cluster, p, *newTD.TaskDefinitionArn)
 
 		if !deploymentOK {
 
 			if disableRollbacks {
-				log.Println("Deployment failed but rollbacks are disabled. If the service has ECS Circuit Breaker enabled, the circuit breaker should handle rolling back.")
+				logging.From(ctx).Error("Deployment failed but rollbacks are disabled. If the service has ECS Circuit Breaker enabled, the circuit breaker should handle rolling back.")
 				return errors.New("deploy failed")
 			} else {
-				log.Println("Rolling back failed deployment for service", service)
-				rollbackOK, _ := release(e, service, cluster, maxDeployChecks, *currTD.TaskDefinitionArn)
-
-				if !rollbackOK {
-					log.Println("Error rolling back")
-				}
+				// Services released before this one are rolled back too, since an alarm may have fired because of them
+				rollbackRelease(ctx, dc, p, *currTD.TaskDefinitionArn, services[:i+1])
 				return errors.New("deploy failed")
 			}
 		}
 
-		log.Printf("Deployment succeeded for service '%s'\n", service)
+		logging.From(ctx).Info(fmt.Sprintf("Deployment succeeded for service '%s'", service))
 
 	}
 
+	if dc.BakePeriod > 0 {
+		logging.From(ctx).Info(fmt.Sprintf("Watching alarms for %v before the deployment succeeds", dc.BakePeriod))
+
+		if err := dc.Alarms.Watch(ctx, p.Interval, dc.BakePeriod); err != nil {
+			logging.From(ctx).Error("Deployment failed during the bake period", logging.Err(err))
+
+			if disableRollbacks {
+				logging.From(ctx).Info("Rollbacks are disabled, so the services are left running the new task definition revision")
+				return errors.New("deploy failed")
+			}
+
+			rollbackRelease(ctx, dc, p, *currTD.TaskDefinitionArn, services)
+			return errors.New("deploy failed")
+		}
+
+		logging.From(ctx).Info("Bake period finished without any alarm firing")
+	}
+
 	return nil
 }
+
+// rollbackRelease releases each service back to taskDefinitionARN after a failed deployment
+func rollbackRelease(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, taskDefinitionARN string, services []string) {
+	ctx = rollbackStarted(ctx)
+
+	ok := true
+
+	for _, service := range services {
+		logging.From(ctx).Error(fmt.Sprintf("Rolling back failed deployment for service %v", service))
+
+		// The rollback must run even if the deploy deadline has passed. Alarms are not watched because they are likely still firing
+		rollbackCtx, cancel := p.CleanupContext(ctx)
+		rollbackOK, _ := release(rollbackCtx, dc.ECS, dc.ELBv2, nil, dc.Logs, service, dc.Cluster, p, taskDefinitionARN)
+		cancel()
+
+		if !rollbackOK {
+			logging.From(ctx).Error("Error rolling back")
+			ok = false
+		}
+	}
+
+	rollbackFinished(ctx, ok)
+}
+
+// reportStoppedTasks reports the stopped tasks of a failed deployment. It runs even if the deploy deadline has passed
+func reportStoppedTasks(ctx context.Context, e types.ECSClient, logs *deploy.LogTail, p deploy.Poller, cluster string, service string, deploymentID string, since time.Time) {
+	ctx, cancel := p.CleanupContext(ctx)
+	defer cancel()
+
+	deploy.ReportStoppedTasks(ctx, e, logs, cluster, service, deploymentID, since)
+}
+
+// recordFailureCategory keeps the category of a classified deployment failure for the deploy result and notifications
+func recordFailureCategory(err error) {
+	var failure *deploy.DeploymentFailure
+
+	if errors.As(err, &failure) {
+		deployResult.SetFailureCategory(failure.Category)
+	}
+}
+
+// deployedRevision returns the task definition of taskDefinitionARN if it is the deploy's new revision
+// Deployments of other revisions, such as rollbacks, have no first healthy task to record
+func deployedRevision(ctx context.Context, e types.ECSClient, taskDefinitionARN string) *ecstypes.TaskDefinition {
+	if deployResult == nil || deployResult.TaskDefinition == nil || deployResult.TaskDefinition.ARN != taskDefinitionARN {
+		return nil
+	}
+
+	td, err := deploy.RetrieveTaskDefinition(ctx, e, taskDefinitionARN)
+
+	if err != nil {
+		logging.From(ctx).Warn("Could not retrieve the new task definition. The first healthy task will not be recorded", logging.Err(err))
+		return nil
+	}
+
+	return &td
+}
+
+// recordFirstHealthyTask records when the first task of td is healthy in its container health checks and, if lb is set, its target groups
+// Nothing is recorded if td is nil
+func recordFirstHealthyTask(ctx context.Context, e types.ECSClient, lb types.ELBv2Client, service string, cluster string, td *ecstypes.TaskDefinition) {
+	if deployResult == nil || td == nil {
+		return
+	}
+
+	if _, ok := deployResult.FirstHealthyTaskSeconds[service]; ok {
+		return
+	}
+
+	if healthy, err := deploy.HasHealthyTask(ctx, e, lb, service, cluster, *td); err == nil && healthy {
+		deployResult.SetFirstHealthyTask(service, time.Now())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func Test_rollingAlarms(t *testing.T) {
+	tests := []struct {
+		name        string
+		alarmState  cwtypes.StateValue
+		bakePeriod  time.Duration
+		wantUpdated []string
+		wantErr     bool
+	}{
+		{
+			name:        "alarm-ok",
+			alarmState:  cwtypes.StateValueOk,
+			bakePeriod:  10 * time.Millisecond,
+			wantUpdated: []string{"test-service"},
+		},
+		{
+			name:        "alarm-fires-during-rollout",
+			alarmState:  cwtypes.StateValueAlarm,
+			wantUpdated: []string{"test-service", "test-service"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated []string
+
+			dc := deploy.DeployConfig{
+				ECS: deploy.MockECSClient{
+					TestingT:        t,
+					DeploymentState: ecstypes.DeploymentRolloutStateCompleted,
+					TaskDefinition: &ecstypes.TaskDefinition{
+						TaskDefinitionArn:    aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"),
+						ContainerDefinitions: []ecstypes.ContainerDefinition{{Name: aws.String("app"), Image: aws.String("some/image:1.0")}},
+					},
+					UpdatedServices: &updated,
+				},
+				Cluster:   "test-cluster",
+				Container: "app",
+				Image:     "some/image:2.0",
+				Alarms: &deploy.AlarmMonitor{
+					Client: deploy.MockCloudWatchClient{AlarmStates: map[string]cwtypes.StateValue{"webapp-5xx": tt.alarmState}},
+					Alarms: []string{"webapp-5xx"},
+				},
+				BakePeriod: tt.bakePeriod,
+			}
+
+			err := rolling(context.TODO(), dc, testPoller(3), "test-service")
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rolling() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.DeepEqual(t, tt.wantUpdated, updated)
+		})
+	}
+}
+
+func Test_releaseFailFast(t *testing.T) {
+	defer func(threshold int) { failFastThreshold = threshold }(failFastThreshold)
+	failFastThreshold = 1
+
+	e := deploy.MockECSClient{
+		TestingT:        t,
+		DeploymentState: ecstypes.DeploymentRolloutStateInProgress,
+		ServiceEvents: []ecstypes.ServiceEvent{
+			{
+				Id:        aws.String("1"),
+				CreatedAt: aws.Time(time.Now().Add(time.Minute)),
+				Message:   aws.String("(service test-service) was unable to place a task because no container instance met all of its requirements."),
+			},
+		},
+		Tasks: []ecstypes.Task{},
+	}
+
+	// Without failing fast the deployment would only stop when ctx is done
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	ok, err := release(ctx, e, nil, nil, nil, "test-service", "test-cluster", testPoller(-1), "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1")
+
+	assert.Assert(t, !ok)
+	assert.Error(t, err, "deploy failed")
+	assert.Assert(t, time.Since(start) < time.Second, "release took %s", time.Since(start))
+}
+
+// failingServiceECSClient is a mock ECS client whose deployments of the failing service fail
+// Every service is described as the mock test-service
+type failingServiceECSClient struct {
+	deploy.MockECSClient
+	failing string
+}
+
+func (c failingServiceECSClient) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	m := c.MockECSClient
+
+	if params.Services[0] == c.failing {
+		m.DeploymentState = ecstypes.DeploymentRolloutStateFailed
+	}
+
+	return m.DescribeServices(ctx, &ecs.DescribeServicesInput{Cluster: params.Cluster, Services: []string{"test-service"}}, optFns...)
+}
+
+func Test_rollingRollsBackReleasedServices(t *testing.T) {
+	var updated []string
+
+	dc := deploy.DeployConfig{
+		ECS: failingServiceECSClient{
+			MockECSClient: deploy.MockECSClient{
+				TestingT:        t,
+				DeploymentState: ecstypes.DeploymentRolloutStateCompleted,
+				TaskDefinition: &ecstypes.TaskDefinition{
+					TaskDefinitionArn:    aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"),
+					ContainerDefinitions: []ecstypes.ContainerDefinition{{Name: aws.String("app"), Image: aws.String("some/image:1.0")}},
+				},
+				UpdatedServices: &updated,
+			},
+			failing: "worker",
+		},
+		Cluster:   "test-cluster",
+		Container: "app",
+		Image:     "some/image:2.0",
+	}
+
+	err := rolling(context.TODO(), dc, testPoller(3), "webapp,worker,cron")
+
+	// cron is never released, and webapp is rolled back with worker
+	assert.ErrorContains(t, err, "deploy failed")
+	assert.DeepEqual(t, []string{"webapp", "worker", "webapp", "worker"}, updated)
+}
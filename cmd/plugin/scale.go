@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+)
+
+// scale sets the desired count of a service and waits for it to run that many tasks
+// If the service uses Application Auto Scaling, its min and max are widened to include count so the scalable target does not undo it
+func scale(ctx context.Context, dc deploy.DeployConfig, p deploy.Poller, service string, count int32) error {
+	ctx = logging.With(ctx, logging.Service(service))
+
+	current, err := deploy.GetServiceDesiredCount(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Could not get desired count of service '%s'", service), logging.Err(err))
+		return errors.New("scale failed")
+	}
+
+	usesAutoscaling, err := deploy.AppAutoscalingTargetExists(ctx, dc.AppAutoscaling, dc.Cluster, service)
+
+	if err != nil {
+		logging.From(ctx).Error("Error checking if the service uses Application Auto Scaling", logging.Err(err))
+		return errors.New("scale failed")
+	}
+
+	// A max count of -1 only sets the desired count of the service
+	minCount, maxCount := int32(0), int32(-1)
+
+	if usesAutoscaling {
+		maxCount, minCount, err = deploy.GetServiceMinMaxCount(ctx, dc.AppAutoscaling, dc.Cluster, service)
+
+		if err != nil {
+			logging.From(ctx).Error("Error getting the Application Auto Scaling min and max of the service", logging.Err(err))
+			return errors.New("scale failed")
+		}
+
+		if count < minCount || count > maxCount {
+			if count < minCount {
+				minCount = count
+			} else {
+				maxCount = count
+			}
+
+			logging.From(ctx).Warn(fmt.Sprintf("Count %d is outside the autoscaling range of service '%s'. The range will be changed to min %d and max %d", count, service, minCount, maxCount))
+		}
+	}
+
+	if dryRun {
+		logging.From(ctx).Info(fmt.Sprintf("Plan: service '%s' would be scaled from %d to %d tasks", service, current, count))
+		return nil
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Scaling service '%s' from %d to %d tasks", service, current, count))
+
+	if err := dc.ScaleUp(ctx, count, minCount, maxCount, service); err != nil {
+		logging.From(ctx).Error("Error setting the desired count of the service", logging.Err(err))
+		return errors.New("scale failed")
+	}
+
+	err = p.Poll(ctx, "service to scale", func(ctx context.Context) (bool, error) {
+		return dc.GreenScaleUpFinished(ctx, service)
+	})
+
+	if err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Service '%s' did not finish scaling", service), logging.Err(err))
+		return errors.New("scale failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Service '%s' is running %d tasks", service, count))
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"gotest.tools/assert"
+)
+
+func Test_scale(t *testing.T) {
+	tests := []struct {
+		name           string
+		runningCount   int32
+		targetExists   bool
+		autoscalingErr bool
+		dryRun         bool
+		wantUpdated    []string
+		wantErr        bool
+	}{
+		{
+			name:         "scaled",
+			runningCount: 2,
+			wantUpdated:  []string{"test-service"},
+		},
+		{
+			name:         "autoscaling",
+			runningCount: 2,
+			targetExists: true,
+			wantUpdated:  []string{"test-service"},
+		},
+		{
+			name:         "dry-run",
+			runningCount: 2,
+			dryRun:       true,
+		},
+		{
+			name:           "autoscaling-error",
+			autoscalingErr: true,
+			wantErr:        true,
+		},
+		{
+			name:         "never-scales",
+			runningCount: 1,
+			wantUpdated:  []string{"test-service"},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dryRun = tt.dryRun
+			defer func() { dryRun = false }()
+
+			var updated []string
+
+			dc := deploy.DeployConfig{
+				ECS: deploy.MockECSClient{
+					TestingT:        t,
+					RunningCount:    tt.runningCount,
+					UpdatedServices: &updated,
+				},
+				AppAutoscaling: deploy.MockAppAutoscalingClient{
+					TestingT:     t,
+					TargetExists: tt.targetExists,
+					WantError:    tt.autoscalingErr,
+				},
+				Cluster: "test-cluster",
+			}
+
+			err := scale(context.TODO(), dc, testPoller(3), "test-service", 2)
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.DeepEqual(t, tt.wantUpdated, updated)
+		})
+	}
+}
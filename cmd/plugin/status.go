@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// errNotSteady is returned by status when a service is still deploying or is not running its desired count
+var errNotSteady = errors.New("services are not steady")
+
+// status logs the state of every service and its deployments
+// It does not change anything. It returns errNotSteady if a service has more than one deployment or its tasks are not all running
+func status(ctx context.Context, e types.ECSClient, cluster string, services []string) error {
+	out, err := e.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(cluster),
+		Services: services,
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing services", logging.Err(err))
+		return errors.New("status failed")
+	}
+
+	if len(out.Failures) > 0 {
+		for _, f := range out.Failures {
+			logging.From(ctx).Error(fmt.Sprintf("Service '%s' could not be described: %s", aws.ToString(f.Arn), aws.ToString(f.Reason)))
+		}
+
+		return errors.New("status failed")
+	}
+
+	steady := true
+
+	for _, s := range out.Services {
+		name := aws.ToString(s.ServiceName)
+		ctx := logging.With(ctx, logging.Service(name))
+
+		logging.From(ctx).Info(fmt.Sprintf("Service '%s' is %s with task definition '%s'", name, aws.ToString(s.Status), aws.ToString(s.TaskDefinition)))
+
+		for _, d := range s.Deployments {
+			logging.From(ctx).Info(
+				fmt.Sprintf("Deployment %s is %s %s with task definition '%s': %d desired, %d running, %d pending and %d failed tasks",
+					aws.ToString(d.Id), aws.ToString(d.Status), d.RolloutState, aws.ToString(d.TaskDefinition), d.DesiredCount, d.RunningCount, d.PendingCount, d.FailedTasks),
+				logging.DeploymentID(aws.ToString(d.Id)),
+			)
+		}
+
+		// A service is steady once it has a single deployment that runs every task it desires
+		if aws.ToString(s.Status) != "ACTIVE" || len(s.Deployments) != 1 || s.Deployments[0].RunningCount != s.Deployments[0].DesiredCount {
+			logging.From(ctx).Warn(fmt.Sprintf("Service '%s' is not steady", name))
+			steady = false
+			continue
+		}
+
+		logging.From(ctx).Info(fmt.Sprintf("Service '%s' is steady", name))
+	}
+
+	if !steady {
+		return errNotSteady
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func Test_status(t *testing.T) {
+	tests := []struct {
+		name         string
+		runningCount int32
+		wantErr      error
+	}{
+		{
+			name:         "steady",
+			runningCount: 2,
+		},
+		{
+			name:         "not-steady",
+			runningCount: 1,
+			wantErr:      errNotSteady,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := deploy.MockECSClient{
+				TestingT:        t,
+				DeploymentState: ecstypes.DeploymentRolloutStateCompleted,
+				RunningCount:    tt.runningCount,
+			}
+
+			err := status(context.TODO(), e, "test-cluster", []string{"test-service"})
+
+			if tt.wantErr == nil {
+				assert.NilError(t, err)
+				return
+			}
+
+			assert.Assert(t, errors.Is(err, tt.wantErr))
+			assert.Equal(t, exitNotSteady, exitCode(err))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+)
+
+const (
+	defaultTaskSetInitialPercent = 10
+)
+
+// taskSet deploys a service that uses the EXTERNAL deployment controller
+// A new task set is created next to the primary task set, scaled up, promoted and the old task set is deleted
+// If anything fails before promotion, the new task set is deleted and the primary task set is left untouched
+func taskSet(ctx context.Context, dc deploy.DeployConfig, service string, initialPercent float64, p deploy.Poller) error {
+	ctx = logging.With(ctx, logging.Service(service))
+	logging.From(ctx).Info("Beginning task set deployment")
+
+	primary, err := deploy.GetPrimaryTaskSet(ctx, dc.ECS, service, dc.Cluster)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error determining the primary task set", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Primary task set of service '%s' is '%s'", service, *primary.Id))
+
+	currTD, err := deploy.RetrieveTaskDefinition(ctx, dc.ECS, *primary.TaskDefinition)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the currently in-use task definition", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	newTD, err := deploy.CreateNewTaskDefinitionRevision(ctx, dc.ECS, currTD, dc.TaskDefinitionChanges())
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error retrieving the creating a new task definition revision", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Created new task definition revision %v", newTD.Revision))
+	deployStarted(currTD, *newTD)
+
+	showTaskDefinitionDiff(currTD, *newTD)
+
+	if err := runTaskSetPreDeployTask(ctx, dc.ECS, dc.Cluster, *primary, *newTD.TaskDefinitionArn, p); err != nil {
+		return errors.New("deploy failed")
+	}
+
+	newSet, err := deploy.CreateTaskSet(ctx, dc.ECS, service, dc.Cluster, *primary, *newTD.TaskDefinitionArn, initialPercent)
+
+	if err != nil {
+		logging.From(ctx).Error("Failing because of an error creating the new task set", logging.Err(err))
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Created task set '%s' at %.0f percent", *newSet.Id, initialPercent))
+	deployResult.AddDeployment(service, *newSet.Id)
+
+	if err := waitForTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, p); err != nil {
+		removeTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, p)
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Scaling task set '%s' to 100 percent", *newSet.Id))
+
+	if err := deploy.ScaleTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, 100); err != nil {
+		removeTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, p)
+		return errors.New("deploy failed")
+	}
+
+	if err := waitForTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, p); err != nil {
+		removeTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, p)
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Promoting task set '%s' to primary", *newSet.Id))
+
+	if err := deploy.PromoteTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id); err != nil {
+		removeTaskSet(ctx, dc.ECS, service, dc.Cluster, *newSet.Id, p)
+		return errors.New("deploy failed")
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Deleting previous primary task set '%s'", *primary.Id))
+
+	// The new task set is already serving traffic so a failure here does not fail the deployment
+	if err := deploy.DeleteTaskSet(ctx, dc.ECS, service, dc.Cluster, *primary.Id, true); err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Unable to delete previous task set '%s'. It must be deleted manually", *primary.Id))
+	}
+
+	logging.From(ctx).Info(fmt.Sprintf("Deployment succeeded for service '%s'", service))
+
+	return nil
+}
+
+// waitForTaskSet polls a task set until it reaches a steady state or the phase times out
+func waitForTaskSet(ctx context.Context, e types.ECSClient, service string, cluster string, taskSetID string, p deploy.Poller) error {
+	err := p.Poll(ctx, fmt.Sprintf("task set '%s' to reach a steady state", taskSetID), func(ctx context.Context) (bool, error) {
+		return deploy.CheckTaskSetSteadyState(ctx, e, service, cluster, taskSetID)
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Task set failed", logging.Err(err))
+	}
+
+	return err
+}
+
+// removeTaskSet deletes a task set that failed to deploy
+func removeTaskSet(ctx context.Context, e types.ECSClient, service string, cluster string, taskSetID string, p deploy.Poller) {
+	logging.From(ctx).Error(fmt.Sprintf("Deleting failed task set '%s'. The primary task set was not modified", taskSetID))
+	ctx = rollbackStarted(ctx)
+
+	// The task set must be removed even if the deploy deadline has passed
+	ctx, cancel := p.CleanupContext(ctx)
+	defer cancel()
+
+	err := deploy.DeleteTaskSet(ctx, e, service, cluster, taskSetID, true)
+
+	if err != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Unable to delete failed task set '%s'. It must be deleted manually", taskSetID))
+	}
+
+	rollbackFinished(ctx, err == nil)
+}
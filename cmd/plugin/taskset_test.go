@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func Test_taskSet(t *testing.T) {
+	tests := []struct {
+		name            string
+		stability       ecstypes.StabilityStatus
+		maxDeployChecks int
+		wantCalls       []string
+		wantErr         bool
+	}{
+		{
+			name:            "test-success",
+			stability:       ecstypes.StabilityStatusSteadyState,
+			maxDeployChecks: 3,
+			wantCalls: []string{
+				"CreateTaskSet:ts-new",
+				"UpdateTaskSet:ts-new",
+				"UpdateServicePrimaryTaskSet:ts-new",
+				"DeleteTaskSet:ts-primary",
+			},
+			wantErr: false,
+		},
+		{
+			name:            "test-never-stable",
+			stability:       ecstypes.StabilityStatusStabilizing,
+			maxDeployChecks: 0,
+			wantCalls: []string{
+				"CreateTaskSet:ts-new",
+				"DeleteTaskSet:ts-new",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []string{}
+
+			dc := deploy.DeployConfig{
+				ECS: deploy.MockECSClient{
+					TestingT:               t,
+					TaskSetStabilityStatus: tt.stability,
+					TaskSetCalls:           &calls,
+					TaskSets: []ecstypes.TaskSet{
+						{Id: aws.String("ts-primary"), Status: aws.String("PRIMARY"), TaskDefinition: aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1")},
+					},
+				},
+				Cluster:   "test-cluster",
+				Container: "app",
+				Image:     "foo/app:3",
+			}
+
+			err := taskSet(context.Background(), dc, "test-service", 10, testPoller(tt.maxDeployChecks))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("taskSet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			assert.DeepEqual(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func Test_taskSetPreDeployTask(t *testing.T) {
+	preDeployContainer = "app"
+
+	defer func() {
+		preDeployContainer = ""
+	}()
+
+	network := &ecstypes.NetworkConfiguration{
+		AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{Subnets: []string{"subnet-1"}},
+	}
+
+	calls := []string{}
+	runTasks := []*ecs.RunTaskInput{}
+
+	dc := deploy.DeployConfig{
+		ECS: deploy.MockECSClient{
+			TestingT:               t,
+			TaskSetStabilityStatus: ecstypes.StabilityStatusSteadyState,
+			TaskSetCalls:           &calls,
+			RunTasks:               &runTasks,
+			TaskSets: []ecstypes.TaskSet{
+				{
+					Id:                   aws.String("ts-primary"),
+					Status:               aws.String("PRIMARY"),
+					TaskDefinition:       aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"),
+					NetworkConfiguration: network,
+					LaunchType:           ecstypes.LaunchTypeFargate,
+				},
+			},
+			Tasks: []ecstypes.Task{
+				{
+					TaskArn:    aws.String("arn-run-task"),
+					LastStatus: aws.String("STOPPED"),
+					Containers: []ecstypes.Container{{Name: aws.String("app"), ExitCode: aws.Int32(1)}},
+				},
+			},
+		},
+		Cluster:   "test-cluster",
+		Container: "app",
+		Image:     "foo/app:3",
+	}
+
+	err := taskSet(context.Background(), dc, "test-service", 10, testPoller(3))
+
+	// The task runs with the settings of the primary task set, and no task set is created when it fails
+	assert.ErrorContains(t, err, "deploy failed")
+	assert.Equal(t, 1, len(runTasks))
+	assert.Equal(t, network, runTasks[0].NetworkConfiguration)
+	assert.Equal(t, ecstypes.LaunchTypeFargate, runTasks[0].LaunchType)
+	assert.DeepEqual(t, []string{}, calls)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
+)
+
+// tracingServiceName is the service.name of every trace
+const tracingServiceName = "drone-deploy-ecs"
+
+var (
+	// tracer records the deploy as a trace if otlp_endpoint is set. It is nil otherwise
+	tracer *tracing.Tracer
+	// deploySpan is the root span of the deploy. It is ended by finish
+	deploySpan *tracing.Span
+)
+
+// newTracer returns a tracer that exports to otlp_endpoint, or nil if it is not set
+func newTracer(c Config) *tracing.Tracer {
+	if c.OTLPEndpoint == "" {
+		return nil
+	}
+
+	return tracing.NewTracer(tracing.OTLPExporter{
+		Endpoint: c.OTLPEndpoint,
+		Headers:  c.OTLPHeaders,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}, tracingServiceName)
+}
+
+// startTrace starts the root span of the deploy and logs its trace ID
+func startTrace(ctx context.Context, mode string, cluster string, services []string) context.Context {
+	if tracer == nil {
+		return ctx
+	}
+
+	ctx, deploySpan = tracing.Start(tracing.WithTracer(ctx, tracer), "deploy",
+		tracing.String("deploy.mode", mode),
+		tracing.String("ecs.cluster", cluster),
+		tracing.String("ecs.services", strings.Join(services, ",")),
+		tracing.Bool("deploy.dry_run", dryRun),
+		tracing.String("drone.build_link", os.Getenv("DRONE_BUILD_LINK")),
+	)
+
+	logging.From(ctx).Info(fmt.Sprintf("Trace ID: %v", deploySpan.TraceIDString()))
+
+	return ctx
+}
+
+// endTrace ends the root span of the deploy and exports every span. Export errors are logged and never fail the deploy
+func endTrace(err error) {
+	if tracer == nil {
+		return
+	}
+
+	deploySpan.End(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := tracer.Flush(ctx); err != nil {
+		logging.From(ctx).Error("Error exporting trace", logging.Err(err))
+	}
+}
@@ -0,0 +1,157 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// ErrAlarm is returned when a watched CloudWatch alarm is in the ALARM state
+var ErrAlarm = errors.New("alarm triggered")
+
+// AlarmMonitor watches CloudWatch alarms during and after a rollout
+// A nil AlarmMonitor watches nothing
+type AlarmMonitor struct {
+	Client types.CloudWatchClient
+	// Alarms are alarm names. A name ending in '*' matches every alarm that starts with it
+	Alarms []string
+}
+
+// Check returns an error wrapping ErrAlarm if any watched alarm is in the ALARM state
+// The state of every watched alarm is logged
+func (m *AlarmMonitor) Check(ctx context.Context) error {
+	if m == nil || len(m.Alarms) == 0 {
+		return nil
+	}
+
+	alarms, err := m.describeAlarms(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	var firing []string
+
+	for _, alarm := range alarms {
+		logging.From(ctx).Info(fmt.Sprintf("Alarm '%s' is %s", aws.ToString(alarm.AlarmName), alarm.StateValue), logging.F("alarm", aws.ToString(alarm.AlarmName)))
+
+		if alarm.StateValue == cwtypes.StateValueAlarm {
+			logging.From(ctx).Warn(fmt.Sprintf("Alarm '%s' reason: %s", aws.ToString(alarm.AlarmName), aws.ToString(alarm.StateReason)), logging.F("alarm", aws.ToString(alarm.AlarmName)))
+			firing = append(firing, aws.ToString(alarm.AlarmName))
+		}
+	}
+
+	if len(firing) > 0 {
+		sort.Strings(firing)
+		return fmt.Errorf("%w: %s", ErrAlarm, strings.Join(firing, ", "))
+	}
+
+	return nil
+}
+
+// Watch waits for d while checking the alarms every interval
+// It returns early with an error wrapping ErrAlarm if an alarm fires, or the context error if ctx is done
+func (m *AlarmMonitor) Watch(ctx context.Context, interval time.Duration, d time.Duration) error {
+	if m == nil || len(m.Alarms) == 0 {
+		return Sleep(ctx, d)
+	}
+
+	deadline := time.Now().Add(d)
+
+	for {
+		if err := m.Check(ctx); err != nil {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+
+		if remaining <= 0 {
+			return nil
+		}
+
+		if remaining > interval {
+			remaining = interval
+		}
+
+		if err := Sleep(ctx, remaining); err != nil {
+			return err
+		}
+	}
+}
+
+// describeAlarms returns every metric and composite alarm that matches Alarms
+func (m *AlarmMonitor) describeAlarms(ctx context.Context) ([]cwtypes.MetricAlarm, error) {
+	var names []string
+	var inputs []*cloudwatch.DescribeAlarmsInput
+
+	for _, alarm := range m.Alarms {
+		if strings.HasSuffix(alarm, "*") {
+			inputs = append(inputs, &cloudwatch.DescribeAlarmsInput{AlarmNamePrefix: aws.String(strings.TrimSuffix(alarm, "*"))})
+		} else {
+			names = append(names, alarm)
+		}
+	}
+
+	// DescribeAlarms accepts up to 100 names per call
+	for i := 0; i < len(names); i += 100 {
+		end := i + 100
+
+		if end > len(names) {
+			end = len(names)
+		}
+
+		inputs = append(inputs, &cloudwatch.DescribeAlarmsInput{AlarmNames: names[i:end]})
+	}
+
+	var alarms []cwtypes.MetricAlarm
+	seen := map[string]bool{}
+
+	for _, i := range inputs {
+		i.AlarmTypes = []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm, cwtypes.AlarmTypeCompositeAlarm}
+
+		for {
+			out, err := m.Client.DescribeAlarms(ctx, i)
+
+			if err != nil {
+				logging.From(ctx).Error("Error describing alarms", logging.Err(err))
+				return nil, err
+			}
+
+			for _, alarm := range out.MetricAlarms {
+				if !seen[aws.ToString(alarm.AlarmName)] {
+					seen[aws.ToString(alarm.AlarmName)] = true
+					alarms = append(alarms, alarm)
+				}
+			}
+
+			// Composite alarms are reported with the same fields that are logged for metric alarms
+			for _, alarm := range out.CompositeAlarms {
+				if !seen[aws.ToString(alarm.AlarmName)] {
+					seen[aws.ToString(alarm.AlarmName)] = true
+					alarms = append(alarms, cwtypes.MetricAlarm{
+						AlarmName:   alarm.AlarmName,
+						StateValue:  alarm.StateValue,
+						StateReason: alarm.StateReason,
+					})
+				}
+			}
+
+			if out.NextToken == nil {
+				break
+			}
+
+			i.NextToken = out.NextToken
+		}
+	}
+
+	return alarms, nil
+}
@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"gotest.tools/assert"
+)
+
+func TestAlarmMonitorCheck(t *testing.T) {
+	states := map[string]cwtypes.StateValue{
+		"webapp-5xx":     cwtypes.StateValueOk,
+		"webapp-latency": cwtypes.StateValueAlarm,
+		"worker-queue":   cwtypes.StateValueAlarm,
+	}
+
+	tests := []struct {
+		name    string
+		alarms  []string
+		wantErr string
+	}{
+		{
+			name:   "ok",
+			alarms: []string{"webapp-5xx"},
+		},
+		{
+			name:    "alarm",
+			alarms:  []string{"webapp-5xx", "worker-queue"},
+			wantErr: "alarm triggered: worker-queue",
+		},
+		{
+			name:    "prefix",
+			alarms:  []string{"webapp-*"},
+			wantErr: "alarm triggered: webapp-latency",
+		},
+		{
+			name:    "prefix-and-name",
+			alarms:  []string{"webapp-*", "worker-queue", "webapp-latency"},
+			wantErr: "alarm triggered: webapp-latency, worker-queue",
+		},
+		{
+			name: "no-alarms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &AlarmMonitor{Client: MockCloudWatchClient{AlarmStates: states}, Alarms: tt.alarms}
+
+			err := m.Check(context.TODO())
+
+			if tt.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+
+			assert.Error(t, err, tt.wantErr)
+			assert.Assert(t, errors.Is(err, ErrAlarm))
+		})
+	}
+}
+
+func TestAlarmMonitorWatch(t *testing.T) {
+	var nilMonitor *AlarmMonitor
+
+	assert.NilError(t, nilMonitor.Check(context.TODO()))
+	assert.NilError(t, nilMonitor.Watch(context.TODO(), time.Millisecond, time.Millisecond))
+
+	checks := 0
+	m := &AlarmMonitor{
+		Client: MockCloudWatchClient{AlarmStates: map[string]cwtypes.StateValue{"webapp-5xx": cwtypes.StateValueOk}, Checks: &checks},
+		Alarms: []string{"webapp-5xx"},
+	}
+
+	assert.NilError(t, m.Watch(context.TODO(), 5*time.Millisecond, 20*time.Millisecond))
+	assert.Assert(t, checks >= 3, "alarms were checked %d times", checks)
+
+	checks = 0
+	m.Client = MockCloudWatchClient{AlarmStates: map[string]cwtypes.StateValue{"webapp-5xx": cwtypes.StateValueAlarm}, Checks: &checks}
+
+	err := m.Watch(context.TODO(), time.Millisecond, time.Hour)
+
+	assert.Assert(t, errors.Is(err, ErrAlarm))
+	assert.Equal(t, 1, checks)
+}
@@ -0,0 +1,233 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// FailureCategory is a known reason a deployment can never succeed
+type FailureCategory string
+
+const (
+	FailurePlacement              FailureCategory = "placement"
+	FailureImagePull              FailureCategory = "image pull"
+	FailureTaskRole               FailureCategory = "task role"
+	FailureEssentialContainerExit FailureCategory = "essential container exit"
+	FailureHealthCheck            FailureCategory = "health check"
+)
+
+// DeploymentFailure is returned when a deployment is classified as failed
+type DeploymentFailure struct {
+	Category FailureCategory
+	// Reason is the service event or stopped task reason that was classified
+	Reason string
+}
+
+func (f *DeploymentFailure) Error() string {
+	return fmt.Sprintf("deployment failed (%s): %s", f.Category, f.Reason)
+}
+
+// eventPatterns classify service event messages. They are matched in order, case insensitive
+var eventPatterns = []struct {
+	contains string
+	category FailureCategory
+}{
+	{"unable to place a task", FailurePlacement},
+	{"unable to assume the role", FailureTaskRole},
+	{"failed elb health checks", FailureHealthCheck},
+	{"failed container health checks", FailureHealthCheck},
+	{"is unhealthy in (target-group", FailureHealthCheck},
+}
+
+// taskPatterns classify the stopped reason of a task and the reasons of its containers. They are matched in order, case insensitive
+var taskPatterns = []struct {
+	contains string
+	category FailureCategory
+}{
+	{"cannotpullcontainererror", FailureImagePull},
+	{"pull image", FailureImagePull},
+	{"pulling image", FailureImagePull},
+	{"unable to assume", FailureTaskRole},
+	{"health checks", FailureHealthCheck},
+	{"essential container in task exited", FailureEssentialContainerExit},
+}
+
+// ClassifyEvent returns the failure category of a service event message, if it has one
+func ClassifyEvent(message string) (FailureCategory, bool) {
+	lower := strings.ToLower(message)
+
+	for _, p := range eventPatterns {
+		if strings.Contains(lower, p.contains) {
+			return p.category, true
+		}
+	}
+
+	return "", false
+}
+
+// ClassifyStoppedTask returns the failure category of a stopped task and a reason that includes the exit code of every failed container
+func ClassifyStoppedTask(task ecstypes.Task) (FailureCategory, string, bool) {
+	reasons := []string{aws.ToString(task.StoppedReason)}
+
+	for _, container := range task.Containers {
+		if container.Reason != nil {
+			reasons = append(reasons, fmt.Sprintf("container '%s': %s", aws.ToString(container.Name), *container.Reason))
+		}
+
+		if container.ExitCode != nil && *container.ExitCode != 0 {
+			reasons = append(reasons, fmt.Sprintf("container '%s' exited with code %d", aws.ToString(container.Name), *container.ExitCode))
+		}
+	}
+
+	reason := strings.Join(reasons, "; ")
+	lower := strings.ToLower(reason)
+
+	for _, p := range taskPatterns {
+		if strings.Contains(lower, p.contains) {
+			return p.category, reason, true
+		}
+	}
+
+	return "", reason, false
+}
+
+// FailureDetector classifies the service events and stopped tasks of a deployment
+// It fails a deployment once Threshold events or stopped tasks of the same category are seen, instead of waiting for the deployment to time out
+// A nil FailureDetector, or one with a Threshold below 1, detects nothing
+type FailureDetector struct {
+	Client  types.ECSClient
+	Cluster string
+	Service string
+	// DeploymentID limits stopped tasks to the tasks of one deployment. Otherwise every task of Service created after Since is checked
+	DeploymentID string
+	// Since is when the deployment started. Older events and tasks are ignored
+	Since     time.Time
+	Threshold int
+
+	counts map[FailureCategory]int
+	seen   map[string]bool
+}
+
+// Check returns a *DeploymentFailure once a category of failure has been seen Threshold times
+func (d *FailureDetector) Check(ctx context.Context) error {
+	if d == nil || d.Threshold < 1 {
+		return nil
+	}
+
+	if d.counts == nil {
+		d.counts = map[FailureCategory]int{}
+		d.seen = map[string]bool{}
+	}
+
+	if failure := d.checkEvents(ctx); failure != nil {
+		return failure
+	}
+
+	return d.checkStoppedTasks(ctx)
+}
+
+func (d *FailureDetector) checkEvents(ctx context.Context) error {
+	out, err := d.Client.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(d.Cluster),
+		Services: []string{d.Service},
+	})
+
+	// Classifying is best effort. The deployment is still checked by its status
+	if err != nil || len(out.Services) == 0 {
+		return nil
+	}
+
+	// ECS scales the capacity providers of a service out for tasks it can not place yet, so placement failures are expected until it has
+	scalingOut := len(out.Services[0].CapacityProviderStrategy) > 0
+
+	for _, event := range out.Services[0].Events {
+		id := aws.ToString(event.Id)
+
+		if d.seen[id] || event.CreatedAt == nil || event.CreatedAt.Before(d.Since) {
+			continue
+		}
+
+		d.seen[id] = true
+
+		if category, ok := ClassifyEvent(aws.ToString(event.Message)); ok {
+			if category == FailurePlacement && scalingOut {
+				logging.From(ctx).Debug("Not counting a placement failure while the capacity providers of the service scale out", logging.Service(d.Service))
+				continue
+			}
+
+			if failure := d.record(ctx, category, aws.ToString(event.Message)); failure != nil {
+				return failure
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *FailureDetector) checkStoppedTasks(ctx context.Context) error {
+	list, err := stoppedTaskARNs(ctx, d.Client, d.Cluster, d.Service, d.DeploymentID)
+
+	if err != nil || len(list) == 0 {
+		return nil
+	}
+
+	var arns []string
+
+	for _, arn := range list {
+		if !d.seen[arn] {
+			arns = append(arns, arn)
+		}
+	}
+
+	if len(arns) == 0 {
+		return nil
+	}
+
+	tasks, err := describeTasks(ctx, d.Client, d.Cluster, arns)
+
+	if err != nil {
+		return nil
+	}
+
+	for _, task := range tasks {
+		if d.DeploymentID == "" && task.CreatedAt != nil && task.CreatedAt.Before(d.Since) {
+			continue
+		}
+
+		d.seen[aws.ToString(task.TaskArn)] = true
+
+		if category, reason, ok := ClassifyStoppedTask(task); ok {
+			if failure := d.record(ctx, category, reason); failure != nil {
+				return failure
+			}
+		}
+	}
+
+	return nil
+}
+
+// record counts a classified failure and returns it once its category reaches Threshold
+func (d *FailureDetector) record(ctx context.Context, category FailureCategory, reason string) error {
+	d.counts[category]++
+
+	logging.From(ctx).Warn(
+		fmt.Sprintf("Detected %s failure %d of %d: %s", category, d.counts[category], d.Threshold, reason),
+		logging.Service(d.Service),
+		logging.DeploymentID(d.DeploymentID),
+		logging.F("failure_category", string(category)),
+	)
+
+	if d.counts[category] >= d.Threshold {
+		return &DeploymentFailure{Category: category, Reason: reason}
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func TestClassifyEvent(t *testing.T) {
+	tests := []struct {
+		message string
+		want    FailureCategory
+		wantOK  bool
+	}{
+		{"(service web) was unable to place a task because no container instance met all of its requirements. The closest matching container-instance has insufficient memory available.", FailurePlacement, true},
+		{"(service web) failed to launch a task with (error ECS was unable to assume the role 'arn:aws:iam::123456789012:role/web' that was provided for this task.)", FailureTaskRole, true},
+		{"(service web) (task 1234) failed ELB health checks in (target-group arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/web/abc)", FailureHealthCheck, true},
+		{"(service web) (instance i-0123456789abcdef0) (port 32768) is unhealthy in (target-group arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/web/abc) due to (reason Health checks failed with these codes: [502]).", FailureHealthCheck, true},
+		{"(service web) has reached a steady state.", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.want), func(t *testing.T) {
+			got, ok := ClassifyEvent(tt.message)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClassifyStoppedTask(t *testing.T) {
+	tests := []struct {
+		name       string
+		task       ecstypes.Task
+		want       FailureCategory
+		wantReason string
+		wantOK     bool
+	}{
+		{
+			name: "image-pull",
+			task: ecstypes.Task{
+				StoppedReason: aws.String("CannotPullContainerError: pull image manifest has been retried 1 time(s): not found"),
+			},
+			want:       FailureImagePull,
+			wantReason: "CannotPullContainerError: pull image manifest has been retried 1 time(s): not found",
+			wantOK:     true,
+		},
+		{
+			name: "essential-container-exit",
+			task: ecstypes.Task{
+				StoppedReason: aws.String("Essential container in task exited"),
+				Containers: []ecstypes.Container{
+					{Name: aws.String("app"), ExitCode: aws.Int32(137), Reason: aws.String("OutOfMemoryError: Container killed due to memory usage")},
+					{Name: aws.String("sidecar"), ExitCode: aws.Int32(0)},
+				},
+			},
+			want:       FailureEssentialContainerExit,
+			wantReason: "Essential container in task exited; container 'app': OutOfMemoryError: Container killed due to memory usage; container 'app' exited with code 137",
+			wantOK:     true,
+		},
+		{
+			name: "health-check",
+			task: ecstypes.Task{
+				StoppedReason: aws.String("Task failed ELB health checks in (target-group arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/web/abc)"),
+			},
+			want:       FailureHealthCheck,
+			wantReason: "Task failed ELB health checks in (target-group arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/web/abc)",
+			wantOK:     true,
+		},
+		{
+			name:   "no-stopped-reason",
+			task:   ecstypes.Task{},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, ok := ClassifyStoppedTask(tt.task)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+
+			if tt.wantOK {
+				assert.Equal(t, tt.wantReason, reason)
+			}
+		})
+	}
+}
+
+func TestFailureDetector(t *testing.T) {
+	since := time.Now()
+
+	placement := ecstypes.ServiceEvent{
+		Id:        aws.String("1"),
+		CreatedAt: aws.Time(since.Add(time.Second)),
+		Message:   aws.String("(service test-service) was unable to place a task because no container instance met all of its requirements."),
+	}
+
+	tests := []struct {
+		name         string
+		threshold    int
+		strategy     []ecstypes.CapacityProviderStrategyItem
+		events       []ecstypes.ServiceEvent
+		tasks        []ecstypes.Task
+		wantCategory FailureCategory
+	}{
+		{
+			name:         "placement",
+			threshold:    1,
+			events:       []ecstypes.ServiceEvent{placement},
+			tasks:        []ecstypes.Task{},
+			wantCategory: FailurePlacement,
+		},
+		{
+			name:      "capacity-provider-scaling",
+			threshold: 1,
+			strategy:  []ecstypes.CapacityProviderStrategyItem{{CapacityProvider: aws.String("web-asg"), Weight: 1}},
+			events:    []ecstypes.ServiceEvent{placement},
+			tasks:     []ecstypes.Task{},
+		},
+		{
+			name:      "below-threshold",
+			threshold: 2,
+			events:    []ecstypes.ServiceEvent{placement},
+			tasks:     []ecstypes.Task{},
+		},
+		{
+			name:      "old-event",
+			threshold: 1,
+			events: []ecstypes.ServiceEvent{
+				{Id: aws.String("0"), CreatedAt: aws.Time(since.Add(-time.Minute)), Message: placement.Message},
+			},
+			tasks: []ecstypes.Task{},
+		},
+		{
+			name:      "stopped-tasks",
+			threshold: 2,
+			tasks: []ecstypes.Task{
+				{TaskArn: aws.String("arn-1"), StoppedReason: aws.String("Essential container in task exited")},
+				{TaskArn: aws.String("arn-2"), StoppedReason: aws.String("Essential container in task exited")},
+			},
+			wantCategory: FailureEssentialContainerExit,
+		},
+		{
+			name:   "disabled",
+			events: []ecstypes.ServiceEvent{placement},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MockECSClient{TestingT: t, CapacityProviderStrategy: tt.strategy, ServiceEvents: tt.events, Tasks: tt.tasks}
+			d := &FailureDetector{Client: c, Cluster: "test-cluster", Service: "test-service", DeploymentID: "ecs-svc/1", Since: since, Threshold: tt.threshold}
+
+			err := d.Check(context.TODO())
+
+			if tt.wantCategory == "" {
+				assert.NilError(t, err)
+				return
+			}
+
+			var failure *DeploymentFailure
+
+			assert.Assert(t, errors.As(err, &failure), "got error %v", err)
+			assert.Equal(t, tt.wantCategory, failure.Category)
+		})
+	}
+}
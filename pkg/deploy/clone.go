@@ -0,0 +1,95 @@
+package deploy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// taskDefinitionReadOnlyFields are set by ECS when a task definition is registered
+// They can never be passed to RegisterTaskDefinition so they are not reported as dropped
+var taskDefinitionReadOnlyFields = map[string]bool{
+	"Compatibilities":    true,
+	"DeregisteredAt":     true,
+	"RegisteredAt":       true,
+	"RegisteredBy":       true,
+	"RequiresAttributes": true,
+	"Revision":           true,
+	"Status":             true,
+	"TaskDefinitionArn":  true,
+}
+
+// CloneTaskDefinition builds the input needed to register a copy of a task definition
+// Every field that ecstypes.TaskDefinition shares with ecs.RegisterTaskDefinitionInput is carried over, including fields added to the SDK later
+// The second return value lists every field or tag that is set on the task definition but cannot be carried over
+func CloneTaskDefinition(td ecstypes.TaskDefinition, tags []ecstypes.Tag) (*ecs.RegisterTaskDefinitionInput, []string) {
+	i := ecs.RegisterTaskDefinitionInput{}
+	var dropped []string
+
+	src := reflect.ValueOf(td)
+	dst := reflect.ValueOf(&i).Elem()
+
+	for idx := 0; idx < src.NumField(); idx++ {
+		field := src.Type().Field(idx)
+
+		// Skip unexported fields such as the SDK's serialization markers
+		if field.PkgPath != "" || taskDefinitionReadOnlyFields[field.Name] {
+			continue
+		}
+
+		value := src.Field(idx)
+		target := dst.FieldByName(field.Name)
+
+		if !target.IsValid() || !target.CanSet() || target.Type() != field.Type {
+			if !value.IsZero() {
+				dropped = append(dropped, field.Name)
+			}
+			continue
+		}
+
+		target.Set(value)
+	}
+
+	for _, tag := range tags {
+		// Tags with the aws: prefix are reserved and are rejected by RegisterTaskDefinition
+		if strings.HasPrefix(strings.ToLower(*tag.Key), "aws:") {
+			dropped = append(dropped, fmt.Sprintf("Tags[%s]", *tag.Key))
+			continue
+		}
+
+		i.Tags = append(i.Tags, tag)
+	}
+
+	return &i, dropped
+}
+
+// PlannedTaskDefinition returns td with every field that is set by i replaced, which is what registering i would produce
+// The ARN and revision are cleared because they are only known once i is registered
+func PlannedTaskDefinition(td ecstypes.TaskDefinition, i *ecs.RegisterTaskDefinitionInput) ecstypes.TaskDefinition {
+	td.TaskDefinitionArn = nil
+	td.Revision = 0
+
+	src := reflect.ValueOf(*i)
+	dst := reflect.ValueOf(&td).Elem()
+
+	for idx := 0; idx < src.NumField(); idx++ {
+		field := src.Type().Field(idx)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		target := dst.FieldByName(field.Name)
+
+		if !target.IsValid() || !target.CanSet() || target.Type() != field.Type {
+			continue
+		}
+
+		target.Set(src.Field(idx))
+	}
+
+	return td
+}
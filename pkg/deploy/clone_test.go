@@ -0,0 +1,174 @@
+package deploy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+// fullTaskDefinition returns a task definition with every field set
+func fullTaskDefinition() ecstypes.TaskDefinition {
+	return ecstypes.TaskDefinition{
+		Compatibilities: []ecstypes.Compatibility{ecstypes.CompatibilityFargate},
+		ContainerDefinitions: []ecstypes.ContainerDefinition{
+			{
+				Name:        aws.String("app"),
+				Image:       aws.String("foo/app:2"),
+				Environment: []ecstypes.KeyValuePair{{Name: aws.String("FOO"), Value: aws.String("bar")}},
+			},
+			{
+				Name:  aws.String("sidecar"),
+				Image: aws.String("foo/sidecar:1"),
+			},
+		},
+		Cpu:                     aws.String("1024"),
+		DeregisteredAt:          &time.Time{},
+		EphemeralStorage:        &ecstypes.EphemeralStorage{SizeInGiB: 30},
+		ExecutionRoleArn:        aws.String("arn:aws:iam::123456789012:role/execution"),
+		Family:                  aws.String("amazon-ecs-sample"),
+		InferenceAccelerators:   []ecstypes.InferenceAccelerator{{DeviceName: aws.String("device"), DeviceType: aws.String("eia2.medium")}},
+		IpcMode:                 ecstypes.IpcModeTask,
+		Memory:                  aws.String("2048"),
+		NetworkMode:             ecstypes.NetworkModeAwsvpc,
+		PidMode:                 ecstypes.PidModeTask,
+		PlacementConstraints:    []ecstypes.TaskDefinitionPlacementConstraint{{Type: ecstypes.TaskDefinitionPlacementConstraintTypeMemberOf, Expression: aws.String("attribute:ecs.os-type == linux")}},
+		ProxyConfiguration:      &ecstypes.ProxyConfiguration{ContainerName: aws.String("sidecar"), Type: ecstypes.ProxyConfigurationTypeAppmesh},
+		RegisteredAt:            &time.Time{},
+		RegisteredBy:            aws.String("arn:aws:iam::123456789012:user/deployer"),
+		RequiresAttributes:      []ecstypes.Attribute{{Name: aws.String("ecs.capability.execution-role-awslogs")}},
+		RequiresCompatibilities: []ecstypes.Compatibility{ecstypes.CompatibilityFargate},
+		Revision:                1,
+		RuntimePlatform:         &ecstypes.RuntimePlatform{CpuArchitecture: ecstypes.CPUArchitectureArm64, OperatingSystemFamily: ecstypes.OSFamilyLinux},
+		Status:                  ecstypes.TaskDefinitionStatusActive,
+		TaskDefinitionArn:       aws.String(testTDARN),
+		TaskRoleArn:             aws.String("arn:aws:iam::123456789012:role/task"),
+		Volumes:                 []ecstypes.Volume{{Name: aws.String("data")}},
+	}
+}
+
+// TestFullTaskDefinitionSetsEveryField ensures the fixture is updated when the SDK adds fields to task definitions
+func TestFullTaskDefinitionSetsEveryField(t *testing.T) {
+	v := reflect.ValueOf(fullTaskDefinition())
+
+	for idx := 0; idx < v.NumField(); idx++ {
+		field := v.Type().Field(idx)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if v.Field(idx).IsZero() {
+			t.Errorf("fullTaskDefinition() does not set field '%s'", field.Name)
+		}
+	}
+}
+
+// TestCloneTaskDefinitionHasSourceForEveryField ensures every registerable field can be read from a task definition
+func TestCloneTaskDefinitionHasSourceForEveryField(t *testing.T) {
+	input := reflect.TypeOf(ecs.RegisterTaskDefinitionInput{})
+	td := reflect.TypeOf(ecstypes.TaskDefinition{})
+
+	for idx := 0; idx < input.NumField(); idx++ {
+		field := input.Field(idx)
+
+		// Tags are not part of the task definition and are fetched separately
+		if field.PkgPath != "" || field.Name == "Tags" {
+			continue
+		}
+
+		source, ok := td.FieldByName(field.Name)
+
+		if !ok || source.Type != field.Type {
+			t.Errorf("RegisterTaskDefinitionInput field '%s' cannot be copied from a task definition", field.Name)
+		}
+	}
+}
+
+func TestCloneTaskDefinition(t *testing.T) {
+	td := fullTaskDefinition()
+
+	tags := []ecstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("platform")},
+		{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("webapp")},
+	}
+
+	got, dropped := CloneTaskDefinition(td, tags)
+
+	want := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    td.ContainerDefinitions,
+		Family:                  td.Family,
+		Cpu:                     td.Cpu,
+		EphemeralStorage:        td.EphemeralStorage,
+		ExecutionRoleArn:        td.ExecutionRoleArn,
+		InferenceAccelerators:   td.InferenceAccelerators,
+		IpcMode:                 td.IpcMode,
+		Memory:                  td.Memory,
+		NetworkMode:             td.NetworkMode,
+		PidMode:                 td.PidMode,
+		PlacementConstraints:    td.PlacementConstraints,
+		ProxyConfiguration:      td.ProxyConfiguration,
+		RequiresCompatibilities: td.RequiresCompatibilities,
+		RuntimePlatform:         td.RuntimePlatform,
+		Tags:                    []ecstypes.Tag{tags[0]},
+		TaskRoleArn:             td.TaskRoleArn,
+		Volumes:                 td.Volumes,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CloneTaskDefinition() = %v, want %v", got, want)
+	}
+
+	assert.DeepEqual(t, []string{"Tags[aws:cloudformation:stack-name]"}, dropped)
+}
+
+// TestCreateNewTaskDefinitionRevisionRoundTrip registers a clone against the mock client and ensures every field and tag survives
+func TestCreateNewTaskDefinitionRevisionRoundTrip(t *testing.T) {
+	td := fullTaskDefinition()
+	tags := []ecstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("platform")},
+		{Key: aws.String("cost-center"), Value: aws.String("1234")},
+	}
+
+	registered := []*ecs.RegisterTaskDefinitionInput{}
+
+	c := MockECSClient{
+		TestingT:                  t,
+		TaskDefinition:            &td,
+		TaskDefinitionTags:        tags,
+		RegisteredTaskDefinitions: &registered,
+	}
+
+	currTD, err := RetrieveTaskDefinition(context.Background(), c, testTDARN)
+	assert.NilError(t, err)
+
+	newTD, err := CreateNewTaskDefinitionRevision(context.Background(), c, currTD, TaskDefinitionChanges{Images: map[string]string{"app": "foo/app:3"}})
+	assert.NilError(t, err)
+
+	assert.Equal(t, 1, len(registered))
+	assert.Assert(t, reflect.DeepEqual(tags, registered[0].Tags))
+
+	got := reflect.ValueOf(*newTD)
+	want := reflect.ValueOf(td)
+
+	for idx := 0; idx < want.NumField(); idx++ {
+		field := want.Type().Field(idx)
+
+		if field.PkgPath != "" || taskDefinitionReadOnlyFields[field.Name] || field.Name == "ContainerDefinitions" {
+			continue
+		}
+
+		if !reflect.DeepEqual(got.Field(idx).Interface(), want.Field(idx).Interface()) {
+			t.Errorf("field '%s' = %v, want %v", field.Name, got.Field(idx).Interface(), want.Field(idx).Interface())
+		}
+	}
+
+	assert.Equal(t, "foo/app:3", *newTD.ContainerDefinitions[0].Image)
+	assert.Assert(t, reflect.DeepEqual(td.ContainerDefinitions[0].Environment, newTD.ContainerDefinitions[0].Environment))
+	assert.Assert(t, reflect.DeepEqual(td.ContainerDefinitions[1], newTD.ContainerDefinitions[1]))
+}
@@ -0,0 +1,196 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdtypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// appSpecHooks are the lifecycle hooks that can run a Lambda function during an ECS deployment, in the order they run
+var appSpecHooks = []string{
+	"BeforeInstall",
+	"AfterInstall",
+	"AfterAllowTestTraffic",
+	"BeforeAllowTraffic",
+	"AfterAllowTraffic",
+}
+
+type appSpec struct {
+	Version   json.Number                  `json:"version"`
+	Resources []map[string]appSpecResource `json:"Resources"`
+	Hooks     []map[string]string          `json:"Hooks,omitempty"`
+}
+
+type appSpecResource struct {
+	Type       string                    `json:"Type"`
+	Properties appSpecResourceProperties `json:"Properties"`
+}
+
+type appSpecResourceProperties struct {
+	TaskDefinition   string                   `json:"TaskDefinition"`
+	LoadBalancerInfo *appSpecLoadBalancerInfo `json:"LoadBalancerInfo,omitempty"`
+}
+
+type appSpecLoadBalancerInfo struct {
+	ContainerName string `json:"ContainerName"`
+	ContainerPort int32  `json:"ContainerPort"`
+}
+
+// GenerateAppSpec returns the JSON AppSpec that deploys taskDefinitionARN to an ECS service
+// loadBalancers are the service's load balancers. hooks maps lifecycle hook names to the Lambda function that runs during the hook
+func GenerateAppSpec(taskDefinitionARN string, loadBalancers []ecstypes.LoadBalancer, hooks map[string]string) (string, error) {
+	properties := appSpecResourceProperties{
+		TaskDefinition: taskDefinitionARN,
+	}
+
+	if len(loadBalancers) > 0 {
+		lb := loadBalancers[0]
+
+		if lb.ContainerName == nil || lb.ContainerPort == nil {
+			return "", errors.New("service load balancer has no container name or port")
+		}
+
+		properties.LoadBalancerInfo = &appSpecLoadBalancerInfo{
+			ContainerName: *lb.ContainerName,
+			ContainerPort: *lb.ContainerPort,
+		}
+	}
+
+	spec := appSpec{
+		Version: json.Number("0.0"),
+		Resources: []map[string]appSpecResource{
+			{
+				"TargetService": appSpecResource{
+					Type:       "AWS::ECS::Service",
+					Properties: properties,
+				},
+			},
+		},
+	}
+
+	known := make(map[string]bool)
+
+	for _, hook := range appSpecHooks {
+		known[hook] = true
+
+		if function, ok := hooks[hook]; ok {
+			spec.Hooks = append(spec.Hooks, map[string]string{hook: function})
+		}
+	}
+
+	for hook := range hooks {
+		if !known[hook] {
+			return "", fmt.Errorf("unknown lifecycle hook '%s'", hook)
+		}
+	}
+
+	out, err := json.Marshal(spec)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// CreateCodeDeployDeployment starts a CodeDeploy deployment using appSpec and returns the deployment ID
+func CreateCodeDeployDeployment(ctx context.Context, c types.CodeDeployClient, application string, deploymentGroup string, appSpec string) (string, error) {
+	i := codedeploy.CreateDeploymentInput{
+		ApplicationName:     aws.String(application),
+		DeploymentGroupName: aws.String(deploymentGroup),
+		Revision: &cdtypes.RevisionLocation{
+			RevisionType: cdtypes.RevisionLocationTypeAppSpecContent,
+			AppSpecContent: &cdtypes.AppSpecContent{
+				Content: aws.String(appSpec),
+			},
+		},
+	}
+
+	out, err := c.CreateDeployment(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error creating CodeDeploy deployment", logging.Err(err))
+		return "", err
+	}
+
+	return *out.DeploymentId, nil
+}
+
+// CheckCodeDeployDeploymentStatus returns true if a CodeDeploy deployment has finished (either success or failure) and false if the deployment is in progress
+func CheckCodeDeployDeploymentStatus(ctx context.Context, c types.CodeDeployClient, deploymentID string) (bool, error) {
+	out, err := c.GetDeployment(ctx, &codedeploy.GetDeploymentInput{DeploymentId: aws.String(deploymentID)})
+
+	if err != nil {
+		logging.From(ctx).Error("Error getting CodeDeploy deployment", logging.Err(err))
+		return true, err
+	}
+
+	switch out.DeploymentInfo.Status {
+	case cdtypes.DeploymentStatusSucceeded:
+		return true, nil
+	case cdtypes.DeploymentStatusFailed, cdtypes.DeploymentStatusStopped:
+		if info := out.DeploymentInfo.ErrorInformation; info != nil {
+			logging.From(ctx).Error(fmt.Sprintf("CodeDeploy deployment %s: %s %s", out.DeploymentInfo.Status, info.Code, aws.ToString(info.Message)), logging.DeploymentID(deploymentID))
+		}
+
+		return true, errors.New("deployment failed")
+	default:
+		return false, nil
+	}
+}
+
+// GetCodeDeployLifecycleEvents returns the lifecycle events of every ECS target in a CodeDeploy deployment
+func GetCodeDeployLifecycleEvents(ctx context.Context, c types.CodeDeployClient, deploymentID string) ([]cdtypes.LifecycleEvent, error) {
+	var events []cdtypes.LifecycleEvent
+
+	targets, err := c.ListDeploymentTargets(ctx, &codedeploy.ListDeploymentTargetsInput{DeploymentId: aws.String(deploymentID)})
+
+	if err != nil {
+		logging.From(ctx).Error("Error listing CodeDeploy deployment targets", logging.Err(err))
+		return nil, err
+	}
+
+	if len(targets.TargetIds) == 0 {
+		return events, nil
+	}
+
+	out, err := c.BatchGetDeploymentTargets(ctx, &codedeploy.BatchGetDeploymentTargetsInput{
+		DeploymentId: aws.String(deploymentID),
+		TargetIds:    targets.TargetIds,
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing CodeDeploy deployment targets", logging.Err(err))
+		return nil, err
+	}
+
+	for _, target := range out.DeploymentTargets {
+		if target.EcsTarget != nil {
+			events = append(events, target.EcsTarget.LifecycleEvents...)
+		}
+	}
+
+	return events, nil
+}
+
+// StopCodeDeployDeployment stops a CodeDeploy deployment. If rollback is true CodeDeploy shifts traffic back to the original task set
+func StopCodeDeployDeployment(ctx context.Context, c types.CodeDeployClient, deploymentID string, rollback bool) error {
+	_, err := c.StopDeployment(ctx, &codedeploy.StopDeploymentInput{
+		DeploymentId:        aws.String(deploymentID),
+		AutoRollbackEnabled: aws.Bool(rollback),
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Error stopping CodeDeploy deployment", logging.Err(err))
+	}
+
+	return err
+}
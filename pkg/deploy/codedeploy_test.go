@@ -0,0 +1,144 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdtypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func TestGenerateAppSpec(t *testing.T) {
+	type args struct {
+		taskDefinitionARN string
+		loadBalancers     []ecstypes.LoadBalancer
+		hooks             map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no-load-balancer",
+			args: args{
+				taskDefinitionARN: testTDARN,
+			},
+			want:    `{"version":0.0,"Resources":[{"TargetService":{"Type":"AWS::ECS::Service","Properties":{"TaskDefinition":"` + testTDARN + `"}}}]}`,
+			wantErr: false,
+		},
+		{
+			name: "load-balancer-and-hooks",
+			args: args{
+				taskDefinitionARN: testTDARN,
+				loadBalancers: []ecstypes.LoadBalancer{
+					{ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+				},
+				hooks: map[string]string{
+					"AfterAllowTestTraffic": "smoke-test",
+					"BeforeInstall":         "validate",
+				},
+			},
+			want:    `{"version":0.0,"Resources":[{"TargetService":{"Type":"AWS::ECS::Service","Properties":{"TaskDefinition":"` + testTDARN + `","LoadBalancerInfo":{"ContainerName":"app","ContainerPort":8080}}}}],"Hooks":[{"BeforeInstall":"validate"},{"AfterAllowTestTraffic":"smoke-test"}]}`,
+			wantErr: false,
+		},
+		{
+			name: "unknown-hook",
+			args: args{
+				taskDefinitionARN: testTDARN,
+				hooks:             map[string]string{"ApplicationStart": "start"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateAppSpec(tt.args.taskDefinitionARN, tt.args.loadBalancers, tt.args.hooks)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateAppSpec() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GenerateAppSpec() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateCodeDeployDeployment(t *testing.T) {
+	created := []*codedeploy.CreateDeploymentInput{}
+	c := MockCodeDeployClient{TestingT: t, CreatedDeployments: &created}
+
+	id, err := CreateCodeDeployDeployment(context.Background(), c, "webapp", "webapp-dg", "{}")
+
+	assert.NilError(t, err)
+	assert.Equal(t, "d-TESTDEPLOY", id)
+	assert.Equal(t, 1, len(created))
+	assert.Equal(t, "webapp", *created[0].ApplicationName)
+	assert.Equal(t, "webapp-dg", *created[0].DeploymentGroupName)
+	assert.Equal(t, cdtypes.RevisionLocationTypeAppSpecContent, created[0].Revision.RevisionType)
+	assert.Equal(t, "{}", *created[0].Revision.AppSpecContent.Content)
+
+	_, err = CreateCodeDeployDeployment(context.Background(), MockCodeDeployClient{TestingT: t, WantError: true}, "webapp", "webapp-dg", "{}")
+	assert.Error(t, err, "error")
+}
+
+func TestCheckCodeDeployDeploymentStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   cdtypes.DeploymentStatus
+		want     bool
+		wantErr  bool
+		apiError bool
+	}{
+		{name: "in-progress", status: cdtypes.DeploymentStatusInProgress, want: false, wantErr: false},
+		{name: "baking", status: cdtypes.DeploymentStatusBaking, want: false, wantErr: false},
+		{name: "succeeded", status: cdtypes.DeploymentStatusSucceeded, want: true, wantErr: false},
+		{name: "failed", status: cdtypes.DeploymentStatusFailed, want: true, wantErr: true},
+		{name: "stopped", status: cdtypes.DeploymentStatusStopped, want: true, wantErr: true},
+		{name: "api-error", apiError: true, want: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MockCodeDeployClient{TestingT: t, DeploymentStatus: tt.status, WantError: tt.apiError}
+
+			got, err := CheckCodeDeployDeploymentStatus(context.Background(), c, "d-TESTDEPLOY")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckCodeDeployDeploymentStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CheckCodeDeployDeploymentStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCodeDeployLifecycleEvents(t *testing.T) {
+	events := []cdtypes.LifecycleEvent{
+		{LifecycleEventName: aws.String("BeforeInstall"), Status: cdtypes.LifecycleEventStatusSucceeded},
+		{LifecycleEventName: aws.String("Install"), Status: cdtypes.LifecycleEventStatusInProgress},
+	}
+
+	got, err := GetCodeDeployLifecycleEvents(context.Background(), MockCodeDeployClient{TestingT: t, LifecycleEvents: events}, "d-TESTDEPLOY")
+
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "Install", *got[1].LifecycleEventName)
+	assert.Equal(t, cdtypes.LifecycleEventStatusInProgress, got[1].Status)
+}
+
+func TestStopCodeDeployDeployment(t *testing.T) {
+	stopped := []*codedeploy.StopDeploymentInput{}
+
+	err := StopCodeDeployDeployment(context.Background(), MockCodeDeployClient{TestingT: t, StoppedDeployments: &stopped}, "d-TESTDEPLOY", true)
+
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(stopped))
+	assert.Equal(t, "d-TESTDEPLOY", *stopped[0].DeploymentId)
+	assert.Equal(t, true, *stopped[0].AutoRollbackEnabled)
+}
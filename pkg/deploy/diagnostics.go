@@ -0,0 +1,183 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// LogTail prints the last lines of the CloudWatch Logs stream of a container that uses the awslogs log driver
+// A nil LogTail prints nothing
+type LogTail struct {
+	Client types.CloudWatchLogsClient
+	Lines  int32
+}
+
+// stoppedTaskARNs returns the stopped tasks started by deploymentID, or every stopped task of service if deploymentID is empty
+func stoppedTaskARNs(ctx context.Context, c types.ECSClient, cluster string, service string, deploymentID string) ([]string, error) {
+	input := ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		DesiredStatus: ecstypes.DesiredStatusStopped,
+	}
+
+	if deploymentID != "" {
+		input.StartedBy = aws.String(deploymentID)
+	} else {
+		input.ServiceName = aws.String(service)
+	}
+
+	return listTaskARNs(ctx, c, input)
+}
+
+// ReportStoppedTasks prints why every stopped task of a deployment stopped, with the exit code, reason and image digest of each container
+// Tasks are the ones started by deploymentID, or the tasks of service created after since if deploymentID is empty
+// ECS forgets stopped tasks soon after they stop, so this should be called as soon as a deployment fails
+func ReportStoppedTasks(ctx context.Context, c types.ECSClient, logs *LogTail, cluster string, service string, deploymentID string, since time.Time) {
+	ctx = logging.With(ctx, logging.Service(service), logging.DeploymentID(deploymentID))
+	logging.From(ctx).Info(fmt.Sprintf("Checking stopped tasks for service '%s'", service))
+
+	arns, err := stoppedTaskARNs(ctx, c, cluster, service, deploymentID)
+
+	if err != nil {
+		logging.From(ctx).Error("Error listing stopped tasks", logging.Err(err))
+		return
+	}
+
+	if len(arns) == 0 {
+		logging.From(ctx).Info("No stopped tasks found")
+		return
+	}
+
+	tasks, err := describeTasks(ctx, c, cluster, arns)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing stopped tasks", logging.Err(err))
+		return
+	}
+
+	taskDefinitions := map[string]*ecstypes.TaskDefinition{}
+
+	for _, task := range tasks {
+		if deploymentID == "" && task.CreatedAt != nil && task.CreatedAt.Before(since) {
+			continue
+		}
+
+		reportStoppedTask(ctx, task)
+
+		if logs == nil || logs.Lines < 1 {
+			continue
+		}
+
+		tdARN := aws.ToString(task.TaskDefinitionArn)
+
+		if _, ok := taskDefinitions[tdARN]; !ok {
+			taskDefinitions[tdARN] = nil
+
+			if tdARN != "" {
+				td, err := RetrieveTaskDefinition(ctx, c, tdARN)
+
+				if err != nil {
+					logging.From(ctx).Error("Error retrieving task definition for log configuration", logging.Err(err))
+				} else {
+					taskDefinitions[tdARN] = &td
+				}
+			}
+		}
+
+		if td := taskDefinitions[tdARN]; td != nil {
+			logs.print(ctx, task, *td)
+		}
+	}
+}
+
+func reportStoppedTask(ctx context.Context, task ecstypes.Task) {
+	stoppedAt := "unknown time"
+
+	if task.StoppedAt != nil {
+		stoppedAt = task.StoppedAt.UTC().Format(time.RFC3339)
+	}
+
+	logging.From(ctx).Error(
+		fmt.Sprintf("Task '%s' stopped at %s (%s): %s", aws.ToString(task.TaskArn), stoppedAt, task.StopCode, aws.ToString(task.StoppedReason)),
+		logging.F("task", aws.ToString(task.TaskArn)),
+	)
+
+	for _, container := range task.Containers {
+		exitCode := "none"
+
+		if container.ExitCode != nil {
+			exitCode = fmt.Sprint(*container.ExitCode)
+		}
+
+		logging.From(ctx).Error(fmt.Sprintf(
+			"  Container '%s' exit code: %s, reason: %s, image: %s, digest: %s",
+			aws.ToString(container.Name),
+			exitCode,
+			aws.ToString(container.Reason),
+			aws.ToString(container.Image),
+			aws.ToString(container.ImageDigest),
+		), logging.F("container", aws.ToString(container.Name)))
+	}
+}
+
+// print logs the tail of the awslogs stream of every container of task
+func (t *LogTail) print(ctx context.Context, task ecstypes.Task, td ecstypes.TaskDefinition) {
+	arn := aws.ToString(task.TaskArn)
+	taskID := arn[strings.LastIndex(arn, "/")+1:]
+
+	for _, container := range task.Containers {
+		name := aws.ToString(container.Name)
+		group, stream, ok := awslogsStream(td, name, taskID)
+
+		if !ok {
+			continue
+		}
+
+		out, err := t.Client.GetLogEvents(ctx, &cloudwatchlogs.GetLogEventsInput{
+			LogGroupName:  aws.String(group),
+			LogStreamName: aws.String(stream),
+			Limit:         aws.Int32(t.Lines),
+			StartFromHead: aws.Bool(false),
+		})
+
+		if err != nil {
+			logging.From(ctx).Warn(fmt.Sprintf("  Error reading logs of container '%s' from '%s/%s'", name, group, stream), logging.Err(err))
+			continue
+		}
+
+		logging.From(ctx).Info(fmt.Sprintf("  Last %d log lines of container '%s' from '%s/%s':", len(out.Events), name, group, stream))
+
+		for _, event := range out.Events {
+			logging.From(ctx).Info("    " + strings.TrimRight(aws.ToString(event.Message), "\n"))
+		}
+	}
+}
+
+// awslogsStream returns the log group and stream of a container that uses the awslogs log driver with a stream prefix
+// Without a prefix the stream is named after the Docker container ID, which ECS does not report
+func awslogsStream(td ecstypes.TaskDefinition, container string, taskID string) (string, string, bool) {
+	for _, c := range td.ContainerDefinitions {
+		if aws.ToString(c.Name) != container || c.LogConfiguration == nil || c.LogConfiguration.LogDriver != ecstypes.LogDriverAwslogs {
+			continue
+		}
+
+		group := c.LogConfiguration.Options["awslogs-group"]
+		prefix := c.LogConfiguration.Options["awslogs-stream-prefix"]
+
+		if group == "" || prefix == "" {
+			return "", "", false
+		}
+
+		return group, prefix + "/" + container + "/" + taskID, true
+	}
+
+	return "", "", false
+}
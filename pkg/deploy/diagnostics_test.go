@@ -0,0 +1,118 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func TestReportStoppedTasks(t *testing.T) {
+	tdARN := "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:2"
+
+	c := MockECSClient{
+		TestingT: t,
+		Tasks: []ecstypes.Task{
+			{
+				TaskArn:           aws.String("arn:aws:ecs:us-west-2:123456789012:task/test-cluster/abc123"),
+				TaskDefinitionArn: aws.String(tdARN),
+				StopCode:          ecstypes.TaskStopCodeEssentialContainerExited,
+				StoppedReason:     aws.String("Essential container in task exited"),
+				Containers: []ecstypes.Container{
+					{
+						Name:        aws.String("app"),
+						ExitCode:    aws.Int32(1),
+						Image:       aws.String("some/image:2.0"),
+						ImageDigest: aws.String("sha256:abc"),
+					},
+					{
+						Name:  aws.String("sidecar"),
+						Image: aws.String("datadog/agent:7"),
+					},
+				},
+			},
+			// ECS does not always set a stopped reason
+			{
+				TaskArn: aws.String("arn:aws:ecs:us-west-2:123456789012:task/test-cluster/def456"),
+			},
+		},
+		TaskDefinitions: map[string]ecstypes.TaskDefinition{
+			tdARN: {
+				TaskDefinitionArn: aws.String(tdARN),
+				ContainerDefinitions: []ecstypes.ContainerDefinition{
+					{
+						Name: aws.String("app"),
+						LogConfiguration: &ecstypes.LogConfiguration{
+							LogDriver: ecstypes.LogDriverAwslogs,
+							Options:   map[string]string{"awslogs-group": "/ecs/webapp", "awslogs-stream-prefix": "ecs"},
+						},
+					},
+					// Without a stream prefix the stream name can't be known
+					{
+						Name: aws.String("sidecar"),
+						LogConfiguration: &ecstypes.LogConfiguration{
+							LogDriver: ecstypes.LogDriverAwslogs,
+							Options:   map[string]string{"awslogs-group": "/ecs/webapp"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	logs := &LogTail{
+		Client: MockCloudWatchLogsClient{Streams: map[string][]string{
+			"/ecs/webapp/ecs/app/abc123": {"starting", "connecting to database", "panic: connection refused\n"},
+		}},
+		Lines: 2,
+	}
+
+	var out bytes.Buffer
+	ctx := logging.WithLogger(context.TODO(), logging.NewTextLogger(&out, logging.LevelInfo))
+
+	ReportStoppedTasks(ctx, c, logs, "test-cluster", "test-service", "ecs-svc/1", time.Now())
+
+	report := out.String()
+
+	for _, want := range []string{
+		"Task 'arn:aws:ecs:us-west-2:123456789012:task/test-cluster/abc123' stopped at unknown time (EssentialContainerExited): Essential container in task exited",
+		"Container 'app' exit code: 1, reason: , image: some/image:2.0, digest: sha256:abc",
+		"Container 'sidecar' exit code: none",
+		"Last 2 log lines of container 'app' from '/ecs/webapp/ecs/app/abc123':",
+		"INFO     connecting to database service=test-service deployment_id=ecs-svc/1\n",
+		"INFO     panic: connection refused service=test-service deployment_id=ecs-svc/1\n",
+		"Task 'arn:aws:ecs:us-west-2:123456789012:task/test-cluster/def456' stopped at unknown time (): ",
+	} {
+		assert.Assert(t, strings.Contains(report, want), "missing %q in:\n%s", want, report)
+	}
+
+	assert.Assert(t, !strings.Contains(report, "starting"), report)
+	assert.Assert(t, !strings.Contains(report, "of container 'sidecar'"), report)
+}
+
+func TestReportStoppedTasksPages(t *testing.T) {
+	var tasks []ecstypes.Task
+
+	for i := 0; i < 150; i++ {
+		tasks = append(tasks, ecstypes.Task{
+			TaskArn:       aws.String(fmt.Sprintf("arn:aws:ecs:us-west-2:123456789012:task/test-cluster/%d", i)),
+			StoppedReason: aws.String("Essential container in task exited"),
+		})
+	}
+
+	var out bytes.Buffer
+	ctx := logging.WithLogger(context.TODO(), logging.NewTextLogger(&out, logging.LevelInfo))
+
+	ReportStoppedTasks(ctx, MockECSClient{TestingT: t, Tasks: tasks}, nil, "test-cluster", "test-service", "ecs-svc/1", time.Now())
+
+	// The last task is on the second page of ListTasks
+	assert.Assert(t, strings.Contains(out.String(), "Task 'arn:aws:ecs:us-west-2:123456789012:task/test-cluster/149' stopped"))
+	assert.Equal(t, 150, strings.Count(out.String(), "Essential container in task exited"))
+}
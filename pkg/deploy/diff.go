@@ -0,0 +1,336 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// diffContextLines is the number of unchanged lines shown around every change in a unified diff
+const diffContextLines = 3
+
+// taskDefinitionIdentityFields differ between every two revisions so they are left out of diffs
+var taskDefinitionIdentityFields = map[string]bool{
+	"DeregisteredAt":    true,
+	"RegisteredAt":      true,
+	"RegisteredBy":      true,
+	"Revision":          true,
+	"TaskDefinitionArn": true,
+}
+
+// FieldChange is a single field that differs between two task definitions
+// Old is omitted when the field was added and New is omitted when the field was removed
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// TaskDefinitionDiff is the structural difference between two task definitions
+type TaskDefinitionDiff struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []FieldChange `json:"changes"`
+
+	fromLines map[string]string
+	toLines   map[string]string
+}
+
+// DiffTaskDefinitions compares every task-level and container definition field of two task definitions
+// Container definitions, environment variables, secrets and other lists of named items are matched by name, so reordering them is not a change
+func DiffTaskDefinitions(from ecstypes.TaskDefinition, to ecstypes.TaskDefinition) (TaskDefinitionDiff, error) {
+	d := TaskDefinitionDiff{
+		From:    taskDefinitionLabel(from),
+		To:      taskDefinitionLabel(to),
+		Changes: []FieldChange{},
+	}
+
+	fromValues, err := flattenTaskDefinition(from)
+
+	if err != nil {
+		return d, err
+	}
+
+	toValues, err := flattenTaskDefinition(to)
+
+	if err != nil {
+		return d, err
+	}
+
+	d.fromLines = encodeValues(fromValues)
+	d.toLines = encodeValues(toValues)
+
+	for _, path := range unionKeys(d.fromLines, d.toLines) {
+		if d.fromLines[path] == d.toLines[path] {
+			continue
+		}
+
+		d.Changes = append(d.Changes, FieldChange{Path: path, Old: fromValues[path], New: toValues[path]})
+	}
+
+	return d, nil
+}
+
+// IsEmpty returns true if both task definitions are the same
+func (d TaskDefinitionDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// JSON returns the diff as a JSON document
+func (d TaskDefinitionDiff) JSON() (string, error) {
+	out, err := json.Marshal(d)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// Unified returns the diff in unified diff format, with one "path: value" line per field
+func (d TaskDefinitionDiff) Unified() string {
+	if d.IsEmpty() {
+		return ""
+	}
+
+	type line struct {
+		op   byte
+		text string
+		// Line numbers in the from and to documents, counted from 1
+		fromLine int
+		toLine   int
+	}
+
+	var lines []line
+	fromLine, toLine := 0, 0
+
+	for _, path := range unionKeys(d.fromLines, d.toLines) {
+		fromValue, inFrom := d.fromLines[path]
+		toValue, inTo := d.toLines[path]
+
+		if inFrom && inTo && fromValue == toValue {
+			fromLine++
+			toLine++
+			lines = append(lines, line{' ', path + ": " + fromValue, fromLine, toLine})
+			continue
+		}
+
+		if inFrom {
+			fromLine++
+			lines = append(lines, line{'-', path + ": " + fromValue, fromLine, toLine})
+		}
+
+		if inTo {
+			toLine++
+			lines = append(lines, line{'+', path + ": " + toValue, fromLine, toLine})
+		}
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", d.From, d.To)
+
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// Extend the hunk until there are more than 2 * diffContextLines unchanged lines in a row
+		end := start
+
+		for idx := start; idx < len(lines) && idx-end <= 2*diffContextLines; idx++ {
+			if lines[idx].op != ' ' {
+				end = idx
+			}
+		}
+
+		first := start - diffContextLines
+
+		if first < 0 {
+			first = 0
+		}
+
+		last := end + diffContextLines
+
+		if last >= len(lines) {
+			last = len(lines) - 1
+		}
+
+		fromStart, fromCount, toStart, toCount := 0, 0, 0, 0
+
+		for _, l := range lines[first : last+1] {
+			if l.op != '+' {
+				if fromCount == 0 {
+					fromStart = l.fromLine
+				}
+				fromCount++
+			}
+
+			if l.op != '-' {
+				if toCount == 0 {
+					toStart = l.toLine
+				}
+				toCount++
+			}
+		}
+
+		// An empty range starts at the line before it, as in diff -u
+		if fromCount == 0 {
+			fromStart = lines[first].fromLine
+		}
+
+		if toCount == 0 {
+			toStart = lines[first].toLine
+		}
+
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+
+		for _, l := range lines[first : last+1] {
+			fmt.Fprintf(&b, "%c%s\n", l.op, l.text)
+		}
+
+		start = last + 1
+	}
+
+	return b.String()
+}
+
+// taskDefinitionLabel names a task definition by family and revision, falling back to its ARN
+// Revisions start at 1, so a task definition without one has not been registered yet
+func taskDefinitionLabel(td ecstypes.TaskDefinition) string {
+	if td.Family != nil && td.Revision == 0 {
+		return fmt.Sprintf("%s:new", *td.Family)
+	}
+
+	if td.Family != nil {
+		return fmt.Sprintf("%s:%d", *td.Family, td.Revision)
+	}
+
+	return aws.ToString(td.TaskDefinitionArn)
+}
+
+// flattenTaskDefinition returns every set field of td keyed by its path, such as ContainerDefinitions[app].Image
+func flattenTaskDefinition(td ecstypes.TaskDefinition) (map[string]interface{}, error) {
+	raw, err := json.Marshal(td)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	for field := range taskDefinitionIdentityFields {
+		delete(doc, field)
+	}
+
+	values := make(map[string]interface{})
+	flattenValue("", doc, values)
+
+	return values, nil
+}
+
+// flattenValue adds every leaf of v to values
+// The SDK represents unset enums and numbers as "" and 0, so they are left out like null values and empty objects and lists
+func flattenValue(path string, v interface{}, values map[string]interface{}) {
+	switch value := v.(type) {
+	case nil:
+		return
+	case string:
+		if value != "" {
+			values[path] = value
+		}
+	case float64:
+		if value != 0 {
+			values[path] = value
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			flattenValue(joinPath(path, key), item, values)
+		}
+	case []interface{}:
+		names, ok := itemNames(value)
+
+		for idx, item := range value {
+			if ok {
+				flattenValue(fmt.Sprintf("%s[%s]", path, names[idx]), item, values)
+			} else {
+				flattenValue(fmt.Sprintf("%s[%d]", path, idx), item, values)
+			}
+		}
+	default:
+		values[path] = value
+	}
+}
+
+// itemNames returns the Name of every item if all of them are objects with a unique, non-empty Name
+func itemNames(items []interface{}) ([]string, bool) {
+	names := make([]string, len(items))
+	seen := make(map[string]bool)
+
+	for idx, item := range items {
+		obj, ok := item.(map[string]interface{})
+
+		if !ok {
+			return nil, false
+		}
+
+		name, ok := obj["Name"].(string)
+
+		if !ok || name == "" || seen[name] {
+			return nil, false
+		}
+
+		seen[name] = true
+		names[idx] = name
+	}
+
+	return names, true
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// encodeValues renders every value as JSON so values can be compared and printed
+func encodeValues(values map[string]interface{}) map[string]string {
+	lines := make(map[string]string, len(values))
+
+	for path, value := range values {
+		encoded, _ := json.Marshal(value)
+		lines[path] = string(encoded)
+	}
+
+	return lines
+}
+
+// unionKeys returns the sorted keys of both maps
+func unionKeys(a map[string]string, b map[string]string) []string {
+	var keys []string
+
+	for k := range a {
+		keys = append(keys, k)
+	}
+
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,113 @@
+package deploy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func diffTestTaskDefinition(revision int32, image string, env []ecstypes.KeyValuePair) ecstypes.TaskDefinition {
+	return ecstypes.TaskDefinition{
+		Family:            aws.String("webapp"),
+		Revision:          revision,
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-2:123456789012:task-definition/webapp:1"),
+		Cpu:               aws.String("256"),
+		ContainerDefinitions: []ecstypes.ContainerDefinition{
+			{Name: aws.String("app"), Image: aws.String(image), Environment: env},
+			{Name: aws.String("sidecar"), Image: aws.String("envoy:1")},
+		},
+	}
+}
+
+func TestDiffTaskDefinitions(t *testing.T) {
+	from := diffTestTaskDefinition(1, "app:1", []ecstypes.KeyValuePair{{Name: aws.String("A"), Value: aws.String("1")}})
+	to := diffTestTaskDefinition(2, "app:2", []ecstypes.KeyValuePair{{Name: aws.String("B"), Value: aws.String("2")}, {Name: aws.String("A"), Value: aws.String("1")}})
+
+	d, err := DiffTaskDefinitions(from, to)
+
+	assert.NilError(t, err)
+	assert.Equal(t, "webapp:1", d.From)
+	assert.Equal(t, "webapp:2", d.To)
+
+	// The revision and ARN always differ and environment variables are matched by name
+	want := []FieldChange{
+		{Path: "ContainerDefinitions[app].Environment[B].Name", New: "B"},
+		{Path: "ContainerDefinitions[app].Environment[B].Value", New: "2"},
+		{Path: "ContainerDefinitions[app].Image", Old: "app:1", New: "app:2"},
+	}
+
+	assert.DeepEqual(t, want, d.Changes)
+}
+
+func TestDiffTaskDefinitionsSame(t *testing.T) {
+	from := diffTestTaskDefinition(1, "app:1", nil)
+	to := diffTestTaskDefinition(5, "app:1", nil)
+
+	// Reordering containers is not a change
+	to.ContainerDefinitions[0], to.ContainerDefinitions[1] = to.ContainerDefinitions[1], to.ContainerDefinitions[0]
+
+	d, err := DiffTaskDefinitions(from, to)
+
+	assert.NilError(t, err)
+	assert.Assert(t, d.IsEmpty())
+	assert.Equal(t, "", d.Unified())
+}
+
+func TestTaskDefinitionDiffUnified(t *testing.T) {
+	from := diffTestTaskDefinition(1, "app:1", nil)
+	to := diffTestTaskDefinition(2, "app:2", nil)
+	to.Cpu = nil
+	to.Memory = aws.String("512")
+
+	d, err := DiffTaskDefinitions(from, to)
+
+	assert.NilError(t, err)
+
+	want := `--- webapp:1
++++ webapp:2
+@@ -1,6 +1,6 @@
+-ContainerDefinitions[app].Image: "app:1"
++ContainerDefinitions[app].Image: "app:2"
+ ContainerDefinitions[app].Name: "app"
+ ContainerDefinitions[sidecar].Image: "envoy:1"
+ ContainerDefinitions[sidecar].Name: "sidecar"
+-Cpu: "256"
+ Family: "webapp"
++Memory: "512"
+`
+
+	assert.Equal(t, want, d.Unified())
+}
+
+func TestTaskDefinitionDiffJSON(t *testing.T) {
+	d, err := DiffTaskDefinitions(diffTestTaskDefinition(1, "app:1", nil), diffTestTaskDefinition(2, "app:2", nil))
+
+	assert.NilError(t, err)
+
+	out, err := d.JSON()
+
+	assert.NilError(t, err)
+
+	var decoded TaskDefinitionDiff
+
+	assert.NilError(t, json.Unmarshal([]byte(out), &decoded))
+	assert.Equal(t, "webapp:1", decoded.From)
+	assert.DeepEqual(t, []FieldChange{{Path: "ContainerDefinitions[app].Image", Old: "app:1", New: "app:2"}}, decoded.Changes)
+}
+
+func TestPlannedTaskDefinition(t *testing.T) {
+	td := diffTestTaskDefinition(3, "app:1", nil)
+
+	i, _ := CloneTaskDefinition(td, nil)
+	i.Cpu = aws.String("512")
+
+	planned := PlannedTaskDefinition(td, i)
+
+	assert.Equal(t, "512", *planned.Cpu)
+	assert.Equal(t, int32(0), planned.Revision)
+	assert.Assert(t, planned.TaskDefinitionArn == nil)
+	assert.Equal(t, "webapp:new", taskDefinitionLabel(planned))
+}
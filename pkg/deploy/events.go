@@ -0,0 +1,104 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// describeServicesLimit is the most services DescribeServices accepts in one call
+const describeServicesLimit = 10
+
+// EventStream prints the ECS service events of every service involved in a deployment that happened after it started
+// Every event is printed once, however often Print is called. A nil EventStream prints nothing
+type EventStream struct {
+	Client   types.ECSClient
+	Cluster  string
+	Services []string
+	// Since is when the deployment started. Older events are not printed
+	Since time.Time
+
+	seen map[string]bool
+}
+
+// NewEventStream returns a stream of the events of services in cluster from now on
+func NewEventStream(c types.ECSClient, cluster string, services ...string) *EventStream {
+	return &EventStream{
+		Client:   c,
+		Cluster:  cluster,
+		Services: services,
+		Since:    time.Now(),
+		seen:     map[string]bool{},
+	}
+}
+
+// Print logs the events that have not been printed yet, oldest first
+// Errors are logged but not returned because events are only informational
+func (s *EventStream) Print(ctx context.Context) {
+	if s == nil || len(s.Services) == 0 {
+		return
+	}
+
+	services := s.Services
+
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+
+	type serviceEvent struct {
+		service string
+		event   ecstypes.ServiceEvent
+	}
+
+	var events []serviceEvent
+
+	for i := 0; i < len(services); i += describeServicesLimit {
+		end := i + describeServicesLimit
+
+		if end > len(services) {
+			end = len(services)
+		}
+
+		out, err := s.Client.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(s.Cluster),
+			Services: services[i:end],
+		})
+
+		if err != nil {
+			logging.From(ctx).Error("Error describing service events", logging.Err(err))
+			return
+		}
+
+		for _, service := range out.Services {
+			for _, event := range service.Events {
+				id := aws.ToString(event.Id)
+
+				if s.seen[id] || event.CreatedAt == nil || event.CreatedAt.Before(s.Since) {
+					continue
+				}
+
+				s.seen[id] = true
+				events = append(events, serviceEvent{service: aws.ToString(service.ServiceName), event: event})
+			}
+		}
+	}
+
+	// ECS returns the newest events first
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].event.CreatedAt.Before(*events[j].event.CreatedAt)
+	})
+
+	for _, e := range events {
+		logging.From(ctx).Info(
+			fmt.Sprintf("Service '%s' event at %s: %s", e.service, e.event.CreatedAt.UTC().Format(time.RFC3339), aws.ToString(e.event.Message)),
+			logging.Service(e.service),
+		)
+	}
+}
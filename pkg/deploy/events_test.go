@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func TestEventStreamPrint(t *testing.T) {
+	since := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	event := func(id string, minutes int, message string) ecstypes.ServiceEvent {
+		return ecstypes.ServiceEvent{
+			Id:        aws.String(id),
+			CreatedAt: aws.Time(since.Add(time.Duration(minutes) * time.Minute)),
+			Message:   aws.String(message),
+		}
+	}
+
+	c := MockECSClient{
+		TestingT: t,
+		// ECS returns the newest events first
+		ServiceEvents: []ecstypes.ServiceEvent{
+			event("3", 2, "has reached a steady state."),
+			event("2", 1, "was unable to place a task because no container instance met all of its requirements."),
+			event("1", -1, "has started 1 tasks"),
+		},
+	}
+
+	var out bytes.Buffer
+	ctx := logging.WithLogger(context.TODO(), logging.NewTextLogger(&out, logging.LevelInfo))
+
+	s := &EventStream{Client: c, Cluster: "test-cluster", Services: []string{"test-service"}, Since: since}
+
+	s.Print(ctx)
+	s.Print(ctx)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	assert.Equal(t, 2, len(lines), out.String())
+	assert.Assert(t, strings.Contains(lines[0], "2022-01-01T12:01:00Z: was unable to place a task"), lines[0])
+	assert.Assert(t, strings.Contains(lines[1], "has reached a steady state."), lines[1])
+
+	var nilStream *EventStream
+	nilStream.Print(context.TODO())
+}
@@ -0,0 +1,63 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type MockCloudWatchClient struct {
+	WantError bool
+	// AlarmStates maps alarm names to their state
+	AlarmStates map[string]cwtypes.StateValue
+	// Checks counts every DescribeAlarms call when set
+	Checks *int
+	// MetricData records every PutMetricData call when set
+	MetricData *[]*cloudwatch.PutMetricDataInput
+}
+
+func (c MockCloudWatchClient) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	if c.Checks != nil {
+		*c.Checks++
+	}
+
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	out := cloudwatch.DescribeAlarmsOutput{}
+
+	for name, state := range c.AlarmStates {
+		matches := params.AlarmNamePrefix != nil && strings.HasPrefix(name, *params.AlarmNamePrefix)
+
+		for _, n := range params.AlarmNames {
+			matches = matches || n == name
+		}
+
+		if matches {
+			out.MetricAlarms = append(out.MetricAlarms, cwtypes.MetricAlarm{
+				AlarmName:   aws.String(name),
+				StateValue:  state,
+				StateReason: aws.String("Threshold crossed"),
+			})
+		}
+	}
+
+	return &out, nil
+}
+
+func (c MockCloudWatchClient) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.MetricData != nil {
+		*c.MetricData = append(*c.MetricData, params)
+	}
+
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
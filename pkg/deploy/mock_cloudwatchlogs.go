@@ -0,0 +1,41 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+type MockCloudWatchLogsClient struct {
+	WantError bool
+	// Streams maps "group/stream" to the messages of the stream, oldest first
+	Streams map[string][]string
+}
+
+func (c MockCloudWatchLogsClient) GetLogEvents(ctx context.Context, params *cloudwatchlogs.GetLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	messages, ok := c.Streams[aws.ToString(params.LogGroupName)+"/"+aws.ToString(params.LogStreamName)]
+
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException: The specified log stream does not exist")
+	}
+
+	// Without StartFromHead the newest events are returned
+	if params.Limit != nil && int(*params.Limit) < len(messages) {
+		messages = messages[len(messages)-int(*params.Limit):]
+	}
+
+	out := cloudwatchlogs.GetLogEventsOutput{}
+
+	for _, m := range messages {
+		out.Events = append(out.Events, cwltypes.OutputLogEvent{Message: aws.String(m)})
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,95 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdtypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+)
+
+type MockCodeDeployClient struct {
+	TestingT         *testing.T
+	WantError        bool
+	DeploymentStatus cdtypes.DeploymentStatus
+	LifecycleEvents  []cdtypes.LifecycleEvent
+	// CreatedDeployments records every CreateDeployment call when set
+	CreatedDeployments *[]*codedeploy.CreateDeploymentInput
+	// StoppedDeployments records every StopDeployment call when set
+	StoppedDeployments *[]*codedeploy.StopDeploymentInput
+}
+
+func (c MockCodeDeployClient) CreateDeployment(ctx context.Context, params *codedeploy.CreateDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.CreateDeploymentOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.CreatedDeployments != nil {
+		*c.CreatedDeployments = append(*c.CreatedDeployments, params)
+	}
+
+	return &codedeploy.CreateDeploymentOutput{DeploymentId: aws.String("d-TESTDEPLOY")}, nil
+}
+
+func (c MockCodeDeployClient) GetDeployment(ctx context.Context, params *codedeploy.GetDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.GetDeploymentOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	info := cdtypes.DeploymentInfo{
+		DeploymentId: params.DeploymentId,
+		Status:       c.DeploymentStatus,
+	}
+
+	if c.DeploymentStatus == cdtypes.DeploymentStatusFailed {
+		info.ErrorInformation = &cdtypes.ErrorInformation{
+			Code:    cdtypes.ErrorCodeEcsUpdateError,
+			Message: aws.String("The ECS service cannot be updated"),
+		}
+	}
+
+	return &codedeploy.GetDeploymentOutput{DeploymentInfo: &info}, nil
+}
+
+func (c MockCodeDeployClient) StopDeployment(ctx context.Context, params *codedeploy.StopDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.StopDeploymentOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.StoppedDeployments != nil {
+		*c.StoppedDeployments = append(*c.StoppedDeployments, params)
+	}
+
+	return &codedeploy.StopDeploymentOutput{Status: cdtypes.StopStatusPending}, nil
+}
+
+func (c MockCodeDeployClient) ListDeploymentTargets(ctx context.Context, params *codedeploy.ListDeploymentTargetsInput, optFns ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentTargetsOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	return &codedeploy.ListDeploymentTargetsOutput{TargetIds: []string{"test-cluster:test-service"}}, nil
+}
+
+func (c MockCodeDeployClient) BatchGetDeploymentTargets(ctx context.Context, params *codedeploy.BatchGetDeploymentTargetsInput, optFns ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentTargetsOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	out := codedeploy.BatchGetDeploymentTargetsOutput{
+		DeploymentTargets: []cdtypes.DeploymentTarget{
+			{
+				DeploymentTargetType: cdtypes.DeploymentTargetTypeEcsTarget,
+				EcsTarget: &cdtypes.ECSTarget{
+					DeploymentId:    params.DeploymentId,
+					TargetId:        aws.String(params.TargetIds[0]),
+					LifecycleEvents: c.LifecycleEvents,
+				},
+			},
+		},
+	}
+
+	return &out, nil
+}
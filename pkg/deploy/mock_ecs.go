@@ -3,6 +3,9 @@ package deploy
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,14 +16,53 @@ import (
 )
 
 const (
-	testTDARN string = "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"
+	testTDARN      string = "arn:aws:ecs:us-west-2:123456789012:task-definition/amazon-ecs-sample:1"
+	testServiceARN string = "arn:aws:ecs:us-west-2:123456789012:service/test-cluster/test-service"
 )
 
 type MockECSClient struct {
 	DeploymentState ecstypes.DeploymentRolloutState
 	FailedTasks     int32
-	TestingT        *testing.T
-	WantError       bool
+	// RunningCount is the running count of the service and its deployment returned by DescribeServices
+	RunningCount int32
+	TestingT     *testing.T
+	WantError    bool
+	// TaskDefinition overrides the task definition returned by DescribeTaskDefinition
+	TaskDefinition *ecstypes.TaskDefinition
+	// TaskDefinitions overrides the task definition returned by DescribeTaskDefinition for each ARN or family:revision
+	TaskDefinitions map[string]ecstypes.TaskDefinition
+	// TaskDefinitionTags are returned by DescribeTaskDefinition when tags are requested
+	TaskDefinitionTags []ecstypes.Tag
+	// LoadBalancers are returned with every service by DescribeServices
+	LoadBalancers []ecstypes.LoadBalancer
+	// NetworkConfiguration is returned with every service by DescribeServices
+	NetworkConfiguration *ecstypes.NetworkConfiguration
+	// CapacityProviderStrategy is returned with every service by DescribeServices
+	CapacityProviderStrategy []ecstypes.CapacityProviderStrategyItem
+	// ServiceEvents are returned with every service by DescribeServices
+	ServiceEvents []ecstypes.ServiceEvent
+	// TaskSets are returned with every service by DescribeServices
+	TaskSets []ecstypes.TaskSet
+	// TaskSetStabilityStatus is the stability status of task sets returned by DescribeTaskSets
+	TaskSetStabilityStatus ecstypes.StabilityStatus
+	// TaskSetCalls records every task set API call and the task set it modified when set
+	TaskSetCalls *[]string
+	// Tasks overrides the tasks listed by ListTasks and returned by DescribeTasks
+	Tasks []ecstypes.Task
+	// ContainerInstances maps the ARN of every container instance returned by DescribeContainerInstances to its EC2 instance ID
+	ContainerInstances map[string]string
+	// RunTasks records every RunTask call when set
+	RunTasks *[]*ecs.RunTaskInput
+	// StoppedTasks records every StopTask call when set
+	StoppedTasks *[]string
+	// RegisteredTaskDefinitions records every RegisterTaskDefinition call when set
+	RegisteredTaskDefinitions *[]*ecs.RegisterTaskDefinitionInput
+	// UpdatedServices records the service of every UpdateService call when set
+	UpdatedServices *[]string
+	// TaskDefinitionARNs are returned by ListTaskDefinitions, newest first
+	TaskDefinitionARNs []string
+	// ResourceTags holds the tags of every resource for TagResource, UntagResource and ListTagsForResource when set
+	ResourceTags *map[string]string
 }
 
 func (c MockECSClient) DescribeTaskDefinition(ctx context.Context, params *ecs.DescribeTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error) {
@@ -41,16 +83,34 @@ func (c MockECSClient) DescribeTaskDefinition(ctx context.Context, params *ecs.D
 		},
 	}
 
+	if c.TaskDefinition != nil {
+		td = *c.TaskDefinition
+	}
+
+	if named, ok := c.TaskDefinitions[aws.ToString(params.TaskDefinition)]; ok {
+		td = named
+	}
+
 	out := ecs.DescribeTaskDefinitionOutput{
 		TaskDefinition: &td,
 	}
 
+	for _, field := range params.Include {
+		if field == ecstypes.TaskDefinitionFieldTags {
+			out.Tags = c.TaskDefinitionTags
+		}
+	}
+
 	return &out, nil
 }
 
 func (c MockECSClient) RegisterTaskDefinition(ctx context.Context, params *ecs.RegisterTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.RegisterTaskDefinitionOutput, error) {
+	if c.RegisteredTaskDefinitions != nil {
+		*c.RegisteredTaskDefinitions = append(*c.RegisteredTaskDefinitions, params)
+	}
+
 	out := ecs.RegisterTaskDefinitionOutput{
-		Tags: []ecstypes.Tag{},
+		Tags: params.Tags,
 		TaskDefinition: &ecstypes.TaskDefinition{
 			Compatibilities:         []ecstypes.Compatibility{},
 			ContainerDefinitions:    params.ContainerDefinitions,
@@ -66,6 +126,7 @@ func (c MockECSClient) RegisterTaskDefinition(ctx context.Context, params *ecs.R
 			PlacementConstraints:    params.PlacementConstraints,
 			ProxyConfiguration:      params.ProxyConfiguration,
 			RequiresCompatibilities: params.RequiresCompatibilities,
+			RuntimePlatform:         params.RuntimePlatform,
 			Status:                  ecstypes.TaskDefinitionStatus(c.DeploymentState),
 			TaskDefinitionArn:       aws.String(testTDARN),
 			TaskRoleArn:             params.TaskRoleArn,
@@ -76,6 +137,22 @@ func (c MockECSClient) RegisterTaskDefinition(ctx context.Context, params *ecs.R
 	return &out, nil
 }
 
+func (c MockECSClient) ListTaskDefinitions(ctx context.Context, params *ecs.ListTaskDefinitionsInput, optFns ...func(*ecs.Options)) (*ecs.ListTaskDefinitionsOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	var arns []string
+
+	for _, arn := range c.TaskDefinitionARNs {
+		if strings.Contains(arn, "task-definition/"+aws.ToString(params.FamilyPrefix)) {
+			arns = append(arns, arn)
+		}
+	}
+
+	return &ecs.ListTaskDefinitionsOutput{TaskDefinitionArns: arns}, nil
+}
+
 func (c MockECSClient) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
 
 	assert.Equal(c.TestingT, *params.Cluster, "test-cluster")
@@ -88,16 +165,24 @@ func (c MockECSClient) DescribeServices(ctx context.Context, params *ecs.Describ
 			RolloutState: c.DeploymentState,
 			Status:       aws.String("PRIMARY"),
 			FailedTasks:  c.FailedTasks,
+			RunningCount: c.RunningCount,
 		},
 	}
 
 	s := []ecstypes.Service{
 		{
-			ServiceName:    aws.String("test-cluster"),
-			Status:         aws.String("ACTIVE"),
-			Deployments:    d,
-			TaskDefinition: aws.String(testTDARN),
-			DesiredCount:   2,
+			ServiceName:              aws.String("test-cluster"),
+			ServiceArn:               aws.String(testServiceARN),
+			Status:                   aws.String("ACTIVE"),
+			Deployments:              d,
+			TaskDefinition:           aws.String(testTDARN),
+			DesiredCount:             2,
+			RunningCount:             c.RunningCount,
+			CapacityProviderStrategy: c.CapacityProviderStrategy,
+			LoadBalancers:            c.LoadBalancers,
+			NetworkConfiguration:     c.NetworkConfiguration,
+			TaskSets:                 c.TaskSets,
+			Events:                   c.ServiceEvents,
 		},
 	}
 
@@ -110,6 +195,9 @@ func (c MockECSClient) DescribeServices(ctx context.Context, params *ecs.Describ
 }
 
 func (c MockECSClient) UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error) {
+	if c.UpdatedServices != nil {
+		*c.UpdatedServices = append(*c.UpdatedServices, *params.Service)
+	}
 
 	if c.WantError {
 		return nil, errors.New("error")
@@ -171,19 +259,65 @@ func (c MockECSClient) UpdateService(ctx context.Context, params *ecs.UpdateServ
 	return &out, nil
 }
 
+// ListTasks lists the ARNs of Tasks if it is set, 100 a page like ECS
 func (c MockECSClient) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	arns := []string{"arn-1", "arn-2"}
+
+	if c.Tasks != nil {
+		arns = nil
+
+		for _, task := range c.Tasks {
+			arns = append(arns, aws.ToString(task.TaskArn))
+		}
+	}
+
+	start := 0
+
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(*params.NextToken)
+	}
+
+	end := start + describeTasksLimit
+
+	if end > len(arns) {
+		end = len(arns)
+	}
+
 	output := &ecs.ListTasksOutput{
-		NextToken: aws.String("FOO"),
-		TaskArns: []string{
-			"arn-1",
-			"arn-2",
-		},
+		TaskArns: arns[start:end],
+	}
+
+	if end < len(arns) {
+		output.NextToken = aws.String(strconv.Itoa(end))
 	}
 
 	return output, nil
 }
 
+// DescribeTasks returns the tasks of Tasks that are asked for if it is set
 func (c MockECSClient) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	if len(params.Tasks) > describeTasksLimit {
+		return nil, fmt.Errorf("at most %d tasks can be described, not %d", describeTasksLimit, len(params.Tasks))
+	}
+
+	if c.Tasks != nil {
+		asked := map[string]bool{}
+
+		for _, arn := range params.Tasks {
+			asked[arn] = true
+		}
+
+		var tasks []ecstypes.Task
+
+		for _, task := range c.Tasks {
+			if asked[aws.ToString(task.TaskArn)] {
+				tasks = append(tasks, task)
+			}
+		}
+
+		return &ecs.DescribeTasksOutput{Tasks: tasks}, nil
+	}
+
 	output := &ecs.DescribeTasksOutput{
 		Tasks: []ecstypes.Task{
 			{
@@ -199,3 +333,178 @@ func (c MockECSClient) DescribeTasks(ctx context.Context, params *ecs.DescribeTa
 
 	return output, nil
 }
+
+func (c MockECSClient) DescribeContainerInstances(ctx context.Context, params *ecs.DescribeContainerInstancesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeContainerInstancesOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	out := ecs.DescribeContainerInstancesOutput{}
+
+	for _, arn := range params.ContainerInstances {
+		if id, ok := c.ContainerInstances[arn]; ok {
+			out.ContainerInstances = append(out.ContainerInstances, ecstypes.ContainerInstance{
+				ContainerInstanceArn: aws.String(arn),
+				Ec2InstanceId:        aws.String(id),
+			})
+		}
+	}
+
+	return &out, nil
+}
+
+func (c MockECSClient) recordTaskSetCall(call string, taskSet string) {
+	if c.TaskSetCalls != nil {
+		*c.TaskSetCalls = append(*c.TaskSetCalls, call+":"+taskSet)
+	}
+}
+
+func (c MockECSClient) CreateTaskSet(ctx context.Context, params *ecs.CreateTaskSetInput, optFns ...func(*ecs.Options)) (*ecs.CreateTaskSetOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	c.recordTaskSetCall("CreateTaskSet", "ts-new")
+
+	out := ecs.CreateTaskSetOutput{
+		TaskSet: &ecstypes.TaskSet{
+			Id:                   aws.String("ts-new"),
+			Status:               aws.String("ACTIVE"),
+			TaskDefinition:       params.TaskDefinition,
+			LaunchType:           params.LaunchType,
+			LoadBalancers:        params.LoadBalancers,
+			NetworkConfiguration: params.NetworkConfiguration,
+			Scale:                params.Scale,
+		},
+	}
+
+	return &out, nil
+}
+
+func (c MockECSClient) DescribeTaskSets(ctx context.Context, params *ecs.DescribeTaskSetsInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTaskSetsOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	out := ecs.DescribeTaskSetsOutput{}
+
+	for _, id := range params.TaskSets {
+		out.TaskSets = append(out.TaskSets, ecstypes.TaskSet{
+			Id:                   aws.String(id),
+			Status:               aws.String("ACTIVE"),
+			StabilityStatus:      c.TaskSetStabilityStatus,
+			ComputedDesiredCount: 2,
+			RunningCount:         2,
+		})
+	}
+
+	return &out, nil
+}
+
+func (c MockECSClient) UpdateTaskSet(ctx context.Context, params *ecs.UpdateTaskSetInput, optFns ...func(*ecs.Options)) (*ecs.UpdateTaskSetOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	c.recordTaskSetCall("UpdateTaskSet", *params.TaskSet)
+
+	return &ecs.UpdateTaskSetOutput{TaskSet: &ecstypes.TaskSet{Id: params.TaskSet, Scale: params.Scale}}, nil
+}
+
+func (c MockECSClient) UpdateServicePrimaryTaskSet(ctx context.Context, params *ecs.UpdateServicePrimaryTaskSetInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServicePrimaryTaskSetOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	c.recordTaskSetCall("UpdateServicePrimaryTaskSet", *params.PrimaryTaskSet)
+
+	return &ecs.UpdateServicePrimaryTaskSetOutput{TaskSet: &ecstypes.TaskSet{Id: params.PrimaryTaskSet, Status: aws.String("PRIMARY")}}, nil
+}
+
+func (c MockECSClient) DeleteTaskSet(ctx context.Context, params *ecs.DeleteTaskSetInput, optFns ...func(*ecs.Options)) (*ecs.DeleteTaskSetOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	c.recordTaskSetCall("DeleteTaskSet", *params.TaskSet)
+
+	return &ecs.DeleteTaskSetOutput{TaskSet: &ecstypes.TaskSet{Id: params.TaskSet, Status: aws.String("DRAINING")}}, nil
+}
+
+func (c MockECSClient) RunTask(ctx context.Context, params *ecs.RunTaskInput, optFns ...func(*ecs.Options)) (*ecs.RunTaskOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.RunTasks != nil {
+		*c.RunTasks = append(*c.RunTasks, params)
+	}
+
+	out := ecs.RunTaskOutput{
+		Tasks: []ecstypes.Task{
+			{
+				TaskArn:           aws.String("arn-run-task"),
+				TaskDefinitionArn: params.TaskDefinition,
+				LastStatus:        aws.String("PROVISIONING"),
+			},
+		},
+	}
+
+	return &out, nil
+}
+
+func (c MockECSClient) StopTask(ctx context.Context, params *ecs.StopTaskInput, optFns ...func(*ecs.Options)) (*ecs.StopTaskOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.StoppedTasks != nil {
+		*c.StoppedTasks = append(*c.StoppedTasks, *params.Task)
+	}
+
+	return &ecs.StopTaskOutput{Task: &ecstypes.Task{TaskArn: params.Task, LastStatus: aws.String("STOPPED")}}, nil
+}
+
+func (c MockECSClient) TagResource(ctx context.Context, params *ecs.TagResourceInput, optFns ...func(*ecs.Options)) (*ecs.TagResourceOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.ResourceTags != nil {
+		for _, tag := range params.Tags {
+			(*c.ResourceTags)[*tag.Key] = aws.ToString(tag.Value)
+		}
+	}
+
+	return &ecs.TagResourceOutput{}, nil
+}
+
+func (c MockECSClient) UntagResource(ctx context.Context, params *ecs.UntagResourceInput, optFns ...func(*ecs.Options)) (*ecs.UntagResourceOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	if c.ResourceTags != nil {
+		for _, key := range params.TagKeys {
+			delete(*c.ResourceTags, key)
+		}
+	}
+
+	return &ecs.UntagResourceOutput{}, nil
+}
+
+func (c MockECSClient) ListTagsForResource(ctx context.Context, params *ecs.ListTagsForResourceInput, optFns ...func(*ecs.Options)) (*ecs.ListTagsForResourceOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	out := ecs.ListTagsForResourceOutput{}
+
+	if c.ResourceTags != nil {
+		for key, value := range *c.ResourceTags {
+			out.Tags = append(out.Tags, ecstypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,26 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+type MockELBv2Client struct {
+	WantError bool
+	// TargetHealth maps target group ARNs to the health of their targets
+	TargetHealth map[string][]elbtypes.TargetHealthDescription
+}
+
+func (c MockELBv2Client) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	return &elasticloadbalancingv2.DescribeTargetHealthOutput{
+		TargetHealthDescriptions: c.TargetHealth[aws.ToString(params.TargetGroupArn)],
+	}, nil
+}
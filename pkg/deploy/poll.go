@@ -0,0 +1,145 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
+)
+
+// PhaseBlueScaleDown is the phase of every step of scaling blue down in a blue / green deploy
+const PhaseBlueScaleDown = "blue service to finish scaling down"
+
+// DefaultCleanupTimeout bounds cleanup when neither PhaseTimeout nor CleanupTimeout is set
+const DefaultCleanupTimeout = 10 * time.Minute
+
+// ErrPhaseTimeout is returned by Poll when a phase runs out of checks or exceeds its timeout
+var ErrPhaseTimeout = errors.New("phase timed out")
+
+// Poller repeatedly checks whether a phase of a deployment, such as a service reaching a steady state, has finished
+// The overall deploy deadline is carried by the context passed to Poll
+type Poller struct {
+	// Interval is the time to wait before every check
+	Interval time.Duration
+	// PhaseTimeout limits how long a single phase may take. Zero means no limit
+	PhaseTimeout time.Duration
+	// MaxChecks fails a phase once more than MaxChecks checks have not finished it. A negative value means no limit
+	MaxChecks int
+	// CleanupTimeout bounds cleanup when PhaseTimeout is not set. Zero means DefaultCleanupTimeout
+	CleanupTimeout time.Duration
+	// Observer is told how long every phase took, how many checks it used and how it ended. Nothing is observed if it is nil
+	Observer PhaseObserver
+}
+
+// PhaseObserver records the phases of a deployment
+type PhaseObserver interface {
+	ObservePhase(phase string, start time.Time, d time.Duration, checks int, err error)
+}
+
+// CheckFunc returns true once a phase has finished. Returning an error stops polling
+type CheckFunc func(ctx context.Context) (bool, error)
+
+// Poll waits Interval before every call to check until check returns true or an error
+// It returns ErrPhaseTimeout if the phase runs out of checks or time, and the context error if ctx is cancelled or its deadline passes
+// The phase is traced as a span, with a child span for every check
+func (p Poller) Poll(ctx context.Context, phase string, check CheckFunc) error {
+	ctx, span := tracing.Start(ctx, phase, tracing.String("deploy.phase", phase))
+	ctx = logging.With(ctx, logging.Phase(phase))
+
+	start := time.Now()
+	checks, err := p.poll(ctx, phase, check)
+
+	span.SetAttributes(tracing.Int("deploy.checks", checks))
+	span.End(err)
+
+	if p.Observer != nil {
+		p.Observer.ObservePhase(phase, start, time.Since(start), checks, err)
+	}
+
+	return err
+}
+
+// poll returns the number of checks that were made
+func (p Poller) poll(ctx context.Context, phase string, check CheckFunc) (int, error) {
+	phaseCtx := ctx
+
+	if p.PhaseTimeout > 0 {
+		var cancel context.CancelFunc
+		phaseCtx, cancel = context.WithTimeout(ctx, p.PhaseTimeout)
+		defer cancel()
+	}
+
+	for checks := 0; ; checks++ {
+		if p.MaxChecks >= 0 && checks > p.MaxChecks {
+			logging.From(ctx).Error(fmt.Sprintf("Reached max check limit waiting for %s", phase))
+			return checks, fmt.Errorf("%s: %w", phase, ErrPhaseTimeout)
+		}
+
+		logging.From(ctx).Info(fmt.Sprintf("Waiting for %s. Check number: %d", phase, checks), logging.F("check", checks))
+
+		if err := Sleep(phaseCtx, p.Interval); err != nil {
+			return checks, p.contextError(ctx, phase)
+		}
+
+		checkCtx, span := tracing.Start(phaseCtx, "check", tracing.Int("deploy.check", checks))
+		finished, err := check(checkCtx)
+		span.End(err)
+
+		if err != nil {
+			// A check that failed because the phase ran out of time is a timeout, not a failure of the deployment
+			if phaseCtx.Err() != nil {
+				return checks + 1, p.contextError(ctx, phase)
+			}
+
+			return checks + 1, err
+		}
+
+		if finished {
+			return checks + 1, nil
+		}
+	}
+}
+
+// contextError explains why a phase context was done. ctx is the parent context that carries the deploy deadline
+func (p Poller) contextError(ctx context.Context, phase string) error {
+	if ctx.Err() != nil {
+		logging.From(ctx).Error(fmt.Sprintf("Deploy deadline passed or the deploy was cancelled while waiting for %s", phase))
+		return fmt.Errorf("%s: %w", phase, ctx.Err())
+	}
+
+	logging.From(ctx).Error(fmt.Sprintf("Timed out after %s waiting for %s", p.PhaseTimeout, phase))
+	return fmt.Errorf("%s: %w", phase, ErrPhaseTimeout)
+}
+
+// CleanupContext returns a context for rolling back or cleaning up after a failed phase
+// It keeps the trace of ctx but not its deadline, so cleanup still runs after the deploy deadline has passed
+// It is always bounded, by PhaseTimeout, or CleanupTimeout or DefaultCleanupTimeout if it is not set, so a stuck rollback can not hang the deploy
+func (p Poller) CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := p.PhaseTimeout
+
+	if timeout <= 0 {
+		timeout = p.CleanupTimeout
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultCleanupTimeout
+	}
+
+	return context.WithTimeout(tracing.Detach(ctx), timeout)
+}
+
+// Sleep pauses for d, returning early with the context error if ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
+	"gotest.tools/assert"
+)
+
+func TestPollerPoll(t *testing.T) {
+	errCheck := errors.New("check failed")
+
+	tests := []struct {
+		name       string
+		poller     Poller
+		finishedAt int
+		checkErr   error
+		wantErr    error
+		wantChecks int
+	}{
+		{
+			name:       "finishes",
+			poller:     Poller{Interval: time.Millisecond, MaxChecks: 5},
+			finishedAt: 3,
+			wantChecks: 3,
+		},
+		{
+			name:       "max-checks",
+			poller:     Poller{Interval: time.Millisecond, MaxChecks: 2},
+			finishedAt: 10,
+			wantErr:    ErrPhaseTimeout,
+			wantChecks: 3,
+		},
+		{
+			name:       "phase-timeout",
+			poller:     Poller{Interval: 20 * time.Millisecond, PhaseTimeout: 50 * time.Millisecond, MaxChecks: -1},
+			finishedAt: 10,
+			wantErr:    ErrPhaseTimeout,
+			wantChecks: 2,
+		},
+		{
+			name:       "check-error",
+			poller:     Poller{Interval: time.Millisecond, MaxChecks: 5},
+			finishedAt: 10,
+			checkErr:   errCheck,
+			wantErr:    errCheck,
+			wantChecks: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := 0
+
+			err := tt.poller.Poll(context.Background(), "test", func(ctx context.Context) (bool, error) {
+				checks++
+				return checks >= tt.finishedAt, tt.checkErr
+			})
+
+			assert.Assert(t, errors.Is(err, tt.wantErr), "got error %v, want %v", err, tt.wantErr)
+			assert.Equal(t, tt.wantChecks, checks)
+		})
+	}
+}
+
+func TestPollerPollDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	p := Poller{Interval: 20 * time.Millisecond, MaxChecks: -1}
+
+	err := p.Poll(ctx, "test", func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded), "got error %v", err)
+	assert.Assert(t, !errors.Is(err, ErrPhaseTimeout))
+}
+
+func TestPollerCleanupContext(t *testing.T) {
+	p := Poller{PhaseTimeout: time.Minute}
+
+	ctx, cancel := p.CleanupContext(context.TODO())
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+
+	assert.Assert(t, ok)
+	assert.Assert(t, time.Until(deadline) <= time.Minute)
+
+	ctx, cancel = Poller{CleanupTimeout: 30 * time.Minute}.CleanupContext(context.TODO())
+	defer cancel()
+
+	deadline, ok = ctx.Deadline()
+
+	assert.Assert(t, ok)
+	assert.Assert(t, time.Until(deadline) > time.Minute && time.Until(deadline) <= 30*time.Minute)
+
+	// Cleanup is bounded even if no timeout is set
+	ctx, cancel = Poller{MaxChecks: -1}.CleanupContext(context.TODO())
+	defer cancel()
+
+	deadline, ok = ctx.Deadline()
+
+	assert.Assert(t, ok)
+	assert.Assert(t, time.Until(deadline) <= DefaultCleanupTimeout)
+}
+
+// spanRecorder keeps every exported span
+type spanRecorder struct {
+	spans []*tracing.Span
+}
+
+func (r *spanRecorder) Export(ctx context.Context, resource []tracing.Attribute, spans []*tracing.Span) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func TestPollerPollTracing(t *testing.T) {
+	r := &spanRecorder{}
+	tracer := tracing.NewTracer(r, "drone-deploy-ecs")
+	ctx := tracing.WithTracer(context.TODO(), tracer)
+
+	checks := 0
+
+	err := Poller{Interval: time.Millisecond, MaxChecks: 5}.Poll(ctx, "deployment to complete", func(ctx context.Context) (bool, error) {
+		assert.Assert(t, tracing.SpanFromContext(ctx) != nil)
+
+		checks++
+		return checks == 2, nil
+	})
+
+	assert.NilError(t, err)
+	assert.NilError(t, tracer.Flush(context.TODO()))
+
+	assert.Equal(t, 3, len(r.spans))
+	assert.Equal(t, "check", r.spans[0].Name)
+	assert.Equal(t, "check", r.spans[1].Name)
+
+	phase := r.spans[2]
+	assert.Equal(t, "deployment to complete", phase.Name)
+	assert.Equal(t, phase.SpanID, r.spans[0].ParentID)
+	assert.DeepEqual(t, []tracing.Attribute{tracing.String("deploy.phase", "deployment to complete"), tracing.Int("deploy.checks", 2)}, phase.Attributes)
+}
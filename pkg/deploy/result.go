@@ -0,0 +1,297 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+
+	RollbackSucceeded = "succeeded"
+	RollbackFailed    = "failed"
+)
+
+// Result describes what a deploy did, for pipeline steps that run after it
+// Every method of a nil Result does nothing, so deploys that don't write a result don't need to check
+type Result struct {
+	Mode     string   `json:"mode"`
+	DryRun   bool     `json:"dry_run"`
+	Cluster  string   `json:"cluster"`
+	Services []string `json:"services"`
+	// PreviousTaskDefinition is the revision the services ran before the deploy
+	PreviousTaskDefinition *TaskDefinitionResult `json:"previous_task_definition,omitempty"`
+	// TaskDefinition is the revision the deploy released
+	TaskDefinition *TaskDefinitionResult `json:"task_definition,omitempty"`
+	Deployments    []DeploymentResult    `json:"deployments,omitempty"`
+	// BlueService is the service that was live before a blue / green deploy and GreenService is the service it deployed to
+	BlueService  string `json:"blue_service,omitempty"`
+	GreenService string `json:"green_service,omitempty"`
+	// LiveService is the service of a blue / green deploy that serves traffic once the deploy finishes
+	LiveService string `json:"live_service,omitempty"`
+	// LiveColor is the live color of a blue / green cluster deploy once it finishes
+	LiveColor  string        `json:"live_color,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Phases     []PhaseResult `json:"phases"`
+	Outcome    string        `json:"outcome"`
+	Error      string        `json:"error,omitempty"`
+	// FailureCategory is the kind of failure of a deployment that was classified, such as placement
+	FailureCategory string `json:"failure_category,omitempty"`
+	// Rollback is empty if nothing was rolled back, or whether the rollback succeeded or failed
+	Rollback string `json:"rollback,omitempty"`
+	// Rollbacks counts the rollbacks of the deploy
+	Rollbacks int `json:"rollbacks"`
+	// FirstHealthyTaskSeconds maps services to how long after the deploy started the first task of the new revision was healthy
+	FirstHealthyTaskSeconds map[string]float64 `json:"first_healthy_task_seconds,omitempty"`
+}
+
+type TaskDefinitionResult struct {
+	ARN      string `json:"arn"`
+	Family   string `json:"family"`
+	Revision int32  `json:"revision"`
+	// Images maps container names to their image
+	Images map[string]string `json:"images,omitempty"`
+}
+
+type DeploymentResult struct {
+	Service string `json:"service"`
+	ID      string `json:"id"`
+}
+
+type PhaseResult struct {
+	Name            string    `json:"name"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Checks          int       `json:"checks"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// NewResult starts the result of a deploy
+func NewResult(mode string, cluster string, services []string, dryRun bool) *Result {
+	return &Result{
+		Mode:      mode,
+		DryRun:    dryRun,
+		Cluster:   cluster,
+		Services:  services,
+		StartedAt: time.Now().UTC(),
+		Phases:    []PhaseResult{},
+	}
+}
+
+func taskDefinitionResult(td ecstypes.TaskDefinition) *TaskDefinitionResult {
+	images := map[string]string{}
+
+	for _, c := range td.ContainerDefinitions {
+		images[aws.ToString(c.Name)] = aws.ToString(c.Image)
+	}
+
+	return &TaskDefinitionResult{
+		ARN:      aws.ToString(td.TaskDefinitionArn),
+		Family:   aws.ToString(td.Family),
+		Revision: td.Revision,
+		Images:   images,
+	}
+}
+
+// SetTaskDefinitions records the revision a deploy replaced and the revision it released
+func (r *Result) SetTaskDefinitions(previous ecstypes.TaskDefinition, next ecstypes.TaskDefinition) {
+	if r == nil {
+		return
+	}
+
+	r.PreviousTaskDefinition = taskDefinitionResult(previous)
+	r.TaskDefinition = taskDefinitionResult(next)
+}
+
+// AddDeployment records the ID of an ECS or CodeDeploy deployment of service
+func (r *Result) AddDeployment(service string, id string) {
+	if r == nil {
+		return
+	}
+
+	r.Deployments = append(r.Deployments, DeploymentResult{Service: service, ID: id})
+}
+
+// SetBlueGreen records which service was blue and which was green
+func (r *Result) SetBlueGreen(blue string, green string) {
+	if r == nil {
+		return
+	}
+
+	r.BlueService = blue
+	r.GreenService = green
+}
+
+// SetLive records the service, or color, that is live once the deploy finishes
+func (r *Result) SetLive(service string, color string) {
+	if r == nil {
+		return
+	}
+
+	r.LiveService = service
+	r.LiveColor = color
+}
+
+// SetRollback counts a rollback and records whether it succeeded. A failed rollback is never overwritten by a later successful one
+func (r *Result) SetRollback(ok bool) {
+	if r == nil {
+		return
+	}
+
+	r.Rollbacks++
+
+	if r.Rollback == RollbackFailed {
+		return
+	}
+
+	r.Rollback = RollbackSucceeded
+
+	if !ok {
+		r.Rollback = RollbackFailed
+	}
+}
+
+// SetFirstHealthyTask records when the first task of the new revision of service was healthy. Only the first time is kept
+func (r *Result) SetFirstHealthyTask(service string, at time.Time) {
+	if r == nil {
+		return
+	}
+
+	if r.FirstHealthyTaskSeconds == nil {
+		r.FirstHealthyTaskSeconds = map[string]float64{}
+	}
+
+	if _, ok := r.FirstHealthyTaskSeconds[service]; !ok {
+		r.FirstHealthyTaskSeconds[service] = at.Sub(r.StartedAt).Seconds()
+	}
+}
+
+// SetFailureCategory records the kind of a classified deployment failure. Only the first failure is kept
+func (r *Result) SetFailureCategory(category FailureCategory) {
+	if r == nil || r.FailureCategory != "" {
+		return
+	}
+
+	r.FailureCategory = string(category)
+}
+
+// ObservePhase records a phase. It makes a Result a PhaseObserver
+func (r *Result) ObservePhase(phase string, start time.Time, d time.Duration, checks int, err error) {
+	if r == nil {
+		return
+	}
+
+	p := PhaseResult{Name: phase, StartedAt: start.UTC(), DurationSeconds: d.Seconds(), Checks: checks}
+
+	if err != nil {
+		p.Error = err.Error()
+	}
+
+	r.Phases = append(r.Phases, p)
+}
+
+// Finish records the outcome of the deploy
+func (r *Result) Finish(err error) {
+	if r == nil {
+		return
+	}
+
+	r.FinishedAt = time.Now().UTC()
+	r.Outcome = OutcomeSucceeded
+
+	if err != nil {
+		r.Outcome = OutcomeFailed
+		r.Error = err.Error()
+	}
+}
+
+// WriteJSON writes the result to path as indented JSON
+func (r *Result) WriteJSON(path string) error {
+	if r == nil {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Dotenv returns the key values of the result as KEY=value lines, prefixed with prefix
+func (r *Result) Dotenv(prefix string) string {
+	if r == nil {
+		return ""
+	}
+
+	var ids []string
+
+	for _, d := range r.Deployments {
+		ids = append(ids, d.ID)
+	}
+
+	vars := [][2]string{
+		{"MODE", r.Mode},
+		{"CLUSTER", r.Cluster},
+		{"SERVICES", strings.Join(r.Services, ",")},
+		{"OUTCOME", r.Outcome},
+		{"FAILURE_CATEGORY", r.FailureCategory},
+		{"ROLLBACK", r.Rollback},
+		{"DEPLOYMENT_IDS", strings.Join(ids, ",")},
+		{"BLUE_SERVICE", r.BlueService},
+		{"GREEN_SERVICE", r.GreenService},
+		{"LIVE_SERVICE", r.LiveService},
+		{"LIVE_COLOR", r.LiveColor},
+		{"STARTED_AT", r.StartedAt.Format(time.RFC3339)},
+		{"FINISHED_AT", r.FinishedAt.Format(time.RFC3339)},
+		{"DURATION_SECONDS", strconv.FormatFloat(r.FinishedAt.Sub(r.StartedAt).Seconds(), 'f', 0, 64)},
+	}
+
+	if td := r.PreviousTaskDefinition; td != nil {
+		vars = append(vars, [2]string{"PREVIOUS_TASK_DEFINITION", td.ARN}, [2]string{"PREVIOUS_TASK_DEFINITION_REVISION", fmt.Sprint(td.Revision)})
+	}
+
+	if td := r.TaskDefinition; td != nil {
+		vars = append(vars, [2]string{"TASK_DEFINITION", td.ARN}, [2]string{"TASK_DEFINITION_REVISION", fmt.Sprint(td.Revision)})
+	}
+
+	var b strings.Builder
+
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%s%s=%s\n", prefix, v[0], dotenvValue(v[1]))
+	}
+
+	return b.String()
+}
+
+// WriteDotenv writes the key values of the result to path
+func (r *Result) WriteDotenv(path string, prefix string) error {
+	if r == nil {
+		return nil
+	}
+
+	return ioutil.WriteFile(path, []byte(r.Dotenv(prefix)), 0644)
+}
+
+var dotenvSafe = regexp.MustCompile(`^[A-Za-z0-9_./:,@+-]*$`)
+
+// dotenvValue quotes a value unless every character is safe to leave unquoted
+func dotenvValue(s string) string {
+	if dotenvSafe.MatchString(s) {
+		return s
+	}
+
+	return strconv.Quote(s)
+}
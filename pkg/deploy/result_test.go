@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func testResult() *Result {
+	r := NewResult("rolling", "test-cluster", []string{"web", "worker"}, false)
+
+	r.SetTaskDefinitions(
+		ecstypes.TaskDefinition{TaskDefinitionArn: aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/web:4"), Family: aws.String("web"), Revision: 4},
+		ecstypes.TaskDefinition{TaskDefinitionArn: aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/web:5"), Family: aws.String("web"), Revision: 5},
+	)
+	r.AddDeployment("web", "ecs-svc/1")
+	r.AddDeployment("worker", "ecs-svc/2")
+
+	return r
+}
+
+func TestResultWriteJSON(t *testing.T) {
+	r := testResult()
+
+	p := Poller{Interval: time.Millisecond, MaxChecks: 0, Observer: r}
+
+	p.Poll(context.TODO(), "deployment to complete", func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+
+	r.SetRollback(true)
+	r.Finish(errors.New("deploy failed"))
+
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	assert.NilError(t, r.WriteJSON(path))
+
+	b, err := ioutil.ReadFile(path)
+	assert.NilError(t, err)
+
+	var got Result
+	assert.NilError(t, json.Unmarshal(b, &got))
+
+	assert.Equal(t, "rolling", got.Mode)
+	assert.DeepEqual(t, []string{"web", "worker"}, got.Services)
+	assert.Equal(t, int32(4), got.PreviousTaskDefinition.Revision)
+	assert.Equal(t, "arn:aws:ecs:us-west-2:123456789012:task-definition/web:5", got.TaskDefinition.ARN)
+	assert.DeepEqual(t, []DeploymentResult{{Service: "web", ID: "ecs-svc/1"}, {Service: "worker", ID: "ecs-svc/2"}}, got.Deployments)
+	assert.Equal(t, 1, len(got.Phases))
+	assert.Equal(t, "deployment to complete", got.Phases[0].Name)
+	assert.Assert(t, strings.Contains(got.Phases[0].Error, "phase timed out"), got.Phases[0].Error)
+	assert.Equal(t, OutcomeFailed, got.Outcome)
+	assert.Equal(t, "deploy failed", got.Error)
+	assert.Equal(t, RollbackSucceeded, got.Rollback)
+}
+
+func TestResultSetRollback(t *testing.T) {
+	r := &Result{}
+
+	r.SetRollback(false)
+	r.SetRollback(true)
+
+	assert.Equal(t, RollbackFailed, r.Rollback)
+}
+
+func TestResultDotenv(t *testing.T) {
+	r := testResult()
+	r.SetLive("", "green")
+	r.SetFailureCategory(FailurePlacement)
+	r.StartedAt = time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.Finish(errors.New("deployment failed (placement): unable to place a task"))
+	r.FinishedAt = r.StartedAt.Add(90 * time.Second)
+
+	want := `DEPLOY_MODE=rolling
+DEPLOY_CLUSTER=test-cluster
+DEPLOY_SERVICES=web,worker
+DEPLOY_OUTCOME=failed
+DEPLOY_FAILURE_CATEGORY=placement
+DEPLOY_ROLLBACK=
+DEPLOY_DEPLOYMENT_IDS=ecs-svc/1,ecs-svc/2
+DEPLOY_BLUE_SERVICE=
+DEPLOY_GREEN_SERVICE=
+DEPLOY_LIVE_SERVICE=
+DEPLOY_LIVE_COLOR=green
+DEPLOY_STARTED_AT=2022-01-01T12:00:00Z
+DEPLOY_FINISHED_AT=2022-01-01T12:01:30Z
+DEPLOY_DURATION_SECONDS=90
+DEPLOY_PREVIOUS_TASK_DEFINITION=arn:aws:ecs:us-west-2:123456789012:task-definition/web:4
+DEPLOY_PREVIOUS_TASK_DEFINITION_REVISION=4
+DEPLOY_TASK_DEFINITION=arn:aws:ecs:us-west-2:123456789012:task-definition/web:5
+DEPLOY_TASK_DEFINITION_REVISION=5
+`
+
+	assert.Equal(t, want, r.Dotenv("DEPLOY_"))
+	assert.Equal(t, `"a value with spaces"`, dotenvValue("a value with spaces"))
+}
+
+func TestResultNil(t *testing.T) {
+	var r *Result
+
+	r.AddDeployment("web", "ecs-svc/1")
+	r.SetRollback(true)
+	r.Finish(nil)
+
+	assert.NilError(t, r.WriteJSON(filepath.Join(t.TempDir(), "result.json")))
+	assert.Equal(t, "", r.Dotenv("DEPLOY_"))
+}
@@ -0,0 +1,168 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	runTaskStartedBy = "drone-deploy-ecs"
+)
+
+// RunServiceTask starts a single task of taskDefinitionARN with the same network, launch type and capacity settings as service
+// If command is set it overrides the command of containerName
+func RunServiceTask(ctx context.Context, c types.ECSClient, service string, cluster string, taskDefinitionARN string, containerName string, command []string) (string, error) {
+	out, err := c.DescribeServices(
+		ctx,
+		&ecs.DescribeServicesInput{
+			Services: []string{service},
+			Cluster:  aws.String(cluster),
+		},
+	)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
+		return "", err
+	}
+
+	svc := out.Services[0]
+
+	i := ecs.RunTaskInput{
+		NetworkConfiguration: svc.NetworkConfiguration,
+		PlatformVersion:      svc.PlatformVersion,
+	}
+
+	// A launch type and a capacity provider strategy cannot be set together
+	if len(svc.CapacityProviderStrategy) > 0 {
+		i.CapacityProviderStrategy = svc.CapacityProviderStrategy
+	} else {
+		i.LaunchType = svc.LaunchType
+	}
+
+	return runTask(ctx, c, i, cluster, taskDefinitionARN, containerName, command)
+}
+
+// RunTaskSetTask starts a single task of taskDefinitionARN with the same network, launch type and capacity settings as a task set
+// Services that use the EXTERNAL deployment controller keep these settings on their task sets instead of the service
+// If command is set it overrides the command of containerName
+func RunTaskSetTask(ctx context.Context, c types.ECSClient, taskSet ecstypes.TaskSet, cluster string, taskDefinitionARN string, containerName string, command []string) (string, error) {
+	i := ecs.RunTaskInput{
+		NetworkConfiguration: taskSet.NetworkConfiguration,
+		PlatformVersion:      taskSet.PlatformVersion,
+	}
+
+	if len(taskSet.CapacityProviderStrategy) > 0 {
+		i.CapacityProviderStrategy = taskSet.CapacityProviderStrategy
+	} else {
+		i.LaunchType = taskSet.LaunchType
+	}
+
+	return runTask(ctx, c, i, cluster, taskDefinitionARN, containerName, command)
+}
+
+// runTask starts a single task of taskDefinitionARN with the network, launch type and capacity settings in i
+func runTask(ctx context.Context, c types.ECSClient, i ecs.RunTaskInput, cluster string, taskDefinitionARN string, containerName string, command []string) (string, error) {
+	i.Cluster = aws.String(cluster)
+	i.TaskDefinition = aws.String(taskDefinitionARN)
+	i.Count = aws.Int32(1)
+	i.StartedBy = aws.String(runTaskStartedBy)
+
+	if len(command) > 0 {
+		i.Overrides = &ecstypes.TaskOverride{
+			ContainerOverrides: []ecstypes.ContainerOverride{
+				{
+					Name:    aws.String(containerName),
+					Command: command,
+				},
+			},
+		}
+	}
+
+	resp, err := c.RunTask(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error running task", logging.Err(err))
+		return "", err
+	}
+
+	if len(resp.Failures) > 0 {
+		return "", fmt.Errorf("unable to run task: %s", aws.ToString(resp.Failures[0].Reason))
+	}
+
+	if len(resp.Tasks) == 0 {
+		return "", errors.New("no task started")
+	}
+
+	return *resp.Tasks[0].TaskArn, nil
+}
+
+// CheckTaskStopped returns the task once it has stopped and nil while it is still running
+func CheckTaskStopped(ctx context.Context, c types.ECSClient, cluster string, taskARN string) (*ecstypes.Task, error) {
+	out, err := c.DescribeTasks(
+		ctx,
+		&ecs.DescribeTasksInput{
+			Tasks:   []string{taskARN},
+			Cluster: aws.String(cluster),
+		},
+	)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing task", logging.Err(err))
+		return nil, err
+	}
+
+	if len(out.Tasks) == 0 {
+		return nil, errors.New("task not found")
+	}
+
+	task := out.Tasks[0]
+
+	if aws.ToString(task.LastStatus) != "STOPPED" {
+		return nil, nil
+	}
+
+	return &task, nil
+}
+
+// ContainerExitCode returns the exit code of containerName in a stopped task
+// An error is returned if the container never ran, for example because its image could not be pulled
+func ContainerExitCode(task ecstypes.Task, containerName string) (int32, error) {
+	for _, container := range task.Containers {
+		if aws.ToString(container.Name) != containerName {
+			continue
+		}
+
+		if container.ExitCode == nil {
+			return 0, fmt.Errorf("container '%s' has no exit code: %s", containerName, aws.ToString(container.Reason))
+		}
+
+		return *container.ExitCode, nil
+	}
+
+	return 0, fmt.Errorf("container '%s' not found in task", containerName)
+}
+
+// StopTask stops a running task
+func StopTask(ctx context.Context, c types.ECSClient, cluster string, taskARN string, reason string) error {
+	_, err := c.StopTask(
+		ctx,
+		&ecs.StopTaskInput{
+			Cluster: aws.String(cluster),
+			Task:    aws.String(taskARN),
+			Reason:  aws.String(reason),
+		},
+	)
+
+	if err != nil {
+		logging.From(ctx).Error("Error stopping task", logging.Err(err))
+	}
+
+	return err
+}
@@ -0,0 +1,122 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func TestRunServiceTask(t *testing.T) {
+	runTasks := []*ecs.RunTaskInput{}
+
+	network := &ecstypes.NetworkConfiguration{
+		AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
+			Subnets:        []string{"subnet-1"},
+			SecurityGroups: []string{"sg-1"},
+		},
+	}
+
+	c := MockECSClient{
+		TestingT:             t,
+		NetworkConfiguration: network,
+		RunTasks:             &runTasks,
+	}
+
+	taskARN, err := RunServiceTask(context.Background(), c, "test-service", "test-cluster", testTDARN, "app", []string{"rake", "db:migrate"})
+
+	assert.NilError(t, err)
+	assert.Equal(t, "arn-run-task", taskARN)
+	assert.Equal(t, 1, len(runTasks))
+	assert.Equal(t, testTDARN, *runTasks[0].TaskDefinition)
+	assert.Equal(t, "test-cluster", *runTasks[0].Cluster)
+	assert.Equal(t, network, runTasks[0].NetworkConfiguration)
+	assert.Equal(t, "app", *runTasks[0].Overrides.ContainerOverrides[0].Name)
+	assert.DeepEqual(t, []string{"rake", "db:migrate"}, runTasks[0].Overrides.ContainerOverrides[0].Command)
+
+	// No override is sent without a command
+	_, err = RunServiceTask(context.Background(), c, "test-service", "test-cluster", testTDARN, "app", nil)
+
+	assert.NilError(t, err)
+	assert.Assert(t, runTasks[1].Overrides == nil)
+}
+
+func TestRunTaskSetTask(t *testing.T) {
+	runTasks := []*ecs.RunTaskInput{}
+
+	network := &ecstypes.NetworkConfiguration{
+		AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
+			Subnets:        []string{"subnet-1"},
+			SecurityGroups: []string{"sg-1"},
+		},
+	}
+
+	c := MockECSClient{
+		TestingT: t,
+		RunTasks: &runTasks,
+	}
+
+	taskSet := ecstypes.TaskSet{
+		Id:                   aws.String("ts-primary"),
+		NetworkConfiguration: network,
+		LaunchType:           ecstypes.LaunchTypeFargate,
+	}
+
+	taskARN, err := RunTaskSetTask(context.Background(), c, taskSet, "test-cluster", testTDARN, "app", []string{"rake", "db:migrate"})
+
+	assert.NilError(t, err)
+	assert.Equal(t, "arn-run-task", taskARN)
+	assert.Equal(t, 1, len(runTasks))
+	assert.Equal(t, testTDARN, *runTasks[0].TaskDefinition)
+	assert.Equal(t, network, runTasks[0].NetworkConfiguration)
+	assert.Equal(t, ecstypes.LaunchTypeFargate, runTasks[0].LaunchType)
+	assert.DeepEqual(t, []string{"rake", "db:migrate"}, runTasks[0].Overrides.ContainerOverrides[0].Command)
+}
+
+func TestCheckTaskStopped(t *testing.T) {
+	running := MockECSClient{TestingT: t, Tasks: []ecstypes.Task{{TaskArn: aws.String("arn-run-task"), LastStatus: aws.String("RUNNING")}}}
+
+	task, err := CheckTaskStopped(context.Background(), running, "test-cluster", "arn-run-task")
+
+	assert.NilError(t, err)
+	assert.Assert(t, task == nil)
+
+	stopped := MockECSClient{TestingT: t, Tasks: []ecstypes.Task{{TaskArn: aws.String("arn-run-task"), LastStatus: aws.String("STOPPED")}}}
+
+	task, err = CheckTaskStopped(context.Background(), stopped, "test-cluster", "arn-run-task")
+
+	assert.NilError(t, err)
+	assert.Equal(t, "arn-run-task", *task.TaskArn)
+}
+
+func TestContainerExitCode(t *testing.T) {
+	task := ecstypes.Task{
+		Containers: []ecstypes.Container{
+			{Name: aws.String("app"), ExitCode: aws.Int32(3)},
+			{Name: aws.String("sidecar"), Reason: aws.String("CannotPullContainerError")},
+		},
+	}
+
+	code, err := ContainerExitCode(task, "app")
+
+	assert.NilError(t, err)
+	assert.Equal(t, int32(3), code)
+
+	_, err = ContainerExitCode(task, "sidecar")
+	assert.ErrorContains(t, err, "CannotPullContainerError")
+
+	_, err = ContainerExitCode(task, "worker")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestStopTask(t *testing.T) {
+	stopped := []string{}
+
+	err := StopTask(context.Background(), MockECSClient{TestingT: t, StoppedTasks: &stopped}, "test-cluster", "arn-run-task", "timed out")
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"arn-run-task"}, stopped)
+}
@@ -3,8 +3,8 @@ package deploy
 import (
 	"context"
 	"fmt"
-	"log"
 
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
@@ -36,34 +36,38 @@ func GetServiceMinMaxCount(ctx context.Context, c types.AppAutoscalingClient, cl
 	return *r.MaxCapacity, *r.MinCapacity, nil
 }
 
-func (c DeployConfig) ScaleDown(desiredCount int32, minCount int32, maxCount int32, service string, serviceUsesAppAutoscaling bool) error {
-	log.Println("Setting desired count to", desiredCount, "for service", service)
-	err := setECSServiceDesiredCount(c.ECS, service, c.Cluster, desiredCount)
+func (c DeployConfig) ScaleDown(ctx context.Context, desiredCount int32, minCount int32, maxCount int32, service string, serviceUsesAppAutoscaling bool) error {
+	ctx = logging.With(c.LoggerContext(ctx), logging.Service(service))
+
+	logging.From(ctx).Info(fmt.Sprint("Setting desired count to ", desiredCount, " for service ", service))
+	err := setECSServiceDesiredCount(ctx, c.ECS, service, c.Cluster, desiredCount)
 
 	if err != nil {
 		return err
 	}
 
 	if serviceUsesAppAutoscaling {
-		log.Println("Setting max count to", maxCount, "for service", service)
-		return setAppAutoscalingCounts(context.Background(), c.AppAutoscaling, service, c.Cluster, maxCount, 0)
+		logging.From(ctx).Info(fmt.Sprint("Setting max count to ", maxCount, " for service ", service))
+		return setAppAutoscalingCounts(ctx, c.AppAutoscaling, service, c.Cluster, maxCount, 0)
 	}
 
 	return nil
 }
 
-func (c DeployConfig) ScaleUp(desiredCount int32, minCount int32, maxCount int32, service string) error {
+func (c DeployConfig) ScaleUp(ctx context.Context, desiredCount int32, minCount int32, maxCount int32, service string) error {
+	ctx = logging.With(c.LoggerContext(ctx), logging.Service(service))
+
 	if maxCount == -1 {
-		log.Println("Setting desired count to", desiredCount, "for service", service)
-		return setECSServiceDesiredCount(c.ECS, service, c.Cluster, desiredCount)
+		logging.From(ctx).Info(fmt.Sprint("Setting desired count to ", desiredCount, " for service ", service))
+		return setECSServiceDesiredCount(ctx, c.ECS, service, c.Cluster, desiredCount)
 	} else {
-		err := setAppAutoscalingCounts(context.Background(), c.AppAutoscaling, service, c.Cluster, maxCount, minCount)
+		err := setAppAutoscalingCounts(ctx, c.AppAutoscaling, service, c.Cluster, maxCount, minCount)
 
 		if err != nil {
 			return err
 		}
 
-		err = setECSServiceDesiredCount(c.ECS, service, c.Cluster, desiredCount)
+		err = setECSServiceDesiredCount(ctx, c.ECS, service, c.Cluster, desiredCount)
 
 		return err
 	}
@@ -3,14 +3,24 @@ package deploy
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"strings"
 
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
-func GetServiceRunningTaskDefinition(ctx context.Context, c types.ECSClient, service string, cluster string) (string, error) {
+func GetServiceRunningTaskDefinition(ctx context.Context, c types.ECSClient, service string, cluster string) (td string, err error) {
+	ctx, span := tracing.Start(ctx, "discover task definition", tracing.String("ecs.cluster", cluster), tracing.String("ecs.service", service))
+	defer func() {
+		span.SetAttributes(tracing.String("ecs.task_definition", td))
+		span.End(err)
+	}()
+
 	i := ecs.DescribeServicesInput{
 		Services: []string{service},
 		Cluster:  aws.String(cluster),
@@ -22,7 +32,7 @@ func GetServiceRunningTaskDefinition(ctx context.Context, c types.ECSClient, ser
 	)
 
 	if err != nil {
-		log.Println("Error describing service: ", err.Error())
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
 		return "", err
 	}
 
@@ -41,14 +51,39 @@ func GetServiceDesiredCount(ctx context.Context, c types.ECSClient, service stri
 	)
 
 	if err != nil {
-		log.Println("Error describing service: ", err.Error())
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
 		return 0, err
 	}
 
 	return out.Services[0].DesiredCount, nil
 }
 
-func UpdateServiceTaskDefinitionVersion(ctx context.Context, c types.ECSClient, service string, cluster string, taskDefinitonARN string) (string, error) {
+// GetServiceLoadBalancers returns the load balancers a service is registered with
+func GetServiceLoadBalancers(ctx context.Context, c types.ECSClient, service string, cluster string) ([]ecstypes.LoadBalancer, error) {
+	i := ecs.DescribeServicesInput{
+		Services: []string{service},
+		Cluster:  aws.String(cluster),
+	}
+
+	out, err := c.DescribeServices(
+		ctx,
+		&i,
+	)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
+		return nil, err
+	}
+
+	return out.Services[0].LoadBalancers, nil
+}
+
+func UpdateServiceTaskDefinitionVersion(ctx context.Context, c types.ECSClient, service string, cluster string, taskDefinitonARN string) (deploymentID string, err error) {
+	ctx, span := tracing.Start(ctx, "update service", tracing.String("ecs.cluster", cluster), tracing.String("ecs.service", service), tracing.String("ecs.task_definition", taskDefinitonARN))
+	defer func() {
+		span.SetAttributes(tracing.String("ecs.deployment_id", deploymentID))
+		span.End(err)
+	}()
 
 	i := ecs.UpdateServiceInput{
 		Service:        aws.String(service),
@@ -62,7 +97,7 @@ func UpdateServiceTaskDefinitionVersion(ctx context.Context, c types.ECSClient,
 	)
 
 	if err != nil {
-		log.Println("Error updating service: ", err.Error())
+		logging.From(ctx).Error("Error updating service", logging.Err(err))
 		return "", err
 	}
 
@@ -84,15 +119,12 @@ func CheckDeploymentStatus(ctx context.Context, c types.ECSClient, service strin
 	)
 
 	if err != nil {
-		log.Println("Error describing service: ", err.Error())
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
 		return true, err
 	}
 
 	if out.Services[0].Deployments[0].FailedTasks > 0 {
-		log.Printf("Deployment %d has failed tasks\n", out.Services[0].Deployments[0].FailedTasks)
-		// This is helpful for debugging
-		// ECS will clear all stopped tasks after a deployment finishes
-		showFailedTasks(c, service, cluster, deploymentID)
+		logging.From(ctx).Error(fmt.Sprintf("Deployment has %d failed tasks", out.Services[0].Deployments[0].FailedTasks), logging.Service(service), logging.DeploymentID(deploymentID))
 		return true, errors.New("deployment failed")
 	}
 
@@ -106,45 +138,48 @@ func CheckDeploymentStatus(ctx context.Context, c types.ECSClient, service strin
 	}
 }
 
-func showFailedTasks(c types.ECSClient, service string, cluster string, deploymentID string) {
-	input := ecs.ListTasksInput{
-		Cluster:       &cluster,
-		DesiredStatus: "STOPPED",
-		StartedBy:     &deploymentID,
-	}
+// ServiceCache is an ECSClient that describes the same services once until Reset
+// The checks of a poll share one, so every check sees the same state and a poll describes a service once
+type ServiceCache struct {
+	types.ECSClient
 
-	log.Printf("Checking failed tasks for service '%s'", service)
+	services map[string]*ecs.DescribeServicesOutput
+}
 
-	resp, err := c.ListTasks(context.TODO(), &input)
+// NewServiceCache returns a ServiceCache that describes services with c
+func NewServiceCache(c types.ECSClient) *ServiceCache {
+	return &ServiceCache{ECSClient: c}
+}
 
-	if err != nil {
-		log.Printf("Error listing tasks: %s", err.Error())
-		return
+// DescribeServices returns the result of the first call that described the same services since Reset. Errors are not kept
+func (c *ServiceCache) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	key := fmt.Sprintf("%s/%s/%v", aws.ToString(params.Cluster), strings.Join(params.Services, ","), params.Include)
+
+	if out, ok := c.services[key]; ok {
+		return out, nil
 	}
 
-	tasks, err := c.DescribeTasks(
-		context.TODO(),
-		&ecs.DescribeTasksInput{
-			Tasks:   resp.TaskArns,
-			Cluster: &cluster,
-		},
-	)
+	out, err := c.ECSClient.DescribeServices(ctx, params, optFns...)
 
 	if err != nil {
-		log.Printf("Error describing tasks: %s", err.Error())
-		return
+		return nil, err
 	}
 
-	for _, task := range tasks.Tasks {
-		stoppedReason := task.StoppedReason
-		taskARN := task.TaskArn
-
-		log.Printf("Task '%s' failure reason: %s", *taskARN, *stoppedReason)
+	if c.services == nil {
+		c.services = make(map[string]*ecs.DescribeServicesOutput)
 	}
 
+	c.services[key] = out
+
+	return out, nil
 }
 
-func setECSServiceDesiredCount(c types.ECSClient, service string, cluster string, desiredCount int32) error {
+// Reset forgets the services described so far, so the next check sees their current state
+func (c *ServiceCache) Reset() {
+	c.services = nil
+}
+
+func setECSServiceDesiredCount(ctx context.Context, c types.ECSClient, service string, cluster string, desiredCount int32) error {
 
 	p := ecs.UpdateServiceInput{
 		Service:      &service,
@@ -152,14 +187,15 @@ func setECSServiceDesiredCount(c types.ECSClient, service string, cluster string
 		Cluster:      &cluster,
 	}
 
-	// TODO use provided context
-	_, err := c.UpdateService(context.Background(), &p)
+	_, err := c.UpdateService(ctx, &p)
 
 	return err
 }
 
 // TODO update mock client so we can test this
 func (c DeployConfig) GreenScaleUpFinished(ctx context.Context, service string) (bool, error) {
+	ctx = c.LoggerContext(ctx)
+
 	i := ecs.DescribeServicesInput{
 		Services: []string{service},
 		Cluster:  aws.String(c.Cluster),
@@ -171,7 +207,7 @@ func (c DeployConfig) GreenScaleUpFinished(ctx context.Context, service string)
 	)
 
 	if err != nil {
-		log.Println("Error describing service: ", err.Error())
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
 		return true, err
 	}
 
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"gotest.tools/assert"
 )
 
@@ -170,6 +173,7 @@ func TestUpdateServiceTaskDefinitionVersion(t *testing.T) {
 
 func Test_setECSServiceDesiredCount(t *testing.T) {
 	type args struct {
+		ctx          context.Context
 		c            types.ECSClient
 		service      string
 		cluster      string
@@ -183,6 +187,7 @@ func Test_setECSServiceDesiredCount(t *testing.T) {
 		{
 			name: "success",
 			args: args{
+				ctx:     context.Background(),
 				c:       MockECSClient{TestingT: t, WantError: false},
 				service: "test-service",
 				cluster: "test-cluster",
@@ -192,6 +197,7 @@ func Test_setECSServiceDesiredCount(t *testing.T) {
 		{
 			name: "failure",
 			args: args{
+				ctx:     context.Background(),
 				c:       MockECSClient{TestingT: t, WantError: true},
 				service: "test-service",
 				cluster: "test-cluster",
@@ -201,7 +207,7 @@ func Test_setECSServiceDesiredCount(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := setECSServiceDesiredCount(tt.args.c, tt.args.service, tt.args.cluster, tt.args.desiredCount); (err != nil) != tt.wantErr {
+			if err := setECSServiceDesiredCount(tt.args.ctx, tt.args.c, tt.args.service, tt.args.cluster, tt.args.desiredCount); (err != nil) != tt.wantErr {
 				t.Errorf("setECSServiceDesiredCount() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -247,30 +253,43 @@ func TestGetServiceDesiredCount(t *testing.T) {
 	}
 }
 
-func Test_showFailedTasks(t *testing.T) {
-	type args struct {
-		c            types.ECSClient
-		service      string
-		cluster      string
-		deploymentID string
-	}
-	tests := []struct {
-		name string
-		args args
-	}{
-		{
-			name: "test-failed-task-logging",
-			args: args{
-				c:            MockECSClient{WantError: false, TestingT: t},
-				service:      "test-service",
-				cluster:      "test-cluster",
-				deploymentID: "ecs/deploy-foo",
-			},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			showFailedTasks(tt.args.c, tt.args.service, tt.args.cluster, tt.args.deploymentID)
-		})
+// describeCountingECSClient counts DescribeServices calls
+type describeCountingECSClient struct {
+	MockECSClient
+	calls *int
+}
+
+func (c describeCountingECSClient) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	*c.calls++
+	return c.MockECSClient.DescribeServices(ctx, params, optFns...)
+}
+
+func TestServiceCache(t *testing.T) {
+	calls := 0
+	cache := NewServiceCache(describeCountingECSClient{MockECSClient: MockECSClient{TestingT: t, DeploymentState: "IN_PROGRESS"}, calls: &calls})
+
+	for i := 0; i < 2; i++ {
+		_, err := CheckDeploymentStatus(context.TODO(), cache, "test-service", "test-cluster", "")
+		assert.NilError(t, err)
+
+		_, err = GetServiceDesiredCount(context.TODO(), cache, "test-service", "test-cluster")
+		assert.NilError(t, err)
 	}
+
+	assert.Equal(t, 1, calls)
+
+	// Describing the service with its tags is a different call
+	_, err := cache.DescribeServices(context.TODO(), &ecs.DescribeServicesInput{
+		Cluster:  aws.String("test-cluster"),
+		Services: []string{"test-service"},
+		Include:  []ecstypes.ServiceField{ecstypes.ServiceFieldTags},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 2, calls)
+
+	cache.Reset()
+
+	_, err = GetServiceDesiredCount(context.TODO(), cache, "test-service", "test-cluster")
+	assert.NilError(t, err)
+	assert.Equal(t, 3, calls)
 }
@@ -1,12 +1,92 @@
 package deploy
 
-import "github.com/assemblyai/drone-deploy-ecs/pkg/types"
+import (
+	"context"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+)
 
 type DeployConfig struct {
 	ECS            types.ECSClient
 	AppAutoscaling types.AppAutoscalingClient
-	Cluster        string
-	Container      string
-	Image          string
-	// Logger
+	// ELBv2 checks the target health of services. Target health is not checked if it is nil
+	ELBv2 types.ELBv2Client
+	// Alarms aborts a deployment if an alarm fires during the rollout or BakePeriod. Alarms are not watched if it is nil
+	Alarms *AlarmMonitor
+	// BakePeriod is how long alarms are watched after the rollout before the deployment succeeds
+	BakePeriod time.Duration
+	// Events prints the service events of a deployment while it is polled. Events are not printed if it is nil
+	Events *EventStream
+	// Logs prints the tail of the logs of every container of a failed deployment's stopped tasks. Logs are not printed if it is nil
+	Logs      *LogTail
+	Cluster   string
+	Container string
+	Image     string
+	// Containers maps additional container names to the image they should be updated to
+	Containers map[string]string
+	// Environment adds, overrides or removes (nil value) environment variables in Container
+	Environment map[string]*string
+	// Secrets adds, overrides or removes (nil value) secrets in Container. Values are the secret ARN
+	Secrets map[string]*string
+	// TaskDefinitionTags are added to the new task definition revision, overriding tags with the same key
+	TaskDefinitionTags map[string]string
+	// Logger logs every step of a deploy with the cluster as a field. The default logger is used if it is nil
+	// Functions that are not methods of DeployConfig log to the logger of their context, which LoggerContext sets
+	Logger logging.Logger
+}
+
+// TaskDefinitionChanges describes how a new task definition revision differs from the one it was cloned from
+type TaskDefinitionChanges struct {
+	// Images maps container names to the image they should use
+	Images map[string]string
+	// Container is the container that Environment and Secrets are applied to
+	Container string
+	// Environment adds or overrides environment variables. A nil value removes the variable
+	Environment map[string]*string
+	// Secrets adds or overrides secrets, keyed by name with the secret ARN as the value. A nil value removes the secret
+	Secrets map[string]*string
+	// Tags are added to the new revision, overriding tags with the same key
+	Tags map[string]string
+}
+
+// IsEmpty returns true if the changes would not modify the task definition
+func (t TaskDefinitionChanges) IsEmpty() bool {
+	return len(t.Images) == 0 && len(t.Environment) == 0 && len(t.Secrets) == 0
+}
+
+// ContainerImages returns every container that should be updated in a new task definition revision, keyed by container name
+func (c DeployConfig) ContainerImages() map[string]string {
+	images := make(map[string]string)
+
+	for container, image := range c.Containers {
+		images[container] = image
+	}
+
+	if c.Container != "" && c.Image != "" {
+		images[c.Container] = c.Image
+	}
+
+	return images
+}
+
+// LoggerContext returns a context that logs to Logger, unless ctx already has a logger
+func (c DeployConfig) LoggerContext(ctx context.Context) context.Context {
+	if _, ok := logging.Lookup(ctx); ok || c.Logger == nil {
+		return ctx
+	}
+
+	return logging.WithLogger(ctx, c.Logger.With(logging.Cluster(c.Cluster)))
+}
+
+// TaskDefinitionChanges returns the changes to apply when registering a new task definition revision
+func (c DeployConfig) TaskDefinitionChanges() TaskDefinitionChanges {
+	return TaskDefinitionChanges{
+		Images:      c.ContainerImages(),
+		Container:   c.Container,
+		Environment: c.Environment,
+		Secrets:     c.Secrets,
+		Tags:        c.TaskDefinitionTags,
+	}
 }
@@ -0,0 +1,21 @@
+package deploy
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestDeployConfigContainerImages(t *testing.T) {
+	dc := DeployConfig{
+		Container: "app",
+		Image:     "foo/app:3",
+		Containers: map[string]string{
+			"app":        "foo/app:2",
+			"migrations": "foo/migrations:3",
+		},
+	}
+
+	// The single container setting takes precedence
+	assert.DeepEqual(t, map[string]string{"app": "foo/app:3", "migrations": "foo/migrations:3"}, dc.ContainerImages())
+}
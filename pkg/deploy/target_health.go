@@ -0,0 +1,369 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// describeTasksLimit is the most tasks DescribeTasks accepts in one call. ListTasks returns as many a page
+const describeTasksLimit = 100
+
+// target is a task registered in a target group. Id is the IP of awsvpc tasks and the EC2 instance ID of other tasks
+type target struct {
+	Id   string
+	Port int32
+}
+
+func (t target) String() string {
+	return fmt.Sprintf("%s:%d", t.Id, t.Port)
+}
+
+// CheckTargetsHealthy returns true once every running task of a service is healthy in every target group of the service
+// If taskDefinitionARN is set, only tasks that run it are checked, so the tasks of a previous deployment are ignored
+// Services without target groups are always healthy
+func CheckTargetsHealthy(ctx context.Context, c types.ECSClient, lb types.ELBv2Client, service string, cluster string, taskDefinitionARN string) (bool, error) {
+	targetGroups, err := serviceTargetGroups(ctx, c, service, cluster)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(targetGroups) == 0 {
+		return true, nil
+	}
+
+	tasks, err := serviceRunningTasks(ctx, c, service, cluster, taskDefinitionARN)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(tasks) == 0 {
+		logging.From(ctx).Info(fmt.Sprintf("Service '%s' has no running tasks to check the target health of", service), logging.Service(service))
+		return false, nil
+	}
+
+	unhealthy, err := unhealthyTargets(ctx, c, lb, cluster, targetGroups, tasks)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, u := range unhealthy {
+		logging.From(ctx).Info(
+			fmt.Sprintf("Target %s of task '%s' is '%s' in target group '%s' %s", u.target, u.task, u.state, u.targetGroup, u.reason),
+			logging.Service(service),
+		)
+	}
+
+	return len(unhealthy) == 0, nil
+}
+
+// HasHealthyTask returns true once a running task of td is healthy
+// A task is healthy once every container of td with a health check is healthy and, if lb is set, its targets are healthy in every target group of the service
+// A running task of a revision without health checks or target groups is healthy
+func HasHealthyTask(ctx context.Context, c types.ECSClient, lb types.ELBv2Client, service string, cluster string, td ecstypes.TaskDefinition) (bool, error) {
+	tasks, err := serviceRunningTasks(ctx, c, service, cluster, aws.ToString(td.TaskDefinitionArn))
+
+	if err != nil {
+		return false, err
+	}
+
+	var running []ecstypes.Task
+
+	for _, task := range tasks {
+		if aws.ToString(task.LastStatus) == "RUNNING" && containersHealthy(task, td) {
+			running = append(running, task)
+		}
+	}
+
+	if len(running) == 0 || lb == nil {
+		return len(running) > 0, nil
+	}
+
+	targetGroups, err := serviceTargetGroups(ctx, c, service, cluster)
+
+	if err != nil {
+		return false, err
+	}
+
+	unhealthy, err := unhealthyTargets(ctx, c, lb, cluster, targetGroups, running)
+
+	if err != nil {
+		return false, err
+	}
+
+	unhealthyTasks := make(map[string]bool)
+
+	for _, u := range unhealthy {
+		unhealthyTasks[u.task] = true
+	}
+
+	for _, task := range running {
+		if !unhealthyTasks[aws.ToString(task.TaskArn)] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// containersHealthy returns true if every container of a task that has a health check in td is healthy
+func containersHealthy(task ecstypes.Task, td ecstypes.TaskDefinition) bool {
+	for _, cd := range td.ContainerDefinitions {
+		if cd.HealthCheck == nil {
+			continue
+		}
+
+		for _, container := range task.Containers {
+			if aws.ToString(container.Name) == aws.ToString(cd.Name) && container.HealthStatus != ecstypes.HealthStatusHealthy {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// serviceTargetGroups returns the load balancers of a service that have a target group
+func serviceTargetGroups(ctx context.Context, c types.ECSClient, service string, cluster string) ([]ecstypes.LoadBalancer, error) {
+	loadBalancers, err := GetServiceLoadBalancers(ctx, c, service, cluster)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var targetGroups []ecstypes.LoadBalancer
+
+	for _, l := range loadBalancers {
+		// Classic load balancers have no target group
+		if l.TargetGroupArn != nil {
+			targetGroups = append(targetGroups, l)
+		}
+	}
+
+	return targetGroups, nil
+}
+
+// unhealthyTarget is a target of a task that is not healthy in a target group
+type unhealthyTarget struct {
+	task        string
+	target      target
+	targetGroup string
+	state       elbtypes.TargetHealthStateEnum
+	reason      string
+}
+
+// unhealthyTargets returns the targets of tasks that are not healthy in targetGroups
+func unhealthyTargets(ctx context.Context, c types.ECSClient, lb types.ELBv2Client, cluster string, targetGroups []ecstypes.LoadBalancer, tasks []ecstypes.Task) ([]unhealthyTarget, error) {
+	instanceIDs, err := containerInstanceIDs(ctx, c, cluster, tasks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var unhealthy []unhealthyTarget
+
+	for _, tg := range targetGroups {
+		out, err := lb.DescribeTargetHealth(ctx, &elasticloadbalancingv2.DescribeTargetHealthInput{
+			TargetGroupArn: tg.TargetGroupArn,
+		})
+
+		if err != nil {
+			logging.From(ctx).Error("Error describing target health", logging.Err(err))
+			return nil, err
+		}
+
+		for _, task := range tasks {
+			for _, t := range taskTargets(task, instanceIDs, aws.ToString(tg.ContainerName), aws.ToInt32(tg.ContainerPort)) {
+				state, reason := targetState(out.TargetHealthDescriptions, t)
+
+				if state != elbtypes.TargetHealthStateEnumHealthy {
+					unhealthy = append(unhealthy, unhealthyTarget{
+						task:        aws.ToString(task.TaskArn),
+						target:      t,
+						targetGroup: aws.ToString(tg.TargetGroupArn),
+						state:       state,
+						reason:      reason,
+					})
+				}
+			}
+		}
+	}
+
+	return unhealthy, nil
+}
+
+// serviceRunningTasks returns the running tasks of a service. Only tasks that run taskDefinitionARN are returned if it is set
+func serviceRunningTasks(ctx context.Context, c types.ECSClient, service string, cluster string, taskDefinitionARN string) ([]ecstypes.Task, error) {
+	arns, err := listTaskARNs(ctx, c, ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		ServiceName:   aws.String(service),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Error listing tasks", logging.Err(err))
+		return nil, err
+	}
+
+	out, err := describeTasks(ctx, c, cluster, arns)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing tasks", logging.Err(err))
+		return nil, err
+	}
+
+	var tasks []ecstypes.Task
+
+	for _, task := range out {
+		if taskDefinitionARN == "" || aws.ToString(task.TaskDefinitionArn) == taskDefinitionARN {
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks, nil
+}
+
+// listTaskARNs returns the ARN of every task that matches i, from every page of ListTasks
+func listTaskARNs(ctx context.Context, c types.ECSClient, i ecs.ListTasksInput) ([]string, error) {
+	var arns []string
+
+	for {
+		out, err := c.ListTasks(ctx, &i)
+
+		if err != nil {
+			return nil, err
+		}
+
+		arns = append(arns, out.TaskArns...)
+
+		if out.NextToken == nil {
+			break
+		}
+
+		i.NextToken = out.NextToken
+	}
+
+	return arns, nil
+}
+
+// describeTasks describes tasks describeTasksLimit at a time
+func describeTasks(ctx context.Context, c types.ECSClient, cluster string, arns []string) ([]ecstypes.Task, error) {
+	var tasks []ecstypes.Task
+
+	for i := 0; i < len(arns); i += describeTasksLimit {
+		end := i + describeTasksLimit
+
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		out, err := c.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   arns[i:end],
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, out.Tasks...)
+	}
+
+	return tasks, nil
+}
+
+// containerInstanceIDs returns the EC2 instance ID of the container instance of every task that runs on one
+func containerInstanceIDs(ctx context.Context, c types.ECSClient, cluster string, tasks []ecstypes.Task) (map[string]string, error) {
+	var arns []string
+
+	for _, task := range tasks {
+		if task.ContainerInstanceArn != nil {
+			arns = append(arns, *task.ContainerInstanceArn)
+		}
+	}
+
+	ids := make(map[string]string)
+
+	if len(arns) == 0 {
+		return ids, nil
+	}
+
+	out, err := c.DescribeContainerInstances(ctx, &ecs.DescribeContainerInstancesInput{
+		Cluster:            aws.String(cluster),
+		ContainerInstances: arns,
+	})
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing container instances", logging.Err(err))
+		return nil, err
+	}
+
+	for _, ci := range out.ContainerInstances {
+		ids[aws.ToString(ci.ContainerInstanceArn)] = aws.ToString(ci.Ec2InstanceId)
+	}
+
+	return ids, nil
+}
+
+// taskTargets returns the targets a task registers in a target group for containerPort of containerName
+// awsvpc tasks are registered by IP and container port. Other tasks are registered by the EC2 instance ID of their container instance and host port
+func taskTargets(task ecstypes.Task, instanceIDs map[string]string, containerName string, containerPort int32) []target {
+	var targets []target
+
+	for _, container := range task.Containers {
+		if aws.ToString(container.Name) != containerName {
+			continue
+		}
+
+		for _, ni := range container.NetworkInterfaces {
+			if ni.PrivateIpv4Address != nil {
+				targets = append(targets, target{Id: *ni.PrivateIpv4Address, Port: containerPort})
+			}
+		}
+
+		if len(targets) > 0 {
+			continue
+		}
+
+		for _, binding := range container.NetworkBindings {
+			if aws.ToInt32(binding.ContainerPort) == containerPort {
+				targets = append(targets, target{Id: instanceIDs[aws.ToString(task.ContainerInstanceArn)], Port: aws.ToInt32(binding.HostPort)})
+			}
+		}
+	}
+
+	return targets
+}
+
+// targetState returns the health state of t in a target group and the reason it is not healthy
+func targetState(descriptions []elbtypes.TargetHealthDescription, t target) (elbtypes.TargetHealthStateEnum, string) {
+	for _, d := range descriptions {
+		if d.Target == nil || d.TargetHealth == nil {
+			continue
+		}
+
+		if aws.ToInt32(d.Target.Port) != t.Port || aws.ToString(d.Target.Id) != t.Id {
+			continue
+		}
+
+		reason := ""
+
+		if d.TargetHealth.Description != nil {
+			reason = "(" + *d.TargetHealth.Description + ")"
+		}
+
+		return d.TargetHealth.State, reason
+	}
+
+	return "unregistered", ""
+}
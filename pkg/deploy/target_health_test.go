@@ -0,0 +1,313 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+const testTGARN = "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/web/abc"
+
+func awsvpcTask(ip string, taskDefinitionARN string) ecstypes.Task {
+	return ecstypes.Task{
+		TaskArn:           aws.String("task-" + ip),
+		TaskDefinitionArn: aws.String(taskDefinitionARN),
+		Containers: []ecstypes.Container{
+			{
+				Name:              aws.String("app"),
+				NetworkInterfaces: []ecstypes.NetworkInterface{{PrivateIpv4Address: aws.String(ip)}},
+			},
+		},
+	}
+}
+
+// instanceTask returns a bridge network task on a container instance that maps container port 8080 to hostPort
+func instanceTask(containerInstanceARN string, hostPort int32) ecstypes.Task {
+	return ecstypes.Task{
+		TaskArn:              aws.String("task-1"),
+		ContainerInstanceArn: aws.String(containerInstanceARN),
+		Containers: []ecstypes.Container{
+			{
+				Name:            aws.String("app"),
+				NetworkBindings: []ecstypes.NetworkBinding{{ContainerPort: aws.Int32(8080), HostPort: aws.Int32(hostPort)}},
+			},
+		},
+	}
+}
+
+func targetHealth(id string, port int32, state elbtypes.TargetHealthStateEnum) elbtypes.TargetHealthDescription {
+	return elbtypes.TargetHealthDescription{
+		Target:       &elbtypes.TargetDescription{Id: aws.String(id), Port: aws.Int32(port)},
+		TargetHealth: &elbtypes.TargetHealth{State: state},
+	}
+}
+
+func TestCheckTargetsHealthy(t *testing.T) {
+	loadBalancers := []ecstypes.LoadBalancer{
+		{TargetGroupArn: aws.String(testTGARN), ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+	}
+
+	tests := []struct {
+		name               string
+		loadBalancers      []ecstypes.LoadBalancer
+		tasks              []ecstypes.Task
+		containerInstances map[string]string
+		targetHealth       []elbtypes.TargetHealthDescription
+		taskDefinitionARN  string
+		want               bool
+		wantErr            bool
+	}{
+		{
+			name: "no-target-groups",
+			want: true,
+		},
+		{
+			name:          "all-healthy",
+			loadBalancers: loadBalancers,
+			tasks:         []ecstypes.Task{awsvpcTask("10.0.0.1", testTDARN), awsvpcTask("10.0.0.2", testTDARN)},
+			targetHealth: []elbtypes.TargetHealthDescription{
+				targetHealth("10.0.0.1", 8080, elbtypes.TargetHealthStateEnumHealthy),
+				targetHealth("10.0.0.2", 8080, elbtypes.TargetHealthStateEnumHealthy),
+			},
+			want: true,
+		},
+		{
+			name:          "one-initial",
+			loadBalancers: loadBalancers,
+			tasks:         []ecstypes.Task{awsvpcTask("10.0.0.1", testTDARN), awsvpcTask("10.0.0.2", testTDARN)},
+			targetHealth: []elbtypes.TargetHealthDescription{
+				targetHealth("10.0.0.1", 8080, elbtypes.TargetHealthStateEnumHealthy),
+				targetHealth("10.0.0.2", 8080, elbtypes.TargetHealthStateEnumInitial),
+			},
+			want: false,
+		},
+		{
+			name:          "not-registered",
+			loadBalancers: loadBalancers,
+			tasks:         []ecstypes.Task{awsvpcTask("10.0.0.1", testTDARN)},
+			want:          false,
+		},
+		{
+			name:          "previous-deployment-ignored",
+			loadBalancers: loadBalancers,
+			tasks:         []ecstypes.Task{awsvpcTask("10.0.0.1", testTDARN), awsvpcTask("10.0.0.2", "old-revision")},
+			targetHealth: []elbtypes.TargetHealthDescription{
+				targetHealth("10.0.0.1", 8080, elbtypes.TargetHealthStateEnumHealthy),
+				targetHealth("10.0.0.2", 8080, elbtypes.TargetHealthStateEnumDraining),
+			},
+			taskDefinitionARN: testTDARN,
+			want:              true,
+		},
+		{
+			name:               "instance-target",
+			loadBalancers:      loadBalancers,
+			tasks:              []ecstypes.Task{instanceTask("arn:container-instance/1", 32768)},
+			containerInstances: map[string]string{"arn:container-instance/1": "i-0123456789abcdef0"},
+			targetHealth: []elbtypes.TargetHealthDescription{
+				targetHealth("i-0123456789abcdef0", 32768, elbtypes.TargetHealthStateEnumHealthy),
+			},
+			want: true,
+		},
+		{
+			// An old task on another instance has the same host port
+			name:               "instance-target-other-instance",
+			loadBalancers:      loadBalancers,
+			tasks:              []ecstypes.Task{instanceTask("arn:container-instance/1", 32768)},
+			containerInstances: map[string]string{"arn:container-instance/1": "i-0123456789abcdef0"},
+			targetHealth: []elbtypes.TargetHealthDescription{
+				targetHealth("i-0fedcba9876543210", 32768, elbtypes.TargetHealthStateEnumHealthy),
+				targetHealth("i-0123456789abcdef0", 32768, elbtypes.TargetHealthStateEnumUnhealthy),
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := MockECSClient{TestingT: t, LoadBalancers: tt.loadBalancers, Tasks: tt.tasks, ContainerInstances: tt.containerInstances}
+			lb := MockELBv2Client{TargetHealth: map[string][]elbtypes.TargetHealthDescription{testTGARN: tt.targetHealth}}
+
+			got, err := CheckTargetsHealthy(context.TODO(), e, lb, "test-service", "test-cluster", tt.taskDefinitionARN)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckTargetsHealthy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("CheckTargetsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTargetsHealthyPages(t *testing.T) {
+	loadBalancers := []ecstypes.LoadBalancer{
+		{TargetGroupArn: aws.String(testTGARN), ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+	}
+
+	var tasks []ecstypes.Task
+	var health []elbtypes.TargetHealthDescription
+
+	for i := 0; i < 150; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/100, i%100)
+		state := elbtypes.TargetHealthStateEnumHealthy
+
+		// Only a task on the second page of ListTasks is unhealthy
+		if i == 120 {
+			state = elbtypes.TargetHealthStateEnumUnhealthy
+		}
+
+		tasks = append(tasks, awsvpcTask(ip, testTDARN))
+		health = append(health, targetHealth(ip, 8080, state))
+	}
+
+	e := MockECSClient{TestingT: t, LoadBalancers: loadBalancers, Tasks: tasks}
+	lb := MockELBv2Client{TargetHealth: map[string][]elbtypes.TargetHealthDescription{testTGARN: health}}
+
+	running, err := serviceRunningTasks(context.TODO(), e, "test-service", "test-cluster", "")
+
+	if err != nil {
+		t.Fatalf("serviceRunningTasks() error = %v", err)
+	}
+
+	if len(running) != len(tasks) {
+		t.Errorf("serviceRunningTasks() returned %d tasks, want %d", len(running), len(tasks))
+	}
+
+	healthy, err := CheckTargetsHealthy(context.TODO(), e, lb, "test-service", "test-cluster", "")
+
+	if err != nil {
+		t.Fatalf("CheckTargetsHealthy() error = %v", err)
+	}
+
+	if healthy {
+		t.Errorf("CheckTargetsHealthy() = true, want false")
+	}
+}
+
+func TestHasHealthyTask(t *testing.T) {
+	loadBalancers := []ecstypes.LoadBalancer{
+		{TargetGroupArn: aws.String(testTGARN), ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+	}
+
+	td := ecstypes.TaskDefinition{
+		TaskDefinitionArn:    aws.String(testTDARN),
+		ContainerDefinitions: []ecstypes.ContainerDefinition{{Name: aws.String("app")}},
+	}
+
+	healthChecked := ecstypes.TaskDefinition{
+		TaskDefinitionArn: aws.String(testTDARN),
+		ContainerDefinitions: []ecstypes.ContainerDefinition{
+			{Name: aws.String("app"), HealthCheck: &ecstypes.HealthCheck{Command: []string{"CMD", "true"}}},
+		},
+	}
+
+	// task returns a running task of the new revision whose app container has health
+	task := func(ip string, health ecstypes.HealthStatus) ecstypes.Task {
+		task := awsvpcTask(ip, testTDARN)
+		task.LastStatus = aws.String("RUNNING")
+		task.Containers[0].HealthStatus = health
+		return task
+	}
+
+	pending := awsvpcTask("10.0.0.1", testTDARN)
+	pending.LastStatus = aws.String("PENDING")
+
+	tests := []struct {
+		name          string
+		td            ecstypes.TaskDefinition
+		tasks         []ecstypes.Task
+		checkTargets  bool
+		loadBalancers []ecstypes.LoadBalancer
+		targetHealth  []elbtypes.TargetHealthDescription
+		want          bool
+	}{
+		{
+			name:  "running-without-health-check",
+			td:    td,
+			tasks: []ecstypes.Task{task("10.0.0.1", ecstypes.HealthStatusUnknown)},
+			want:  true,
+		},
+		{
+			name:  "pending",
+			td:    td,
+			tasks: []ecstypes.Task{pending},
+			want:  false,
+		},
+		{
+			name:  "previous-revision",
+			td:    td,
+			tasks: []ecstypes.Task{awsvpcTask("10.0.0.1", "old-revision")},
+			want:  false,
+		},
+		{
+			name:  "health-check-not-passed",
+			td:    healthChecked,
+			tasks: []ecstypes.Task{task("10.0.0.1", ecstypes.HealthStatusUnknown)},
+			want:  false,
+		},
+		{
+			name:  "health-check-passed",
+			td:    healthChecked,
+			tasks: []ecstypes.Task{task("10.0.0.1", ecstypes.HealthStatusUnknown), task("10.0.0.2", ecstypes.HealthStatusHealthy)},
+			want:  true,
+		},
+		{
+			name:          "target-initial",
+			td:            td,
+			tasks:         []ecstypes.Task{task("10.0.0.1", ecstypes.HealthStatusUnknown)},
+			checkTargets:  true,
+			loadBalancers: loadBalancers,
+			targetHealth:  []elbtypes.TargetHealthDescription{targetHealth("10.0.0.1", 8080, elbtypes.TargetHealthStateEnumInitial)},
+			want:          false,
+		},
+		{
+			name:          "one-target-healthy",
+			td:            td,
+			tasks:         []ecstypes.Task{task("10.0.0.1", ecstypes.HealthStatusUnknown), task("10.0.0.2", ecstypes.HealthStatusUnknown)},
+			checkTargets:  true,
+			loadBalancers: loadBalancers,
+			targetHealth: []elbtypes.TargetHealthDescription{
+				targetHealth("10.0.0.1", 8080, elbtypes.TargetHealthStateEnumInitial),
+				targetHealth("10.0.0.2", 8080, elbtypes.TargetHealthStateEnumHealthy),
+			},
+			want: true,
+		},
+		{
+			// Target health is only checked when it is configured
+			name:          "targets-not-checked",
+			td:            td,
+			tasks:         []ecstypes.Task{task("10.0.0.1", ecstypes.HealthStatusUnknown)},
+			loadBalancers: loadBalancers,
+			targetHealth:  []elbtypes.TargetHealthDescription{targetHealth("10.0.0.1", 8080, elbtypes.TargetHealthStateEnumInitial)},
+			want:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := MockECSClient{TestingT: t, LoadBalancers: tt.loadBalancers, Tasks: tt.tasks}
+
+			var lb types.ELBv2Client
+
+			if tt.checkTargets {
+				lb = MockELBv2Client{TargetHealth: map[string][]elbtypes.TargetHealthDescription{testTGARN: tt.targetHealth}}
+			}
+
+			got, err := HasHealthyTask(context.TODO(), e, lb, "test-service", "test-cluster", tt.td)
+
+			if err != nil {
+				t.Errorf("HasHealthyTask() error = %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("HasHealthyTask() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
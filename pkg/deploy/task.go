@@ -3,8 +3,13 @@ package deploy
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/tracing"
 	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
@@ -25,7 +30,7 @@ func RetrieveTaskDefinition(ctx context.Context, c types.ECSClient, taskDefiniti
 	)
 
 	if err != nil {
-		log.Println("Error describing task definition: ", err.Error())
+		logging.From(ctx).Error("Error describing task definition", logging.Err(err))
 		return td, err
 	}
 
@@ -34,38 +39,51 @@ func RetrieveTaskDefinition(ctx context.Context, c types.ECSClient, taskDefiniti
 	return td, nil
 }
 
-func CreateNewTaskDefinitionRevision(ctx context.Context, c types.ECSClient, taskDefintion ecstypes.TaskDefinition, containerName string, newImage string) (*ecstypes.TaskDefinition, error) {
-	updatedContainers, err := updateImage(taskDefintion.ContainerDefinitions, containerName, newImage)
+// RetrieveTaskDefinitionTags returns the tags of a task definition
+func RetrieveTaskDefinitionTags(ctx context.Context, c types.ECSClient, taskDefinitionARN string) ([]ecstypes.Tag, error) {
+	i := ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinitionARN),
+		Include:        []ecstypes.TaskDefinitionField{ecstypes.TaskDefinitionFieldTags},
+	}
+
+	out, err := c.DescribeTaskDefinition(
+		ctx,
+		&i,
+	)
 
 	if err != nil {
+		logging.From(ctx).Error("Error describing task definition tags", logging.Err(err))
 		return nil, err
 	}
 
-	i := ecs.RegisterTaskDefinitionInput{
-		ContainerDefinitions:    updatedContainers,
-		Family:                  taskDefintion.Family,
-		Cpu:                     taskDefintion.Cpu,
-		EphemeralStorage:        taskDefintion.EphemeralStorage,
-		ExecutionRoleArn:        taskDefintion.ExecutionRoleArn,
-		InferenceAccelerators:   taskDefintion.InferenceAccelerators,
-		IpcMode:                 taskDefintion.IpcMode,
-		Memory:                  taskDefintion.Memory,
-		NetworkMode:             taskDefintion.NetworkMode,
-		PidMode:                 taskDefintion.PidMode,
-		PlacementConstraints:    taskDefintion.PlacementConstraints,
-		ProxyConfiguration:      taskDefintion.ProxyConfiguration,
-		RequiresCompatibilities: taskDefintion.RequiresCompatibilities,
-		TaskRoleArn:             taskDefintion.TaskRoleArn,
-		Volumes:                 taskDefintion.Volumes,
+	return out.Tags, nil
+}
+
+// CreateNewTaskDefinitionRevision registers a new revision of taskDefintion with changes applied.
+// All containers are updated in a single RegisterTaskDefinition call
+func CreateNewTaskDefinitionRevision(ctx context.Context, c types.ECSClient, taskDefintion ecstypes.TaskDefinition, changes TaskDefinitionChanges) (td *ecstypes.TaskDefinition, err error) {
+	ctx, span := tracing.Start(ctx, "register revision", tracing.String("ecs.task_definition_family", aws.ToString(taskDefintion.Family)))
+	defer func() {
+		if td != nil {
+			span.SetAttributes(tracing.Int("ecs.task_definition_revision", int(td.Revision)), tracing.String("ecs.task_definition", aws.ToString(td.TaskDefinitionArn)))
+		}
+
+		span.End(err)
+	}()
+
+	i, err := NewTaskDefinitionRevisionInput(ctx, c, taskDefintion, changes)
+
+	if err != nil {
+		return nil, err
 	}
 
 	out, err := c.RegisterTaskDefinition(
 		ctx,
-		&i,
+		i,
 	)
 
 	if err != nil {
-		log.Println("Error registering task definition: ", err.Error())
+		logging.From(ctx).Error("Error registering task definition", logging.Err(err))
 		return nil, err
 	}
 
@@ -73,26 +91,262 @@ func CreateNewTaskDefinitionRevision(ctx context.Context, c types.ECSClient, tas
 
 }
 
-func updateImage(containers []ecstypes.ContainerDefinition, containerName string, newImage string) ([]ecstypes.ContainerDefinition, error) {
+// NewTaskDefinitionRevisionInput returns the input that registers a new revision of taskDefintion with changes applied
+// Nothing is registered, so it can be used to plan a deployment
+func NewTaskDefinitionRevisionInput(ctx context.Context, c types.ECSClient, taskDefintion ecstypes.TaskDefinition, changes TaskDefinitionChanges) (*ecs.RegisterTaskDefinitionInput, error) {
+	if changes.IsEmpty() {
+		return nil, errors.New("no changes to task definition")
+	}
+
+	updatedContainers, err := updateImages(ctx, taskDefintion.ContainerDefinitions, changes.Images)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updatedContainers, err = updateEnvironment(ctx, updatedContainers, changes.Container, changes.Environment, changes.Secrets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []ecstypes.Tag
+
+	if taskDefintion.TaskDefinitionArn != nil {
+		tags, err = RetrieveTaskDefinitionTags(ctx, c, *taskDefintion.TaskDefinitionArn)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	i, dropped := CloneTaskDefinition(taskDefintion, tags)
+	i.ContainerDefinitions = updatedContainers
+	i.Tags = mergeTags(i.Tags, changes.Tags)
+
+	for _, field := range dropped {
+		logging.From(ctx).Warn(fmt.Sprintf("'%s' cannot be carried over to the new task definition revision", field))
+	}
+
+	return i, nil
+}
+
+// updateImages returns a copy of containers with the image of each named container replaced
+// It returns an error without modifying anything if any container in images does not exist
+func updateImages(ctx context.Context, containers []ecstypes.ContainerDefinition, images map[string]string) ([]ecstypes.ContainerDefinition, error) {
 	var resp []ecstypes.ContainerDefinition
 
-	containerFound := false
+	existing := make(map[string]bool)
 
-	for idx, container := range containers {
-		if *container.Name == containerName {
-			log.Printf("Updating container '%s' from '%s' to '%s'\n", *container.Name, *container.Image, newImage)
-			resp = append(resp, container)
-			resp[idx].Image = &newImage
-			containerFound = true
-		} else {
-			resp = append(resp, container)
+	for _, container := range containers {
+		existing[*container.Name] = true
+	}
+
+	containersMissing := false
+
+	for containerName := range images {
+		if !existing[containerName] {
+			logging.From(ctx).Error(fmt.Sprintf("Container '%s' not found. Cannot proceed.", containerName), logging.F("container", containerName))
+			containersMissing = true
 		}
 	}
 
-	if !containerFound {
-		log.Printf("Container '%s' not found. Cannot proceed.\n", containerName)
+	if containersMissing {
 		return resp, errors.New("container not found")
 	}
 
+	for _, container := range containers {
+		if newImage, ok := images[*container.Name]; ok {
+			logging.From(ctx).Info(fmt.Sprintf("Updating container '%s' from '%s' to '%s'", *container.Name, aws.ToString(container.Image), newImage), logging.F("container", *container.Name))
+			container.Image = aws.String(newImage)
+		}
+
+		resp = append(resp, container)
+	}
+
 	return resp, nil
 }
+
+// updateEnvironment returns a copy of containers with the environment variables and secrets of containerName added, overridden or removed
+// Secret values are never logged
+func updateEnvironment(ctx context.Context, containers []ecstypes.ContainerDefinition, containerName string, environment map[string]*string, secrets map[string]*string) ([]ecstypes.ContainerDefinition, error) {
+	if len(environment) == 0 && len(secrets) == 0 {
+		return containers, nil
+	}
+
+	resp := make([]ecstypes.ContainerDefinition, len(containers))
+	copy(resp, containers)
+
+	for idx, container := range resp {
+		if *container.Name != containerName {
+			continue
+		}
+
+		var env []ecstypes.KeyValuePair
+
+		for _, pair := range container.Environment {
+			value, ok := environment[*pair.Name]
+
+			if !ok {
+				env = append(env, pair)
+			} else if value == nil {
+				logging.From(ctx).Info(fmt.Sprintf("Removing environment variable '%s' from container '%s'", *pair.Name, containerName), logging.F("container", containerName))
+			} else {
+				logging.From(ctx).Info(fmt.Sprintf("Updating environment variable '%s' in container '%s' from '%s' to '%s'", *pair.Name, containerName, aws.ToString(pair.Value), *value), logging.F("container", containerName))
+				env = append(env, ecstypes.KeyValuePair{Name: pair.Name, Value: aws.String(*value)})
+			}
+		}
+
+		for _, name := range newKeys(environment, environmentNames(container.Environment)) {
+			logging.From(ctx).Info(fmt.Sprintf("Adding environment variable '%s' to container '%s' with value '%s'", name, containerName, *environment[name]), logging.F("container", containerName))
+			env = append(env, ecstypes.KeyValuePair{Name: aws.String(name), Value: aws.String(*environment[name])})
+		}
+
+		var containerSecrets []ecstypes.Secret
+
+		for _, secret := range container.Secrets {
+			value, ok := secrets[*secret.Name]
+
+			if !ok {
+				containerSecrets = append(containerSecrets, secret)
+			} else if value == nil {
+				logging.From(ctx).Info(fmt.Sprintf("Removing secret '%s' from container '%s'", *secret.Name, containerName), logging.F("container", containerName))
+			} else {
+				logging.From(ctx).Info(fmt.Sprintf("Updating secret '%s' in container '%s' (value hidden)", *secret.Name, containerName), logging.F("container", containerName))
+				containerSecrets = append(containerSecrets, ecstypes.Secret{Name: secret.Name, ValueFrom: aws.String(*value)})
+			}
+		}
+
+		for _, name := range newKeys(secrets, secretNames(container.Secrets)) {
+			logging.From(ctx).Info(fmt.Sprintf("Adding secret '%s' to container '%s' (value hidden)", name, containerName), logging.F("container", containerName))
+			containerSecrets = append(containerSecrets, ecstypes.Secret{Name: aws.String(name), ValueFrom: aws.String(*secrets[name])})
+		}
+
+		resp[idx].Environment = env
+		resp[idx].Secrets = containerSecrets
+
+		return resp, nil
+	}
+
+	logging.From(ctx).Error(fmt.Sprintf("Container '%s' not found. Cannot update environment.", containerName), logging.F("container", containerName))
+	return nil, errors.New("container not found")
+}
+
+// newKeys returns the sorted keys of m with a non-nil value that are not in existing
+func newKeys(m map[string]*string, existing map[string]bool) []string {
+	var keys []string
+
+	for k, v := range m {
+		if v != nil && !existing[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func environmentNames(env []ecstypes.KeyValuePair) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, pair := range env {
+		names[*pair.Name] = true
+	}
+
+	return names
+}
+
+func secretNames(secrets []ecstypes.Secret) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, secret := range secrets {
+		names[*secret.Name] = true
+	}
+
+	return names
+}
+
+// mergeTags returns tags with every tag in overrides added or replaced
+func mergeTags(tags []ecstypes.Tag, overrides map[string]string) []ecstypes.Tag {
+	if len(overrides) == 0 {
+		return tags
+	}
+
+	var resp []ecstypes.Tag
+
+	for _, tag := range tags {
+		if _, ok := overrides[*tag.Key]; !ok {
+			resp = append(resp, tag)
+		}
+	}
+
+	keys := make([]string, 0, len(overrides))
+
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		resp = append(resp, ecstypes.Tag{Key: aws.String(k), Value: aws.String(overrides[k])})
+	}
+
+	return resp
+}
+
+// PreviousTaskDefinitionRevision returns the ARN of the newest ACTIVE revision of the family of taskDefinition that is older than it
+func PreviousTaskDefinitionRevision(ctx context.Context, c types.ECSClient, taskDefinition ecstypes.TaskDefinition) (string, error) {
+	family := aws.ToString(taskDefinition.Family)
+
+	i := ecs.ListTaskDefinitionsInput{
+		FamilyPrefix: aws.String(family),
+		Status:       ecstypes.TaskDefinitionStatusActive,
+		Sort:         ecstypes.SortOrderDesc,
+	}
+
+	for {
+		out, err := c.ListTaskDefinitions(ctx, &i)
+
+		if err != nil {
+			logging.From(ctx).Error("Error listing task definitions", logging.Err(err))
+			return "", err
+		}
+
+		for _, arn := range out.TaskDefinitionArns {
+			// FamilyPrefix also matches longer family names
+			arnFamily, revision, ok := parseTaskDefinitionARN(arn)
+
+			if ok && arnFamily == family && revision < taskDefinition.Revision {
+				return arn, nil
+			}
+		}
+
+		if out.NextToken == nil {
+			break
+		}
+
+		i.NextToken = out.NextToken
+	}
+
+	return "", &ErrNoResults{Message: fmt.Sprintf("task definition family '%s' has no active revision older than %d", family, taskDefinition.Revision)}
+}
+
+// parseTaskDefinitionARN returns the family and revision of a task definition ARN
+func parseTaskDefinitionARN(arn string) (string, int32, bool) {
+	name := arn[strings.LastIndex(arn, "/")+1:]
+	sep := strings.LastIndex(name, ":")
+
+	if sep == -1 {
+		return "", 0, false
+	}
+
+	revision, err := strconv.ParseInt(name[sep+1:], 10, 32)
+
+	if err != nil {
+		return "", 0, false
+	}
+
+	return name[:sep], int32(revision), true
+}
@@ -26,11 +26,10 @@ func TestRetrieveTaskDefinition1(t *testing.T) {
 	assert.Equal(t, "sidecar", *o.ContainerDefinitions[1].Name)
 }
 
-func Test_updateImage(t *testing.T) {
+func Test_updateImages(t *testing.T) {
 	type args struct {
-		containers    []ecstypes.ContainerDefinition
-		containerName string
-		newImage      string
+		containers []ecstypes.ContainerDefinition
+		images     map[string]string
 	}
 	tests := []struct {
 		name    string
@@ -55,8 +54,7 @@ func Test_updateImage(t *testing.T) {
 						Image:   aws.String("foo/app:2"),
 					},
 				},
-				containerName: "app",
-				newImage:      "foo/app:3",
+				images: map[string]string{"app": "foo/app:3"},
 			},
 
 			want: []ecstypes.ContainerDefinition{
@@ -75,16 +73,71 @@ func Test_updateImage(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "update-multiple-containers",
+			args: args{
+				containers: []ecstypes.ContainerDefinition{
+					{
+						Image: aws.String("foo/app:2"),
+						Name:  aws.String("app"),
+					},
+					{
+						Image: aws.String("foo/migrations:2"),
+						Name:  aws.String("migrations"),
+					},
+					{
+						Image: aws.String("fluent/fluent-bit:1"),
+						Name:  aws.String("log-router"),
+					},
+				},
+				images: map[string]string{
+					"app":        "foo/app:3",
+					"migrations": "foo/migrations:3",
+				},
+			},
+			want: []ecstypes.ContainerDefinition{
+				{
+					Image: aws.String("foo/app:3"),
+					Name:  aws.String("app"),
+				},
+				{
+					Image: aws.String("foo/migrations:3"),
+					Name:  aws.String("migrations"),
+				},
+				{
+					Image: aws.String("fluent/fluent-bit:1"),
+					Name:  aws.String("log-router"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing-container",
+			args: args{
+				containers: []ecstypes.ContainerDefinition{
+					{
+						Image: aws.String("foo/app:2"),
+						Name:  aws.String("app"),
+					},
+				},
+				images: map[string]string{
+					"app":        "foo/app:3",
+					"migrations": "foo/migrations:3",
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := updateImage(tt.args.containers, tt.args.containerName, tt.args.newImage)
+			got, err := updateImages(context.TODO(), tt.args.containers, tt.args.images)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("updateImage() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("updateImages() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("updateImage() = %v, want %v", got, tt.want)
+				t.Errorf("updateImages() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -95,8 +148,7 @@ func TestCreateNewTaskDefinitionRevision(t *testing.T) {
 		ctx           context.Context
 		c             types.ECSClient
 		taskDefintion ecstypes.TaskDefinition
-		containerName string
-		newImage      string
+		changes       TaskDefinitionChanges
 	}
 	tests := []struct {
 		name    string
@@ -113,8 +165,7 @@ func TestCreateNewTaskDefinitionRevision(t *testing.T) {
 					TestingT:        t,
 					DeploymentState: "COMPLETED",
 				},
-				containerName: "app",
-				newImage:      "foo/app:3",
+				changes: TaskDefinitionChanges{Images: map[string]string{"app": "foo/app:3"}},
 				taskDefintion: ecstypes.TaskDefinition{
 					Compatibilities: []ecstypes.Compatibility{},
 					ContainerDefinitions: []ecstypes.ContainerDefinition{
@@ -191,7 +242,7 @@ func TestCreateNewTaskDefinitionRevision(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CreateNewTaskDefinitionRevision(tt.args.ctx, tt.args.c, tt.args.taskDefintion, tt.args.containerName, tt.args.newImage)
+			got, err := CreateNewTaskDefinitionRevision(tt.args.ctx, tt.args.c, tt.args.taskDefintion, tt.args.changes)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateNewTaskDefinitionRevision() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -260,3 +311,168 @@ func TestRetrieveTaskDefinition(t *testing.T) {
 		})
 	}
 }
+
+func Test_updateEnvironment(t *testing.T) {
+	containers := []ecstypes.ContainerDefinition{
+		{
+			Name: aws.String("app"),
+			Environment: []ecstypes.KeyValuePair{
+				{Name: aws.String("GIT_SHA"), Value: aws.String("abc123")},
+				{Name: aws.String("LOG_LEVEL"), Value: aws.String("debug")},
+				{Name: aws.String("PORT"), Value: aws.String("8080")},
+			},
+			Secrets: []ecstypes.Secret{
+				{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("arn:aws:secretsmanager:us-east-2:123456789012:secret:db-v1")},
+				{Name: aws.String("OLD_TOKEN"), ValueFrom: aws.String("arn:aws:secretsmanager:us-east-2:123456789012:secret:token")},
+			},
+		},
+		{
+			Name:        aws.String("sidecar"),
+			Environment: []ecstypes.KeyValuePair{{Name: aws.String("GIT_SHA"), Value: aws.String("abc123")}},
+		},
+	}
+
+	type args struct {
+		containerName string
+		environment   map[string]*string
+		secrets       map[string]*string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []ecstypes.ContainerDefinition
+		wantErr bool
+	}{
+		{
+			name: "add-override-remove",
+			args: args{
+				containerName: "app",
+				environment: map[string]*string{
+					"GIT_SHA":   aws.String("def456"),
+					"LOG_LEVEL": nil,
+					"RELEASE":   aws.String("42"),
+				},
+				secrets: map[string]*string{
+					"DB_PASSWORD": aws.String("arn:aws:secretsmanager:us-east-2:123456789012:secret:db-v2"),
+					"OLD_TOKEN":   nil,
+					"API_KEY":     aws.String("arn:aws:secretsmanager:us-east-2:123456789012:secret:api"),
+				},
+			},
+			want: []ecstypes.ContainerDefinition{
+				{
+					Name: aws.String("app"),
+					Environment: []ecstypes.KeyValuePair{
+						{Name: aws.String("GIT_SHA"), Value: aws.String("def456")},
+						{Name: aws.String("PORT"), Value: aws.String("8080")},
+						{Name: aws.String("RELEASE"), Value: aws.String("42")},
+					},
+					Secrets: []ecstypes.Secret{
+						{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("arn:aws:secretsmanager:us-east-2:123456789012:secret:db-v2")},
+						{Name: aws.String("API_KEY"), ValueFrom: aws.String("arn:aws:secretsmanager:us-east-2:123456789012:secret:api")},
+					},
+				},
+				containers[1],
+			},
+			wantErr: false,
+		},
+		{
+			name: "no-changes",
+			args: args{
+				containerName: "app",
+			},
+			want:    containers,
+			wantErr: false,
+		},
+		{
+			name: "missing-container",
+			args: args{
+				containerName: "worker",
+				environment:   map[string]*string{"GIT_SHA": aws.String("def456")},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := updateEnvironment(context.TODO(), containers, tt.args.containerName, tt.args.environment, tt.args.secrets)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("updateEnvironment() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("updateEnvironment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The original containers must not be modified since they are used for rollbacks
+	assert.Equal(t, 3, len(containers[0].Environment))
+	assert.Equal(t, "abc123", *containers[0].Environment[0].Value)
+}
+
+func Test_mergeTags(t *testing.T) {
+	tags := []ecstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("platform")},
+		{Key: aws.String("drone-deploy-ecs:image:app"), Value: aws.String("foo/app:2")},
+	}
+
+	got := mergeTags(tags, map[string]string{"drone-deploy-ecs:image:app": "foo/app:3"})
+
+	want := []ecstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("platform")},
+		{Key: aws.String("drone-deploy-ecs:image:app"), Value: aws.String("foo/app:3")},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeTags() = %v, want %v", got, want)
+	}
+
+	assert.Equal(t, "foo/app:2", *tags[1].Value)
+}
+
+func TestPreviousTaskDefinitionRevision(t *testing.T) {
+	arns := []string{
+		"arn:aws:ecs:us-west-2:123456789012:task-definition/webapp-worker:9",
+		"arn:aws:ecs:us-west-2:123456789012:task-definition/webapp:42",
+		"arn:aws:ecs:us-west-2:123456789012:task-definition/webapp:40",
+		"arn:aws:ecs:us-west-2:123456789012:task-definition/webapp:39",
+	}
+
+	tests := []struct {
+		name     string
+		revision int32
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "skips-deregistered-revisions",
+			revision: 42,
+			want:     "arn:aws:ecs:us-west-2:123456789012:task-definition/webapp:40",
+		},
+		{
+			name:     "current-revision-is-not-the-newest",
+			revision: 40,
+			want:     "arn:aws:ecs:us-west-2:123456789012:task-definition/webapp:39",
+		},
+		{
+			name:     "no-older-revision",
+			revision: 39,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := ecstypes.TaskDefinition{Family: aws.String("webapp"), Revision: tt.revision}
+
+			got, err := PreviousTaskDefinitionRevision(context.TODO(), MockECSClient{TaskDefinitionARNs: arns}, td)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PreviousTaskDefinitionRevision() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// GetPrimaryTaskSet returns the primary task set of a service that uses the EXTERNAL deployment controller
+func GetPrimaryTaskSet(ctx context.Context, c types.ECSClient, service string, cluster string) (*ecstypes.TaskSet, error) {
+	i := ecs.DescribeServicesInput{
+		Services: []string{service},
+		Cluster:  aws.String(cluster),
+	}
+
+	out, err := c.DescribeServices(
+		ctx,
+		&i,
+	)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing service", logging.Err(err))
+		return nil, err
+	}
+
+	for _, taskSet := range out.Services[0].TaskSets {
+		if aws.ToString(taskSet.Status) == "PRIMARY" {
+			return &taskSet, nil
+		}
+	}
+
+	return nil, &ErrNoResults{Message: fmt.Sprintf("service '%s' has no primary task set", service)}
+}
+
+// CreateTaskSet creates a task set running taskDefinitionARN at scalePercent of the service's desired count
+// The network, load balancer, service registry and capacity settings are copied from primary
+func CreateTaskSet(ctx context.Context, c types.ECSClient, service string, cluster string, primary ecstypes.TaskSet, taskDefinitionARN string, scalePercent float64) (*ecstypes.TaskSet, error) {
+	i := ecs.CreateTaskSetInput{
+		Cluster:                  aws.String(cluster),
+		Service:                  aws.String(service),
+		TaskDefinition:           aws.String(taskDefinitionARN),
+		CapacityProviderStrategy: primary.CapacityProviderStrategy,
+		LaunchType:               primary.LaunchType,
+		LoadBalancers:            primary.LoadBalancers,
+		NetworkConfiguration:     primary.NetworkConfiguration,
+		PlatformVersion:          primary.PlatformVersion,
+		ServiceRegistries:        primary.ServiceRegistries,
+		Scale: &ecstypes.Scale{
+			Unit:  ecstypes.ScaleUnitPercent,
+			Value: scalePercent,
+		},
+	}
+
+	out, err := c.CreateTaskSet(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error creating task set", logging.Err(err))
+		return nil, err
+	}
+
+	return out.TaskSet, nil
+}
+
+// ScaleTaskSet sets the scale of a task set as a percent of the service's desired count
+func ScaleTaskSet(ctx context.Context, c types.ECSClient, service string, cluster string, taskSetID string, scalePercent float64) error {
+	i := ecs.UpdateTaskSetInput{
+		Cluster: aws.String(cluster),
+		Service: aws.String(service),
+		TaskSet: aws.String(taskSetID),
+		Scale: &ecstypes.Scale{
+			Unit:  ecstypes.ScaleUnitPercent,
+			Value: scalePercent,
+		},
+	}
+
+	_, err := c.UpdateTaskSet(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error scaling task set", logging.Err(err))
+	}
+
+	return err
+}
+
+// CheckTaskSetSteadyState returns true once every task of a task set is running and ECS considers the task set stable
+func CheckTaskSetSteadyState(ctx context.Context, c types.ECSClient, service string, cluster string, taskSetID string) (bool, error) {
+	i := ecs.DescribeTaskSetsInput{
+		Cluster:  aws.String(cluster),
+		Service:  aws.String(service),
+		TaskSets: []string{taskSetID},
+	}
+
+	out, err := c.DescribeTaskSets(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error describing task set", logging.Err(err))
+		return true, err
+	}
+
+	if len(out.TaskSets) == 0 {
+		return true, errors.New("task set not found")
+	}
+
+	taskSet := out.TaskSets[0]
+
+	if aws.ToString(taskSet.Status) == "DRAINING" {
+		return true, errors.New("task set is draining")
+	}
+
+	logging.From(ctx).Info(
+		fmt.Sprintf("Task set '%s' has %d running tasks, %d pending tasks and %d desired tasks", taskSetID, taskSet.RunningCount, taskSet.PendingCount, taskSet.ComputedDesiredCount),
+		logging.Service(service),
+		logging.F("task_set", taskSetID),
+	)
+
+	if taskSet.StabilityStatus != ecstypes.StabilityStatusSteadyState || taskSet.RunningCount != taskSet.ComputedDesiredCount {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// PromoteTaskSet makes a task set the primary task set of its service
+func PromoteTaskSet(ctx context.Context, c types.ECSClient, service string, cluster string, taskSetID string) error {
+	i := ecs.UpdateServicePrimaryTaskSetInput{
+		Cluster:        aws.String(cluster),
+		Service:        aws.String(service),
+		PrimaryTaskSet: aws.String(taskSetID),
+	}
+
+	_, err := c.UpdateServicePrimaryTaskSet(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error updating primary task set", logging.Err(err))
+	}
+
+	return err
+}
+
+// DeleteTaskSet deletes a task set. force deletes the task set even if it has not been scaled down to zero
+func DeleteTaskSet(ctx context.Context, c types.ECSClient, service string, cluster string, taskSetID string, force bool) error {
+	i := ecs.DeleteTaskSetInput{
+		Cluster: aws.String(cluster),
+		Service: aws.String(service),
+		TaskSet: aws.String(taskSetID),
+		Force:   aws.Bool(force),
+	}
+
+	_, err := c.DeleteTaskSet(ctx, &i)
+
+	if err != nil {
+		logging.From(ctx).Error("Error deleting task set", logging.Err(err))
+	}
+
+	return err
+}
@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gotest.tools/assert"
+)
+
+func TestGetPrimaryTaskSet(t *testing.T) {
+	c := MockECSClient{
+		TestingT: t,
+		TaskSets: []ecstypes.TaskSet{
+			{Id: aws.String("ts-old"), Status: aws.String("ACTIVE")},
+			{Id: aws.String("ts-primary"), Status: aws.String("PRIMARY"), TaskDefinition: aws.String(testTDARN)},
+		},
+	}
+
+	got, err := GetPrimaryTaskSet(context.Background(), c, "test-service", "test-cluster")
+
+	assert.NilError(t, err)
+	assert.Equal(t, "ts-primary", *got.Id)
+
+	_, err = GetPrimaryTaskSet(context.Background(), MockECSClient{TestingT: t}, "test-service", "test-cluster")
+
+	_, ok := err.(*ErrNoResults)
+	assert.Assert(t, ok)
+}
+
+func TestCreateTaskSet(t *testing.T) {
+	primary := ecstypes.TaskSet{
+		Id:         aws.String("ts-primary"),
+		LaunchType: ecstypes.LaunchTypeFargate,
+		LoadBalancers: []ecstypes.LoadBalancer{
+			{ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+		},
+		NetworkConfiguration: &ecstypes.NetworkConfiguration{
+			AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{Subnets: []string{"subnet-1"}},
+		},
+	}
+
+	got, err := CreateTaskSet(context.Background(), MockECSClient{TestingT: t}, "test-service", "test-cluster", primary, testTDARN, 10)
+
+	assert.NilError(t, err)
+	assert.Equal(t, "ts-new", *got.Id)
+	assert.Equal(t, testTDARN, *got.TaskDefinition)
+	assert.Equal(t, ecstypes.LaunchTypeFargate, got.LaunchType)
+	assert.Equal(t, "app", *got.LoadBalancers[0].ContainerName)
+	assert.Equal(t, "subnet-1", got.NetworkConfiguration.AwsvpcConfiguration.Subnets[0])
+	assert.Equal(t, ecstypes.ScaleUnitPercent, got.Scale.Unit)
+	assert.Equal(t, float64(10), got.Scale.Value)
+}
+
+func TestCheckTaskSetSteadyState(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       MockECSClient
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "steady",
+			c:       MockECSClient{TestingT: t, TaskSetStabilityStatus: ecstypes.StabilityStatusSteadyState},
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name:    "stabilizing",
+			c:       MockECSClient{TestingT: t, TaskSetStabilityStatus: ecstypes.StabilityStatusStabilizing},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:    "error",
+			c:       MockECSClient{TestingT: t, WantError: true},
+			want:    true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckTaskSetSteadyState(context.Background(), tt.c, "test-service", "test-cluster", "ts-new")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckTaskSetSteadyState() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CheckTaskSetSteadyState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskSetUpdates(t *testing.T) {
+	calls := []string{}
+	c := MockECSClient{TestingT: t, TaskSetCalls: &calls}
+
+	assert.NilError(t, ScaleTaskSet(context.Background(), c, "test-service", "test-cluster", "ts-new", 100))
+	assert.NilError(t, PromoteTaskSet(context.Background(), c, "test-service", "test-cluster", "ts-new"))
+	assert.NilError(t, DeleteTaskSet(context.Background(), c, "test-service", "test-cluster", "ts-primary", true))
+
+	assert.DeepEqual(t, []string{
+		"UpdateTaskSet:ts-new",
+		"UpdateServicePrimaryTaskSet:ts-new",
+		"DeleteTaskSet:ts-primary",
+	}, calls)
+}
@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDB attribute names. The table must have a string partition key named LockID
+const (
+	dynamoDBKeyAttribute     = "LockID"
+	dynamoDBOwnerAttribute   = "Owner"
+	dynamoDBExpiresAttribute = "Expires"
+)
+
+// DynamoDB stores locks as items in a DynamoDB table and uses conditional writes, so only one owner can hold a lock
+type DynamoDB struct {
+	Client types.DynamoDBClient
+	Table  string
+}
+
+func (d DynamoDB) Acquire(ctx context.Context, key Key, owner string, ttl time.Duration) error {
+	now := time.Now()
+
+	_, err := d.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.Table),
+		Item: map[string]ddbtypes.AttributeValue{
+			dynamoDBKeyAttribute:     &ddbtypes.AttributeValueMemberS{Value: key.String()},
+			dynamoDBOwnerAttribute:   &ddbtypes.AttributeValueMemberS{Value: owner},
+			dynamoDBExpiresAttribute: &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(ttl).Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #owner = :owner OR #expires < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":     dynamoDBKeyAttribute,
+			"#owner":   dynamoDBOwnerAttribute,
+			"#expires": dynamoDBExpiresAttribute,
+		},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":owner": &ddbtypes.AttributeValueMemberS{Value: owner},
+			":now":   &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: ddbtypes.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	var conditionFailed *ddbtypes.ConditionalCheckFailedException
+
+	if errors.As(err, &conditionFailed) {
+		return heldErrorFromItem(key, conditionFailed.Item)
+	}
+
+	return err
+}
+
+func (d DynamoDB) Release(ctx context.Context, key Key, owner string) error {
+	_, err := d.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.Table),
+		Key: map[string]ddbtypes.AttributeValue{
+			dynamoDBKeyAttribute: &ddbtypes.AttributeValueMemberS{Value: key.String()},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]string{
+			"#owner": dynamoDBOwnerAttribute,
+		},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":owner": &ddbtypes.AttributeValueMemberS{Value: owner},
+		},
+	})
+
+	var conditionFailed *ddbtypes.ConditionalCheckFailedException
+
+	// The lock expired and was taken by another owner, so there is nothing to release
+	if errors.As(err, &conditionFailed) {
+		return nil
+	}
+
+	return err
+}
+
+// heldErrorFromItem describes the owner of a lock from its item. The item may be missing if the table does not return it
+func heldErrorFromItem(key Key, item map[string]ddbtypes.AttributeValue) error {
+	held := HeldError{Key: key, Owner: "unknown"}
+
+	if owner, ok := item[dynamoDBOwnerAttribute].(*ddbtypes.AttributeValueMemberS); ok {
+		held.Owner = owner.Value
+	}
+
+	if expires, ok := item[dynamoDBExpiresAttribute].(*ddbtypes.AttributeValueMemberN); ok {
+		if seconds, err := strconv.ParseInt(expires.Value, 10, 64); err == nil {
+			held.Expires = time.Unix(seconds, 0)
+		}
+	}
+
+	return &held
+}
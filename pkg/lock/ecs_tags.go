@@ -0,0 +1,143 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	ownerTagKey   = "drone-deploy-ecs:lock-owner"
+	expiresTagKey = "drone-deploy-ecs:lock-expires"
+)
+
+// ECSTags stores locks as tags on the ECS service itself, so no extra infrastructure is needed
+// Tagging is not atomic. Acquire writes the tags, waits Settle and reads them back, so two deployments that start at the same moment are very unlikely, but not guaranteed, to both hold the lock
+type ECSTags struct {
+	Client types.ECSClient
+	// Settle is how long to wait before checking that no other deployment overwrote the lock
+	Settle time.Duration
+}
+
+func (e ECSTags) Acquire(ctx context.Context, key Key, owner string, ttl time.Duration) error {
+	arn, err := e.serviceARN(ctx, key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := e.checkHeld(ctx, key, arn, owner); err != nil {
+		return err
+	}
+
+	_, err = e.Client.TagResource(ctx, &ecs.TagResourceInput{
+		ResourceArn: aws.String(arn),
+		Tags: []ecstypes.Tag{
+			{Key: aws.String(ownerTagKey), Value: aws.String(owner)},
+			{Key: aws.String(expiresTagKey), Value: aws.String(strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))},
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(e.Settle):
+	}
+
+	// Whoever tagged the service last holds the lock
+	return e.checkHeld(ctx, key, arn, owner)
+}
+
+func (e ECSTags) Release(ctx context.Context, key Key, owner string) error {
+	arn, err := e.serviceARN(ctx, key)
+
+	if err != nil {
+		return err
+	}
+
+	current, _, err := e.holder(ctx, arn)
+
+	if err != nil {
+		return err
+	}
+
+	// The lock expired and was taken by another owner, so there is nothing to release
+	if current != owner {
+		return nil
+	}
+
+	_, err = e.Client.UntagResource(ctx, &ecs.UntagResourceInput{
+		ResourceArn: aws.String(arn),
+		TagKeys:     []string{ownerTagKey, expiresTagKey},
+	})
+
+	return err
+}
+
+// checkHeld returns a HeldError if the lock is held by an owner other than owner and has not expired
+func (e ECSTags) checkHeld(ctx context.Context, key Key, arn string, owner string) error {
+	current, expires, err := e.holder(ctx, arn)
+
+	if err != nil {
+		return err
+	}
+
+	if current == "" || current == owner || expires.Before(time.Now()) {
+		return nil
+	}
+
+	return &HeldError{Key: key, Owner: current, Expires: expires}
+}
+
+// holder returns the owner of the lock on a service and when it expires. The owner is empty if the service is not locked
+func (e ECSTags) holder(ctx context.Context, arn string) (string, time.Time, error) {
+	out, err := e.Client.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{ResourceArn: aws.String(arn)})
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var owner string
+	var expires time.Time
+
+	for _, tag := range out.Tags {
+		switch aws.ToString(tag.Key) {
+		case ownerTagKey:
+			owner = aws.ToString(tag.Value)
+		case expiresTagKey:
+			if seconds, err := strconv.ParseInt(aws.ToString(tag.Value), 10, 64); err == nil {
+				expires = time.Unix(seconds, 0)
+			}
+		}
+	}
+
+	return owner, expires, nil
+}
+
+// serviceARN looks up the ARN of a service, which is needed to tag it
+func (e ECSTags) serviceARN(ctx context.Context, key Key) (string, error) {
+	out, err := e.Client.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(key.Cluster),
+		Services: []string{key.Service},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(out.Services) == 0 || out.Services[0].ServiceArn == nil {
+		return "", errors.New("service not found")
+	}
+
+	return *out.Services[0].ServiceArn, nil
+}
@@ -0,0 +1,221 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+)
+
+// ErrLocked is returned when a lock is held by another owner
+var ErrLocked = errors.New("locked by another deployment")
+
+// ErrLeaseLost is returned by Err when a held lock could not be renewed before its lease expired
+var ErrLeaseLost = errors.New("deployment lock lease lost")
+
+// Key identifies the service a lock protects
+type Key struct {
+	Cluster string
+	Service string
+}
+
+func (k Key) String() string {
+	return k.Cluster + "/" + k.Service
+}
+
+// HeldError describes the owner of a lock that could not be acquired
+type HeldError struct {
+	Key     Key
+	Owner   string
+	Expires time.Time
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("service '%s' is locked by '%s' until %s", e.Key, e.Owner, e.Expires.UTC().Format(time.RFC3339))
+}
+
+func (e *HeldError) Unwrap() error {
+	return ErrLocked
+}
+
+// Backend stores locks
+// Acquire must succeed if the lock is free, has expired or is already held by owner, which is how a lease is renewed
+type Backend interface {
+	Acquire(ctx context.Context, key Key, owner string, ttl time.Duration) error
+	Release(ctx context.Context, key Key, owner string) error
+}
+
+// Locker acquires, renews and releases locks on a set of services
+type Locker struct {
+	Backend Backend
+	// Owner identifies this deployment. It must be unique per deployment
+	Owner string
+	// TTL is the lease of a lock. Leases are renewed every third of TTL until the lock is released, so only crashed deployments let a lock expire
+	TTL time.Duration
+	// Wait is how long to wait for a lock held by another deployment. Zero means fail immediately
+	Wait time.Duration
+	// RetryInterval is the time between attempts while waiting for a lock
+	RetryInterval time.Duration
+
+	held []Key
+	stop chan struct{}
+	done chan struct{}
+	// lost is closed when a lease is lost. err holds the reason
+	lost chan struct{}
+	mu   sync.Mutex
+	err  error
+	// logger is the logger of the context passed to Lock. Renewing and releasing locks log to it
+	logger logging.Logger
+}
+
+// Lock acquires a lock on every key and keeps renewing the leases until Unlock is called
+// Keys are locked in a fixed order so two deployments of overlapping services cannot deadlock
+// If any lock cannot be acquired, the locks that were acquired are released
+func (l *Locker) Lock(ctx context.Context, keys []Key) error {
+	l.logger = logging.From(ctx)
+
+	sorted := make([]Key, len(keys))
+	copy(sorted, keys)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+
+	for _, key := range sorted {
+		if err := l.acquire(ctx, key); err != nil {
+			l.Unlock()
+			return err
+		}
+
+		l.logger.Info(fmt.Sprintf("Acquired deployment lock on service '%s' for %s", key, l.TTL))
+		l.held = append(l.held, key)
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	l.lost = make(chan struct{})
+	l.err = nil
+
+	go l.renew(l.stop, l.done)
+
+	return nil
+}
+
+// acquire retries until the lock on key is acquired or Wait has passed
+func (l *Locker) acquire(ctx context.Context, key Key) error {
+	deadline := time.Now().Add(l.Wait)
+
+	for {
+		err := l.Backend.Acquire(ctx, key, l.Owner, l.TTL)
+
+		if err == nil || !errors.Is(err, ErrLocked) {
+			return err
+		}
+
+		if !time.Now().Add(l.RetryInterval).Before(deadline) {
+			logging.From(ctx).Error("Unable to acquire deployment lock", logging.Err(err))
+			return err
+		}
+
+		logging.From(ctx).Info("Waiting for deployment lock", logging.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.RetryInterval):
+		}
+	}
+}
+
+// renew extends the lease of every held lock every third of TTL until stop is closed
+// A lease is lost if another owner holds the lock, or if it can not be renewed before it expires. Renewing stops once a lease is lost
+func (l *Locker) renew(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.TTL / 3)
+	defer ticker.Stop()
+
+	renewed := make(map[Key]time.Time, len(l.held))
+
+	for _, key := range l.held {
+		renewed[key] = time.Now()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, key := range l.held {
+				ctx, cancel := context.WithTimeout(context.Background(), l.TTL/3)
+				err := l.Backend.Acquire(ctx, key, l.Owner, l.TTL)
+				cancel()
+
+				if err == nil {
+					renewed[key] = time.Now()
+					continue
+				}
+
+				// The lease has to outlive the next attempt for a failed renewal to be retried
+				if errors.Is(err, ErrLocked) || !renewed[key].Add(l.TTL).After(time.Now().Add(l.TTL/3)) {
+					l.logger.Error(fmt.Sprintf("Lost deployment lock on service '%s'", key), logging.Err(err))
+					l.lose(fmt.Errorf("%w on service '%s': %v", ErrLeaseLost, key, err))
+					return
+				}
+
+				l.logger.Warn(fmt.Sprintf("Unable to renew deployment lock on service '%s'", key), logging.Err(err))
+			}
+		}
+	}
+}
+
+// lose records err and closes the lost channel
+func (l *Locker) lose(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.err = err
+	close(l.lost)
+}
+
+// Lost returns a channel that is closed when a held lock is lost. It must be called after Lock succeeds
+func (l *Locker) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns an error wrapping ErrLeaseLost if a held lock was lost since Lock was called, or nil
+func (l *Locker) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
+
+// Unlock stops renewing leases and releases every held lock
+// Locks that cannot be released expire once their lease has passed
+func (l *Locker) Unlock() {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+		l.stop = nil
+	}
+
+	// Release even if the deploy context was cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, key := range l.held {
+		if err := l.Backend.Release(ctx, key, l.Owner); err != nil {
+			l.logger.Warn(fmt.Sprintf("Unable to release deployment lock on service '%s'. It expires after %s", key, l.TTL), logging.Err(err))
+			continue
+		}
+
+		l.logger.Info(fmt.Sprintf("Released deployment lock on service '%s'", key))
+	}
+
+	l.held = nil
+}
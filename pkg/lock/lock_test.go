@@ -0,0 +1,159 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"gotest.tools/assert"
+)
+
+var testKey = Key{Cluster: "test-cluster", Service: "test-service"}
+
+func TestBackends(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend func() Backend
+	}{
+		{
+			name: "dynamodb",
+			backend: func() Backend {
+				return DynamoDB{Client: MockDynamoDBClient{Items: map[string]map[string]ddbtypes.AttributeValue{}}, Table: "locks"}
+			},
+		},
+		{
+			name: "ecs-tags",
+			backend: func() Backend {
+				tags := map[string]string{}
+				return ECSTags{Client: deploy.MockECSClient{TestingT: t, ResourceTags: &tags}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			b := tt.backend()
+
+			assert.NilError(t, b.Acquire(ctx, testKey, "build-1", time.Minute))
+
+			// The owner can renew its lease
+			assert.NilError(t, b.Acquire(ctx, testKey, "build-1", time.Minute))
+
+			err := b.Acquire(ctx, testKey, "build-2", time.Minute)
+
+			assert.Assert(t, errors.Is(err, ErrLocked), "got error %v", err)
+
+			var held *HeldError
+
+			assert.Assert(t, errors.As(err, &held))
+			assert.Equal(t, "build-1", held.Owner)
+
+			// Only the owner can release a lock
+			assert.NilError(t, b.Release(ctx, testKey, "build-2"))
+			assert.Assert(t, errors.Is(b.Acquire(ctx, testKey, "build-2", time.Minute), ErrLocked))
+
+			assert.NilError(t, b.Release(ctx, testKey, "build-1"))
+			assert.NilError(t, b.Acquire(ctx, testKey, "build-2", time.Minute))
+		})
+	}
+}
+
+func TestDynamoDBExpiredLock(t *testing.T) {
+	items := map[string]map[string]ddbtypes.AttributeValue{
+		testKey.String(): {
+			dynamoDBKeyAttribute:     &ddbtypes.AttributeValueMemberS{Value: testKey.String()},
+			dynamoDBOwnerAttribute:   &ddbtypes.AttributeValueMemberS{Value: "crashed-build"},
+			dynamoDBExpiresAttribute: &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)},
+		},
+	}
+
+	b := DynamoDB{Client: MockDynamoDBClient{Items: items}, Table: "locks"}
+
+	assert.NilError(t, b.Acquire(context.Background(), testKey, "build-1", time.Minute))
+}
+
+func TestLocker(t *testing.T) {
+	items := map[string]map[string]ddbtypes.AttributeValue{}
+	b := DynamoDB{Client: MockDynamoDBClient{Items: items}, Table: "locks"}
+
+	first := Locker{Backend: b, Owner: "build-1", TTL: time.Minute, RetryInterval: time.Millisecond}
+
+	assert.NilError(t, first.Lock(context.Background(), []Key{testKey}))
+
+	second := Locker{Backend: b, Owner: "build-2", TTL: time.Minute, Wait: 20 * time.Millisecond, RetryInterval: 5 * time.Millisecond}
+
+	err := second.Lock(context.Background(), []Key{{Cluster: "test-cluster", Service: "other-service"}, testKey})
+
+	assert.Assert(t, errors.Is(err, ErrLocked), "got error %v", err)
+
+	// The lock on the other service is released when the second lock cannot be acquired
+	_, ok := items["test-cluster/other-service"]
+	assert.Assert(t, !ok)
+
+	first.Unlock()
+
+	assert.Equal(t, 0, len(items))
+	assert.NilError(t, second.Lock(context.Background(), []Key{testKey}))
+
+	second.Unlock()
+}
+
+// failingRenewalBackend acquires a lock once and then fails every renewal with err
+type failingRenewalBackend struct {
+	DynamoDB
+	err      error
+	acquired *bool
+}
+
+func (b failingRenewalBackend) Acquire(ctx context.Context, key Key, owner string, ttl time.Duration) error {
+	if *b.acquired {
+		return b.err
+	}
+
+	*b.acquired = true
+
+	return b.DynamoDB.Acquire(ctx, key, owner, ttl)
+}
+
+func TestLockerLostLease(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{
+			name: "held-by-another-owner",
+			err:  &HeldError{Key: testKey, Owner: "build-2", Expires: time.Now().Add(time.Minute)},
+		},
+		{
+			name: "renewal-errors-until-expiry",
+			err:  errors.New("throttled"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := failingRenewalBackend{
+				DynamoDB: DynamoDB{Client: MockDynamoDBClient{Items: map[string]map[string]ddbtypes.AttributeValue{}}, Table: "locks"},
+				err:      tt.err,
+				acquired: new(bool),
+			}
+			l := Locker{Backend: b, Owner: "build-1", TTL: 30 * time.Millisecond}
+
+			assert.NilError(t, l.Lock(context.Background(), []Key{testKey}))
+
+			select {
+			case <-l.Lost():
+			case <-time.After(time.Second):
+				t.Fatal("the lost lease was not reported")
+			}
+
+			assert.Assert(t, errors.Is(l.Err(), ErrLeaseLost), "got error %v", l.Err())
+
+			l.Unlock()
+		})
+	}
+}
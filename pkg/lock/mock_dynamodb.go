@@ -0,0 +1,59 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MockDynamoDBClient keeps lock items in memory and enforces the same conditions as the lock expressions
+type MockDynamoDBClient struct {
+	WantError bool
+	// Items holds every item by LockID
+	Items map[string]map[string]ddbtypes.AttributeValue
+}
+
+func (c MockDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	key := params.Item[dynamoDBKeyAttribute].(*ddbtypes.AttributeValueMemberS).Value
+	owner := params.ExpressionAttributeValues[":owner"].(*ddbtypes.AttributeValueMemberS).Value
+
+	if existing, ok := c.Items[key]; ok {
+		existingOwner := existing[dynamoDBOwnerAttribute].(*ddbtypes.AttributeValueMemberS).Value
+		expires, _ := strconv.ParseInt(existing[dynamoDBExpiresAttribute].(*ddbtypes.AttributeValueMemberN).Value, 10, 64)
+
+		if existingOwner != owner && expires >= time.Now().Unix() {
+			return nil, &ddbtypes.ConditionalCheckFailedException{Message: new(string), Item: existing}
+		}
+	}
+
+	c.Items[key] = params.Item
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c MockDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	key := params.Key[dynamoDBKeyAttribute].(*ddbtypes.AttributeValueMemberS).Value
+	owner := params.ExpressionAttributeValues[":owner"].(*ddbtypes.AttributeValueMemberS).Value
+
+	existing, ok := c.Items[key]
+
+	if !ok || existing[dynamoDBOwnerAttribute].(*ddbtypes.AttributeValueMemberS).Value != owner {
+		return nil, &ddbtypes.ConditionalCheckFailedException{Message: new(string)}
+	}
+
+	delete(c.Items, key)
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// AppendMiddlewares adds a middleware to AWS SDK clients that logs every API call at debug level with its request ID
+// Pass the APIOptions of an aws.Config. Calls are logged to the logger of their context
+func AppendMiddlewares(apiOptions *[]func(*middleware.Stack) error) {
+	*apiOptions = append(*apiOptions, func(stack *middleware.Stack) error {
+		// After, so the service and operation names have been set by the client
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("DeployLogging", logCall), middleware.After)
+	})
+}
+
+func logCall(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	start := time.Now()
+
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	fields := []Field{
+		F("aws_service", awsmiddleware.GetServiceID(ctx)),
+		F("aws_operation", awsmiddleware.GetOperationName(ctx)),
+		F("duration_ms", time.Since(start).Milliseconds()),
+	}
+
+	if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+		fields = append(fields, F("aws_request_id", requestID))
+	}
+
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		fields = append(fields, F("http_status", resp.StatusCode))
+	}
+
+	if err != nil {
+		fields = append(fields, Err(err))
+	}
+
+	From(ctx).Debug("AWS API call", fields...)
+
+	return out, metadata, err
+}
@@ -0,0 +1,282 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Field is a key value added to a log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Cluster(cluster string) Field {
+	return Field{Key: "cluster", Value: cluster}
+}
+
+func Service(service string) Field {
+	return Field{Key: "service", Value: service}
+}
+
+func DeploymentID(id string) Field {
+	return Field{Key: "deployment_id", Value: id}
+}
+
+func Phase(phase string) Field {
+	return Field{Key: "phase", Value: phase}
+}
+
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// Logger writes levelled log entries with fields. Implement it in order to send the logs of a deploy elsewhere
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// With returns a logger that adds fields to every entry
+	With(fields ...Field) Logger
+}
+
+const (
+	formatText = iota
+	formatJSON
+)
+
+// writer is shared by a logger and every logger derived from it with With
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type logger struct {
+	out    *writer
+	level  Level
+	format int
+	fields []Field
+}
+
+// NewTextLogger writes entries at level or above to w as lines such as
+// 2021/01/01 00:00:00 INFO Started deployment service=webapp deployment_id=ecs-svc/123
+func NewTextLogger(w io.Writer, level Level) Logger {
+	return &logger{out: &writer{w: w}, level: level, format: formatText}
+}
+
+// NewJSONLogger writes entries at level or above to w as one JSON object per line with time, level, msg and every field
+func NewJSONLogger(w io.Writer, level Level) Logger {
+	return &logger{out: &writer{w: w}, level: level, format: formatJSON}
+}
+
+func (l *logger) Debug(msg string, fields ...Field) { l.log(LevelDebug, msg, fields) }
+func (l *logger) Info(msg string, fields ...Field)  { l.log(LevelInfo, msg, fields) }
+func (l *logger) Warn(msg string, fields ...Field)  { l.log(LevelWarn, msg, fields) }
+func (l *logger) Error(msg string, fields ...Field) { l.log(LevelError, msg, fields) }
+
+func (l *logger) With(fields ...Field) Logger {
+	return &logger{out: l.out, level: l.level, format: l.format, fields: merge(l.fields, fields)}
+}
+
+// merge returns the fields of base followed by fields. A field replaces the value of an earlier field with the same key
+func merge(base []Field, fields []Field) []Field {
+	merged := make([]Field, len(base), len(base)+len(fields))
+	copy(merged, base)
+
+	for _, f := range fields {
+		replaced := false
+
+		for i := range merged {
+			if merged[i].Key == f.Key {
+				merged[i].Value = f.Value
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			merged = append(merged, f)
+		}
+	}
+
+	return merged
+}
+
+func (l *logger) log(level Level, msg string, fields []Field) {
+	if level < l.level {
+		return
+	}
+
+	fields = merge(l.fields, fields)
+	now := time.Now()
+
+	var line []byte
+
+	if l.format == formatJSON {
+		line = jsonLine(now, level, msg, fields)
+	} else {
+		line = textLine(now, level, msg, fields)
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+
+	l.out.w.Write(line)
+}
+
+func textLine(now time.Time, level Level, msg string, fields []Field) []byte {
+	var b strings.Builder
+
+	b.WriteString(now.Format("2006/01/02 15:04:05 "))
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for _, f := range fields {
+		if f.Value == nil {
+			continue
+		}
+
+		b.WriteString(" ")
+		b.WriteString(f.Key)
+		b.WriteString("=")
+		b.WriteString(textValue(f.Value))
+	}
+
+	b.WriteString("\n")
+
+	return []byte(b.String())
+}
+
+// textValue quotes values that are empty or contain spaces, quotes or an equals sign
+func textValue(v interface{}) string {
+	s := fmt.Sprint(v)
+
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func jsonLine(now time.Time, level Level, msg string, fields []Field) []byte {
+	var b strings.Builder
+
+	b.WriteString(`{"time":`)
+	writeJSON(&b, now.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+
+	for _, f := range fields {
+		if f.Value == nil || f.Key == "time" || f.Key == "level" || f.Key == "msg" {
+			continue
+		}
+
+		b.WriteString(",")
+		writeJSON(&b, f.Key)
+		b.WriteString(":")
+		writeJSON(&b, f.Value)
+	}
+
+	b.WriteString("}\n")
+
+	return []byte(b.String())
+}
+
+func writeJSON(b *strings.Builder, v interface{}) {
+	out, err := json.Marshal(v)
+
+	if err != nil {
+		out, _ = json.Marshal(fmt.Sprint(v))
+	}
+
+	b.Write(out)
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = NewTextLogger(os.Stderr, LevelInfo)
+)
+
+// Default returns the logger used when a context has no logger. It writes text at info level to stderr unless it is replaced with SetDefault
+func Default() Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultLogger
+}
+
+// SetDefault replaces the logger used when a context has no logger
+func SetDefault(l Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultLogger = l
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context whose functions log to l
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// With returns a context whose logger adds fields to every entry
+func With(ctx context.Context, fields ...Field) context.Context {
+	return WithLogger(ctx, From(ctx).With(fields...))
+}
+
+// From returns the logger of ctx, or the default logger
+func From(ctx context.Context) Logger {
+	if l, ok := Lookup(ctx); ok {
+		return l
+	}
+
+	return Default()
+}
+
+// Lookup returns the logger of ctx and whether it has one
+func Lookup(ctx context.Context) (Logger, bool) {
+	l, ok := ctx.Value(loggerKey{}).(Logger)
+	return l, ok
+}
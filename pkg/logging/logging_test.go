@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"gotest.tools/assert"
+)
+
+// trimTime removes the timestamp of every text line
+func trimTime(s string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+
+	for i, line := range lines {
+		lines[i] = line[len("2006/01/02 15:04:05 "):]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func TestTextLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    Level
+		log      func(l Logger)
+		expected string
+	}{
+		{
+			name:  "fields",
+			level: LevelInfo,
+			log: func(l Logger) {
+				l.Info("Started deployment", Service("webapp"), DeploymentID("ecs-svc/123"))
+			},
+			expected: "INFO Started deployment service=webapp deployment_id=ecs-svc/123",
+		},
+		{
+			name:  "quoted values",
+			level: LevelInfo,
+			log: func(l Logger) {
+				l.Error("Deployment failed", Err(errors.New("service not found")), F("empty", ""))
+			},
+			expected: `ERROR Deployment failed error="service not found" empty=""`,
+		},
+		{
+			name:  "nil error",
+			level: LevelInfo,
+			log: func(l Logger) {
+				l.Warn("Deployment finished", Err(nil))
+			},
+			expected: "WARN Deployment finished",
+		},
+		{
+			name:  "level filter",
+			level: LevelWarn,
+			log: func(l Logger) {
+				l.Debug("AWS API call")
+				l.Info("Started deployment")
+				l.Warn("Rollbacks are disabled")
+			},
+			expected: "WARN Rollbacks are disabled",
+		},
+		{
+			name:  "with",
+			level: LevelDebug,
+			log: func(l Logger) {
+				l = l.With(Cluster("prod-ecs-cluster"), Service("webapp"))
+				l.Debug("Checking", Phase("deployment to complete"))
+				l.Info("Override", Service("worker"))
+			},
+			expected: "DEBUG Checking cluster=prod-ecs-cluster service=webapp phase=\"deployment to complete\"\nINFO Override cluster=prod-ecs-cluster service=worker",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			tc.log(NewTextLogger(&out, tc.level))
+
+			assert.Equal(t, tc.expected, trimTime(out.String()))
+		})
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+
+	l := NewJSONLogger(&out, LevelInfo).With(Cluster("prod-ecs-cluster"), F("level", "ignored"))
+	l.Debug("Not logged")
+	l.Info("Started deployment", Service("webapp"), F("checks", 3))
+
+	var entry map[string]interface{}
+	assert.NilError(t, json.Unmarshal(out.Bytes(), &entry))
+
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "Started deployment", entry["msg"])
+	assert.Equal(t, "prod-ecs-cluster", entry["cluster"])
+	assert.Equal(t, "webapp", entry["service"])
+	assert.Equal(t, float64(3), entry["checks"])
+	assert.Assert(t, entry["time"] != nil)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := Lookup(context.TODO())
+	assert.Assert(t, !ok)
+	assert.Equal(t, Default(), From(context.TODO()))
+
+	var out bytes.Buffer
+
+	ctx := WithLogger(context.TODO(), NewTextLogger(&out, LevelInfo))
+	ctx = With(ctx, Service("webapp"))
+
+	_, ok = Lookup(ctx)
+	assert.Assert(t, ok)
+
+	From(ctx).Info("Started deployment")
+	assert.Equal(t, "INFO Started deployment service=webapp", trimTime(out.String()))
+}
+
+func TestAppendMiddlewares(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RequestId", "request-1")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"services": []}`))
+	}))
+	defer server.Close()
+
+	options := ecs.Options{
+		Region:           "us-east-2",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: ecs.EndpointResolverFromURL(server.URL),
+		HTTPClient:       server.Client(),
+	}
+
+	AppendMiddlewares(&options.APIOptions)
+
+	client := ecs.New(options)
+
+	// Calls are only logged at debug level
+	var info bytes.Buffer
+
+	_, err := client.DescribeServices(WithLogger(context.TODO(), NewTextLogger(&info, LevelInfo)), &ecs.DescribeServicesInput{Services: []string{"webapp"}})
+	assert.NilError(t, err)
+	assert.Equal(t, "", info.String())
+
+	var out bytes.Buffer
+
+	_, err = client.DescribeServices(WithLogger(context.TODO(), NewJSONLogger(&out, LevelDebug)), &ecs.DescribeServicesInput{Services: []string{"webapp"}})
+	assert.NilError(t, err)
+
+	var entry map[string]interface{}
+	assert.NilError(t, json.Unmarshal(out.Bytes(), &entry))
+
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, "AWS API call", entry["msg"])
+	assert.Equal(t, "ECS", entry["aws_service"])
+	assert.Equal(t, "DescribeServices", entry["aws_operation"])
+	assert.Equal(t, "request-1", entry["aws_request_id"])
+	assert.Equal(t, float64(200), entry["http_status"])
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// putMetricDataLimit is the most metrics PutMetricData accepts in one call
+const putMetricDataLimit = 20
+
+// CloudWatch puts metrics in Namespace with a dimension for every label, such as Cluster, Service and Mode
+type CloudWatch struct {
+	Client    types.CloudWatchClient
+	Namespace string
+}
+
+func (c CloudWatch) Send(ctx context.Context, metrics []Metric) error {
+	now := time.Now()
+
+	var data []cwtypes.MetricDatum
+
+	for _, m := range metrics {
+		datum := cwtypes.MetricDatum{
+			MetricName: aws.String(m.Name),
+			Value:      aws.Float64(m.Value),
+			Timestamp:  aws.Time(now),
+			Unit:       cwtypes.StandardUnitCount,
+		}
+
+		if m.Unit == UnitSeconds {
+			datum.Unit = cwtypes.StandardUnitSeconds
+		}
+
+		for _, k := range sortedKeys(m.Labels) {
+			datum.Dimensions = append(datum.Dimensions, cwtypes.Dimension{
+				Name:  aws.String(strings.Title(k)),
+				Value: aws.String(m.Labels[k]),
+			})
+		}
+
+		data = append(data, datum)
+	}
+
+	for i := 0; i < len(data); i += putMetricDataLimit {
+		end := i + putMetricDataLimit
+
+		if end > len(data) {
+			end = len(data)
+		}
+
+		_, err := c.Client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(c.Namespace),
+			MetricData: data[i:end],
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+)
+
+type Unit string
+
+const (
+	UnitSeconds Unit = "Seconds"
+	UnitCount   Unit = "Count"
+)
+
+// Metric is one value of a deploy
+type Metric struct {
+	Name  string
+	Value float64
+	Unit  Unit
+	// Labels always include cluster, service and mode
+	Labels map[string]string
+}
+
+// Sink sends metrics to a metrics backend
+type Sink interface {
+	Send(ctx context.Context, metrics []Metric) error
+}
+
+// Emit sends metrics to every sink. Errors are logged but never fail a deploy
+func Emit(ctx context.Context, sinks []Sink, metrics []Metric) {
+	for _, sink := range sinks {
+		if err := sink.Send(ctx, metrics); err != nil {
+			logging.From(ctx).Warn(fmt.Sprintf("Error sending metrics to %T", sink), logging.Err(err))
+		}
+	}
+}
+
+// FromResult returns the metrics of a finished deploy for every service it deployed
+func FromResult(r *deploy.Result) []Metric {
+	var metrics []Metric
+
+	if r == nil {
+		return metrics
+	}
+
+	success := 0.0
+
+	if r.Outcome == deploy.OutcomeSucceeded {
+		success = 1
+	}
+
+	checks := 0
+
+	for _, p := range r.Phases {
+		checks += p.Checks
+	}
+
+	for _, service := range r.Services {
+		labels := map[string]string{"cluster": r.Cluster, "service": service, "mode": r.Mode}
+
+		metrics = append(metrics,
+			Metric{Name: "ecs_deploy_duration_seconds", Value: r.FinishedAt.Sub(r.StartedAt).Seconds(), Unit: UnitSeconds, Labels: labels},
+			Metric{Name: "ecs_deploy_success", Value: success, Unit: UnitCount, Labels: labels},
+			Metric{Name: "ecs_deploy_checks", Value: float64(checks), Unit: UnitCount, Labels: labels},
+			Metric{Name: "ecs_deploy_rollbacks", Value: float64(r.Rollbacks), Unit: UnitCount, Labels: labels},
+		)
+
+		if seconds, ok := r.FirstHealthyTaskSeconds[service]; ok {
+			metrics = append(metrics, Metric{Name: "ecs_deploy_first_healthy_task_seconds", Value: seconds, Unit: UnitSeconds, Labels: labels})
+		}
+
+		if service != r.BlueService {
+			continue
+		}
+
+		step := 0
+
+		for _, p := range r.Phases {
+			if p.Name != deploy.PhaseBlueScaleDown {
+				continue
+			}
+
+			step++
+			stepLabels := map[string]string{"step": fmt.Sprint(step)}
+
+			for k, v := range labels {
+				stepLabels[k] = v
+			}
+
+			metrics = append(metrics, Metric{Name: "ecs_deploy_scale_down_step_seconds", Value: p.DurationSeconds, Unit: UnitSeconds, Labels: stepLabels})
+		}
+	}
+
+	return metrics
+}
+
+// sortedKeys returns the label names of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
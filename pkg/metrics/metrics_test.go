@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/deploy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"gotest.tools/assert"
+)
+
+func testResult() *deploy.Result {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return &deploy.Result{
+		Mode:        "blue-green",
+		Cluster:     "prod-ecs-cluster",
+		Services:    []string{"webapp-blue", "webapp-green"},
+		BlueService: "webapp-blue",
+		StartedAt:   start,
+		FinishedAt:  start.Add(90 * time.Second),
+		Phases: []deploy.PhaseResult{
+			{Name: "deployment", Checks: 4},
+			{Name: deploy.PhaseBlueScaleDown, DurationSeconds: 10, Checks: 2},
+			{Name: deploy.PhaseBlueScaleDown, DurationSeconds: 12, Checks: 3},
+		},
+		Outcome:                 deploy.OutcomeSucceeded,
+		Rollbacks:               1,
+		FirstHealthyTaskSeconds: map[string]float64{"webapp-green": 30},
+	}
+}
+
+func find(metrics []Metric, name string, service string) []Metric {
+	var found []Metric
+
+	for _, m := range metrics {
+		if m.Name == name && m.Labels["service"] == service {
+			found = append(found, m)
+		}
+	}
+
+	return found
+}
+
+func TestFromResult(t *testing.T) {
+	metrics := FromResult(testResult())
+
+	tests := []struct {
+		name    string
+		service string
+		want    []float64
+	}{
+		{"ecs_deploy_duration_seconds", "webapp-green", []float64{90}},
+		{"ecs_deploy_success", "webapp-green", []float64{1}},
+		{"ecs_deploy_checks", "webapp-blue", []float64{9}},
+		{"ecs_deploy_rollbacks", "webapp-blue", []float64{1}},
+		{"ecs_deploy_first_healthy_task_seconds", "webapp-green", []float64{30}},
+		{"ecs_deploy_first_healthy_task_seconds", "webapp-blue", nil},
+		{"ecs_deploy_scale_down_step_seconds", "webapp-blue", []float64{10, 12}},
+		{"ecs_deploy_scale_down_step_seconds", "webapp-green", nil},
+	}
+
+	for _, tc := range tests {
+		var got []float64
+
+		for _, m := range find(metrics, tc.name, tc.service) {
+			got = append(got, m.Value)
+			assert.Equal(t, "prod-ecs-cluster", m.Labels["cluster"])
+			assert.Equal(t, "blue-green", m.Labels["mode"])
+		}
+
+		assert.DeepEqual(t, tc.want, got)
+	}
+
+	steps := find(metrics, "ecs_deploy_scale_down_step_seconds", "webapp-blue")
+	assert.Equal(t, "1", steps[0].Labels["step"])
+	assert.Equal(t, "2", steps[1].Labels["step"])
+
+	assert.Equal(t, 0, len(FromResult(nil)))
+}
+
+func TestPushgateway(t *testing.T) {
+	bodies := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(body)
+	}))
+	defer server.Close()
+
+	p := Pushgateway{URL: server.URL + "/", Job: "drone_deploy_ecs", Client: server.Client()}
+
+	err := p.Send(context.TODO(), FromResult(testResult()))
+	assert.NilError(t, err)
+
+	assert.Equal(t, 2, len(bodies))
+
+	blue := bodies["/metrics/job/drone_deploy_ecs/cluster/prod-ecs-cluster/service/webapp-blue/mode/blue-green"]
+	assert.Assert(t, strings.Contains(blue, "# TYPE ecs_deploy_success gauge\necs_deploy_success 1\n"))
+	assert.Assert(t, strings.Contains(blue, "# TYPE ecs_deploy_scale_down_step_seconds gauge\necs_deploy_scale_down_step_seconds{step=\"1\"} 10\necs_deploy_scale_down_step_seconds{step=\"2\"} 12\n"))
+
+	green := bodies["/metrics/job/drone_deploy_ecs/cluster/prod-ecs-cluster/service/webapp-green/mode/blue-green"]
+	assert.Assert(t, strings.Contains(green, "ecs_deploy_first_healthy_task_seconds 30\n"))
+}
+
+func TestPushgatewayError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := Pushgateway{URL: server.URL, Job: "drone_deploy_ecs", Client: server.Client()}
+
+	err := p.Send(context.TODO(), FromResult(testResult()))
+	assert.ErrorContains(t, err, "unexpected status 400")
+}
+
+func TestStatsD(t *testing.T) {
+	tests := []struct {
+		dogStatsD bool
+		want      []string
+	}{
+		{
+			dogStatsD: false,
+			want: []string{
+				"ecs_deploy_duration_seconds.prod-ecs-cluster.webapp.rolling:90000|ms",
+				"ecs_deploy_success.prod-ecs-cluster.webapp.rolling:0|g",
+			},
+		},
+		{
+			dogStatsD: true,
+			want: []string{
+				"ecs_deploy_duration_seconds:90000|ms|#cluster:prod-ecs-cluster,mode:rolling,service:webapp",
+				"ecs_deploy_success:0|g|#cluster:prod-ecs-cluster,mode:rolling,service:webapp",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NilError(t, err)
+
+		r := testResult()
+		r.Mode = "rolling"
+		r.Services = []string{"webapp"}
+		r.Outcome = deploy.OutcomeFailed
+
+		s := StatsD{Address: conn.LocalAddr().String(), DogStatsD: tc.dogStatsD}
+		err = s.Send(context.TODO(), FromResult(r))
+		assert.NilError(t, err)
+
+		buf := make([]byte, maxPacketSize)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.NilError(t, err)
+		conn.Close()
+
+		lines := strings.Split(string(buf[:n]), "\n")
+		assert.DeepEqual(t, tc.want, lines[:2])
+	}
+}
+
+func TestCloudWatch(t *testing.T) {
+	var inputs []*cloudwatch.PutMetricDataInput
+
+	var metrics []Metric
+
+	for i := 0; i < 25; i++ {
+		metrics = append(metrics, Metric{Name: "ecs_deploy_checks", Value: float64(i), Unit: UnitCount, Labels: map[string]string{"cluster": "prod-ecs-cluster", "service": "webapp", "mode": "rolling"}})
+	}
+
+	c := CloudWatch{Client: deploy.MockCloudWatchClient{MetricData: &inputs}, Namespace: "Deploys"}
+
+	err := c.Send(context.TODO(), metrics)
+	assert.NilError(t, err)
+
+	assert.Equal(t, 2, len(inputs))
+	assert.Equal(t, 20, len(inputs[0].MetricData))
+	assert.Equal(t, 5, len(inputs[1].MetricData))
+	assert.Equal(t, "Deploys", aws.ToString(inputs[0].Namespace))
+
+	dimensions := inputs[0].MetricData[0].Dimensions
+	assert.Equal(t, "Cluster", aws.ToString(dimensions[0].Name))
+	assert.Equal(t, "Mode", aws.ToString(dimensions[1].Name))
+	assert.Equal(t, "Service", aws.ToString(dimensions[2].Name))
+
+	c.Client = deploy.MockCloudWatchClient{WantError: true}
+	assert.Assert(t, c.Send(context.TODO(), metrics) != nil)
+}
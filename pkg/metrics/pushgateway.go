@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// groupingLabels are sent in the Pushgateway URL, so every service of a deploy gets its own group
+var groupingLabels = []string{"cluster", "service", "mode"}
+
+// Pushgateway pushes metrics to a Prometheus Pushgateway as gauges
+type Pushgateway struct {
+	URL    string
+	Job    string
+	Client *http.Client
+}
+
+func (p Pushgateway) Send(ctx context.Context, metrics []Metric) error {
+	type group struct {
+		path string
+		body bytes.Buffer
+		seen map[string]bool
+	}
+
+	var groups []*group
+	byPath := map[string]*group{}
+
+	for _, m := range metrics {
+		path := "/metrics/job/" + url.PathEscape(p.Job)
+
+		for _, l := range groupingLabels {
+			path += "/" + l + "/" + url.PathEscape(m.Labels[l])
+		}
+
+		g, ok := byPath[path]
+
+		if !ok {
+			g = &group{path: path, seen: map[string]bool{}}
+			byPath[path] = g
+			groups = append(groups, g)
+		}
+
+		if !g.seen[m.Name] {
+			g.seen[m.Name] = true
+			fmt.Fprintf(&g.body, "# TYPE %s gauge\n", m.Name)
+		}
+
+		var labels []string
+
+		for _, k := range sortedKeys(m.Labels) {
+			if k != "cluster" && k != "service" && k != "mode" {
+				labels = append(labels, fmt.Sprintf("%s=%q", k, m.Labels[k]))
+			}
+		}
+
+		if len(labels) > 0 {
+			fmt.Fprintf(&g.body, "%s{%s} %g\n", m.Name, strings.Join(labels, ","), m.Value)
+		} else {
+			fmt.Fprintf(&g.body, "%s %g\n", m.Name, m.Value)
+		}
+	}
+
+	for _, g := range groups {
+		if err := p.push(ctx, g.path, g.body.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// push replaces the metrics of a group. PUT is used so metrics of an earlier deploy, such as a scale down step, don't linger
+func (p Pushgateway) push(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimSuffix(p.URL, "/")+path, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := p.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d from Pushgateway: %s", resp.StatusCode, string(b))
+	}
+
+	return nil
+}
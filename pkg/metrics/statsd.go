@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// maxPacketSize keeps StatsD packets under the usual Ethernet MTU
+const maxPacketSize = 1432
+
+// StatsD sends metrics over UDP. Durations are sent as timings in milliseconds and everything else as gauges
+// DogStatsD sends labels as tags. Otherwise the label values are appended to the metric name, such as ecs_deploy_success.prod.webapp.rolling
+type StatsD struct {
+	Address   string
+	DogStatsD bool
+}
+
+func (s StatsD) Send(ctx context.Context, metrics []Metric) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "udp", s.Address)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	var packet strings.Builder
+
+	for _, m := range metrics {
+		line := s.line(m)
+
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > maxPacketSize {
+			if _, err := conn.Write([]byte(packet.String())); err != nil {
+				return err
+			}
+
+			packet.Reset()
+		}
+
+		if packet.Len() > 0 {
+			packet.WriteString("\n")
+		}
+
+		packet.WriteString(line)
+	}
+
+	if packet.Len() > 0 {
+		if _, err := conn.Write([]byte(packet.String())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statsdReplacer removes the characters that separate the parts of a StatsD line or name
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", ".", "_", " ", "_")
+
+func (s StatsD) line(m Metric) string {
+	value := fmt.Sprintf("%g|g", m.Value)
+
+	if m.Unit == UnitSeconds {
+		value = fmt.Sprintf("%g|ms", m.Value*1000)
+	}
+
+	name := m.Name
+
+	if s.DogStatsD {
+		var tags []string
+
+		for _, k := range sortedKeys(m.Labels) {
+			tags = append(tags, k+":"+statsdReplacer.Replace(m.Labels[k]))
+		}
+
+		return fmt.Sprintf("%s:%s|#%s", name, value, strings.Join(tags, ","))
+	}
+
+	for _, k := range []string{"cluster", "service", "mode", "step"} {
+		if v, ok := m.Labels[k]; ok {
+			name += "." + statsdReplacer.Replace(v)
+		}
+	}
+
+	return fmt.Sprintf("%s:%s", name, value)
+}
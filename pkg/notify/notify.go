@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/assemblyai/drone-deploy-ecs/pkg/logging"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventStarted          EventType = "deploy_started"
+	EventSucceeded        EventType = "deploy_succeeded"
+	EventFailed           EventType = "deploy_failed"
+	EventRollbackStarted  EventType = "rollback_started"
+	EventRollbackFinished EventType = "rollback_finished"
+)
+
+// Event is a point in the lifecycle of a deploy. It is sent as is to webhooks
+type Event struct {
+	Type     EventType `json:"event"`
+	Mode     string    `json:"mode"`
+	Cluster  string    `json:"cluster"`
+	Services []string  `json:"services"`
+	// Images are the images of every container the deploy changes
+	Images    []ImageChange `json:"images,omitempty"`
+	BuildLink string        `json:"build_link,omitempty"`
+	// FailureCategory is the kind of failure of a deployment that was classified, such as placement
+	FailureCategory string `json:"failure_category,omitempty"`
+	Error           string `json:"error,omitempty"`
+	// Rollback is whether a finished rollback succeeded or failed
+	Rollback string    `json:"rollback,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type ImageChange struct {
+	Container string `json:"container"`
+	Previous  string `json:"previous,omitempty"`
+	Image     string `json:"image"`
+}
+
+// Target sends events to one destination
+type Target interface {
+	Send(ctx context.Context, e Event) error
+}
+
+// StatusError is returned when a target responds with a status other than 2xx
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable returns false for errors that sending again will not fix, such as a wrong URL
+func retryable(err error) bool {
+	var status *StatusError
+
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+// post sends body to url as JSON
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+
+	return nil
+}
+
+// Notifier sends every event to every target
+// Sending never fails a deploy. Errors are retried and then logged. A nil Notifier sends nothing
+type Notifier struct {
+	Targets []Target
+	// Retries is how many times a failed send is retried. Each retry waits twice as long as the one before, starting at RetryInterval
+	Retries       int
+	RetryInterval time.Duration
+	// Timeout limits every send. Zero means no limit. Events are sent even after the deploy deadline has passed
+	Timeout time.Duration
+}
+
+// NewNotifier returns a Notifier that retries every send 3 times, starting after a second
+func NewNotifier(targets ...Target) *Notifier {
+	return &Notifier{
+		Targets:       targets,
+		Retries:       3,
+		RetryInterval: time.Second,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// Notify sends e to every target
+func (n *Notifier) Notify(e Event) {
+	if n == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	for _, t := range n.Targets {
+		if err := n.send(t, e); err != nil {
+			logging.Default().Warn(fmt.Sprintf("Error sending %s notification", e.Type), logging.Err(err))
+		}
+	}
+}
+
+func (n *Notifier) send(t Target, e Event) error {
+	wait := n.RetryInterval
+
+	for attempt := 0; ; attempt++ {
+		var ctx context.Context
+		var cancel context.CancelFunc
+
+		if n.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), n.Timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		err := t.Send(ctx, e)
+		cancel()
+
+		if err == nil || attempt >= n.Retries || !retryable(err) {
+			return err
+		}
+
+		logging.Default().Info(fmt.Sprintf("Retrying %s notification in %s", e.Type, wait), logging.Err(err))
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func testEvent() Event {
+	return Event{
+		Type:     EventFailed,
+		Mode:     "rolling",
+		Cluster:  "prod-ecs-cluster",
+		Services: []string{"webapp"},
+		Images: []ImageChange{
+			{Container: "nginx", Previous: "myorg/nginx:1", Image: "myorg/nginx:2"},
+		},
+		BuildLink:       "https://drone.example.com/myorg/webapp/42",
+		FailureCategory: "placement",
+		Rollback:        "succeeded",
+	}
+}
+
+// newTestServer responds with each status in turn and records every request body
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *[][]byte) {
+	var bodies [][]byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, body)
+
+		status := http.StatusOK
+
+		if len(bodies) <= len(statuses) {
+			status = statuses[len(bodies)-1]
+		}
+
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, &bodies
+}
+
+func TestWebhook(t *testing.T) {
+	server, bodies := newTestServer(t)
+
+	n := &Notifier{Targets: []Target{Webhook{URL: server.URL, Client: server.Client()}}}
+	n.Notify(testEvent())
+
+	assert.Equal(t, 1, len(*bodies))
+
+	var got Event
+	assert.NilError(t, json.Unmarshal((*bodies)[0], &got))
+
+	assert.Equal(t, EventFailed, got.Type)
+	assert.DeepEqual(t, []string{"webapp"}, got.Services)
+	assert.Equal(t, "myorg/nginx:2", got.Images[0].Image)
+	assert.Equal(t, "placement", got.FailureCategory)
+	assert.Assert(t, !got.Time.IsZero())
+}
+
+func TestSlack(t *testing.T) {
+	server, bodies := newTestServer(t)
+
+	n := &Notifier{Targets: []Target{Slack{URL: server.URL, Client: server.Client()}}}
+	n.Notify(testEvent())
+
+	assert.Equal(t, 1, len(*bodies))
+
+	var got map[string]string
+	assert.NilError(t, json.Unmarshal((*bodies)[0], &got))
+
+	want := "*:x: Deploy failed* for `webapp` in cluster `prod-ecs-cluster` (rolling)" +
+		"\n• nginx: `myorg/nginx:1` → `myorg/nginx:2`" +
+		"\n• Failure: placement" +
+		"\n• Rollback succeeded" +
+		"\n<https://drone.example.com/myorg/webapp/42|Drone build>"
+
+	assert.Equal(t, want, got["text"])
+}
+
+func TestNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+	}{
+		{
+			name:      "retries-server-errors",
+			statuses:  []int{http.StatusInternalServerError, http.StatusTooManyRequests},
+			wantCalls: 3,
+		},
+		{
+			name:      "gives-up",
+			statuses:  []int{500, 500, 500, 500, 500},
+			wantCalls: 3,
+		},
+		{
+			name:      "does-not-retry-client-errors",
+			statuses:  []int{http.StatusNotFound},
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, bodies := newTestServer(t, tt.statuses...)
+
+			n := &Notifier{
+				Targets:       []Target{Webhook{URL: server.URL, Client: server.Client()}},
+				Retries:       2,
+				RetryInterval: time.Millisecond,
+				Timeout:       time.Second,
+			}
+
+			// Notify never returns an error, however the send ends
+			n.Notify(testEvent())
+
+			assert.Equal(t, tt.wantCalls, len(*bodies))
+		})
+	}
+}
+
+func TestNotifierUnreachable(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.Close()
+
+	n := &Notifier{
+		Targets:       []Target{Webhook{URL: server.URL, Client: &http.Client{Timeout: time.Second}}},
+		Retries:       1,
+		RetryInterval: time.Millisecond,
+	}
+
+	n.Notify(testEvent())
+
+	var nilNotifier *Notifier
+	nilNotifier.Notify(testEvent())
+}
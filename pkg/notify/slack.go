@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Slack posts a formatted message for every event to a Slack incoming webhook
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+var slackTitles = map[EventType]string{
+	EventStarted:          ":rocket: Deploy started",
+	EventSucceeded:        ":white_check_mark: Deploy succeeded",
+	EventFailed:           ":x: Deploy failed",
+	EventRollbackStarted:  ":rewind: Rollback started",
+	EventRollbackFinished: ":leftwards_arrow_with_hook: Rollback finished",
+}
+
+func (s Slack) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(map[string]string{"text": SlackMessage(e)})
+
+	if err != nil {
+		return err
+	}
+
+	return post(ctx, s.Client, s.URL, body)
+}
+
+// SlackMessage formats an event with Slack's mrkdwn
+func SlackMessage(e Event) string {
+	var b strings.Builder
+
+	title, ok := slackTitles[e.Type]
+
+	if !ok {
+		title = string(e.Type)
+	}
+
+	fmt.Fprintf(&b, "*%s* for `%s` in cluster `%s` (%s)", title, strings.Join(e.Services, ", "), e.Cluster, e.Mode)
+
+	for _, image := range e.Images {
+		if image.Previous == "" || image.Previous == image.Image {
+			fmt.Fprintf(&b, "\n• %s: `%s`", image.Container, image.Image)
+		} else {
+			fmt.Fprintf(&b, "\n• %s: `%s` → `%s`", image.Container, image.Previous, image.Image)
+		}
+	}
+
+	if e.FailureCategory != "" {
+		fmt.Fprintf(&b, "\n• Failure: %s", e.FailureCategory)
+	}
+
+	if e.Error != "" {
+		fmt.Fprintf(&b, "\n• Error: %s", e.Error)
+	}
+
+	if e.Rollback != "" {
+		fmt.Fprintf(&b, "\n• Rollback %s", e.Rollback)
+	}
+
+	if e.BuildLink != "" {
+		fmt.Fprintf(&b, "\n<%s|Drone build>", e.BuildLink)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Webhook posts every event as JSON to URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w Webhook) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	return post(ctx, w.Client, w.URL, body)
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+var ecrRegistryPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// Authenticator returns the credentials used to authenticate against a registry
+// Empty credentials mean anonymous access
+type Authenticator interface {
+	Credentials(ctx context.Context, registry string) (username string, password string, err error)
+}
+
+// Anonymous never sends credentials
+type Anonymous struct{}
+
+func (Anonymous) Credentials(ctx context.Context, registry string) (string, string, error) {
+	return "", "", nil
+}
+
+// StaticCredentials uses the same username and password for every registry
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+func (s StaticCredentials) Credentials(ctx context.Context, registry string) (string, string, error) {
+	return s.Username, s.Password, nil
+}
+
+// ECRCredentials retrieves credentials for ECR registries with GetAuthorizationToken
+// Registries that are not hosted in ECR use Fallback
+type ECRCredentials struct {
+	// NewClient returns an ECR client for the region the registry is in
+	NewClient func(region string) types.ECRClient
+	Fallback  Authenticator
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// IsECRRegistry returns true if the registry host is an ECR private registry
+func IsECRRegistry(registry string) bool {
+	return ecrRegistryPattern.MatchString(registry)
+}
+
+func (e *ECRCredentials) Credentials(ctx context.Context, registry string) (string, string, error) {
+	matches := ecrRegistryPattern.FindStringSubmatch(registry)
+
+	if matches == nil {
+		if e.Fallback == nil {
+			return "", "", nil
+		}
+
+		return e.Fallback.Credentials(ctx, registry)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	token, ok := e.tokens[registry]
+
+	if !ok {
+		out, err := e.NewClient(matches[3]).GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+
+		if err != nil {
+			return "", "", fmt.Errorf("could not get ECR authorization token for %s: %v", registry, err)
+		}
+
+		if len(out.AuthorizationData) == 0 || out.AuthorizationData[0].AuthorizationToken == nil {
+			return "", "", errors.New("no ECR authorization data returned")
+		}
+
+		token = *out.AuthorizationData[0].AuthorizationToken
+
+		if e.tokens == nil {
+			e.tokens = make(map[string]string)
+		}
+
+		e.tokens[registry] = token
+	}
+
+	// The token is base64 encoded username:password
+	decoded, err := base64.StdEncoding.DecodeString(token)
+
+	if err != nil {
+		return "", "", fmt.Errorf("could not decode ECR authorization token: %v", err)
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+
+	if len(parts) != 2 {
+		return "", "", errors.New("invalid ECR authorization token")
+	}
+
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/assemblyai/drone-deploy-ecs/pkg/types"
+	"gotest.tools/assert"
+)
+
+func TestIsECRRegistry(t *testing.T) {
+	assert.Equal(t, true, IsECRRegistry("123456789012.dkr.ecr.us-east-2.amazonaws.com"))
+	assert.Equal(t, true, IsECRRegistry("123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn"))
+	assert.Equal(t, false, IsECRRegistry("registry-1.docker.io"))
+	assert.Equal(t, false, IsECRRegistry("public.ecr.aws"))
+}
+
+func TestECRCredentials(t *testing.T) {
+	calls := 0
+	var regions []string
+
+	auth := &ECRCredentials{
+		NewClient: func(region string) types.ECRClient {
+			regions = append(regions, region)
+			return MockECRClient{TestingT: t, Username: "AWS", Password: "secret-token", Calls: &calls}
+		},
+		Fallback: StaticCredentials{Username: "docker", Password: "hub"},
+	}
+
+	username, password, err := auth.Credentials(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	assert.NilError(t, err)
+	assert.Equal(t, "AWS", username)
+	assert.Equal(t, "secret-token", password)
+
+	// Tokens are cached per registry
+	_, _, err = auth.Credentials(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.DeepEqual(t, []string{"us-west-2"}, regions)
+
+	username, password, err = auth.Credentials(context.Background(), "registry-1.docker.io")
+	assert.NilError(t, err)
+	assert.Equal(t, "docker", username)
+	assert.Equal(t, "hub", password)
+}
+
+func TestECRCredentialsError(t *testing.T) {
+	auth := &ECRCredentials{
+		NewClient: func(region string) types.ECRClient {
+			return MockECRClient{TestingT: t, WantError: true}
+		},
+	}
+
+	_, _, err := auth.Credentials(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	assert.ErrorContains(t, err, "could not get ECR authorization token")
+}
@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+type MockECRClient struct {
+	TestingT  *testing.T
+	WantError bool
+	Username  string
+	Password  string
+	// Calls counts GetAuthorizationToken calls when set
+	Calls *int
+}
+
+func (c MockECRClient) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	if c.Calls != nil {
+		*c.Calls++
+	}
+
+	if c.WantError {
+		return nil, errors.New("error")
+	}
+
+	token := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+
+	out := ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []ecrtypes.AuthorizationData{
+			{
+				AuthorizationToken: aws.String(token),
+				ProxyEndpoint:      aws.String("https://123456789012.dkr.ecr.us-east-2.amazonaws.com"),
+			},
+		},
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	defaultTag        = "latest"
+)
+
+// dockerHubAliases are the hosts that references can spell Docker Hub as. Its registry API is only served by dockerHubRegistry
+var dockerHubAliases = map[string]bool{
+	"docker.io":       true,
+	"index.docker.io": true,
+}
+
+// Reference is a parsed container image reference such as myorg/app:1.0 or 123456789012.dkr.ecr.us-east-2.amazonaws.com/app@sha256:...
+type Reference struct {
+	// Registry is the registry host, including the port if set
+	Registry string
+	// Repository is the repository within the registry
+	Repository string
+	// Tag is empty if the reference is pinned to a digest and has no tag
+	Tag string
+	// Digest is empty unless the reference is pinned to a digest
+	Digest string
+	// name is the image name as written in the original reference, without tag or digest
+	name string
+}
+
+// ParseReference parses an image reference using the same defaults as docker pull
+func ParseReference(image string) (Reference, error) {
+	r := Reference{}
+
+	if image == "" {
+		return r, errors.New("empty image reference")
+	}
+
+	name := image
+
+	if idx := strings.Index(name, "@"); idx != -1 {
+		r.Digest = name[idx+1:]
+		name = name[:idx]
+
+		if !strings.Contains(r.Digest, ":") {
+			return r, fmt.Errorf("invalid digest in image reference '%s'", image)
+		}
+	}
+
+	// A colon after the last slash separates the tag. A colon before it is a registry port
+	if idx := strings.LastIndex(name, ":"); idx != -1 && idx > strings.LastIndex(name, "/") {
+		r.Tag = name[idx+1:]
+		name = name[:idx]
+	}
+
+	if name == "" {
+		return r, fmt.Errorf("invalid image reference '%s'", image)
+	}
+
+	r.name = name
+
+	parts := strings.SplitN(name, "/", 2)
+
+	if len(parts) == 2 && dockerHubAliases[parts[0]] {
+		// docker.io/nginx is the same image as nginx
+		r.Registry = dockerHubRegistry
+		r.Repository = parts[1]
+
+		if !strings.Contains(r.Repository, "/") {
+			r.Repository = "library/" + r.Repository
+		}
+	} else if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		r.Registry = parts[0]
+		r.Repository = parts[1]
+	} else {
+		r.Registry = dockerHubRegistry
+		r.Repository = name
+
+		if len(parts) == 1 {
+			r.Repository = "library/" + name
+		}
+	}
+
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+
+	return r, nil
+}
+
+// String returns the reference as it was originally written, with the default tag added if needed
+func (r Reference) String() string {
+	s := r.name
+
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+
+	return s
+}
+
+// WithDigest returns the immutable form of the reference, name@digest
+func (r Reference) WithDigest(digest string) string {
+	return r.name + "@" + digest
+}
@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name       string
+		image      string
+		want       Reference
+		wantString string
+		wantErr    bool
+	}{
+		{
+			name:       "docker-hub-official",
+			image:      "nginx",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "library/nginx", Tag: "latest", name: "nginx"},
+			wantString: "nginx:latest",
+		},
+		{
+			name:       "docker-hub-org",
+			image:      "myorg/app:abc123",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "myorg/app", Tag: "abc123", name: "myorg/app"},
+			wantString: "myorg/app:abc123",
+		},
+		{
+			name:       "docker-io-official",
+			image:      "docker.io/nginx:1.25",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "library/nginx", Tag: "1.25", name: "docker.io/nginx"},
+			wantString: "docker.io/nginx:1.25",
+		},
+		{
+			name:       "docker-io-org",
+			image:      "docker.io/myorg/app:abc123",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "myorg/app", Tag: "abc123", name: "docker.io/myorg/app"},
+			wantString: "docker.io/myorg/app:abc123",
+		},
+		{
+			name:       "index-docker-io",
+			image:      "index.docker.io/library/nginx",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "library/nginx", Tag: "latest", name: "index.docker.io/library/nginx"},
+			wantString: "index.docker.io/library/nginx:latest",
+		},
+		{
+			name:       "ecr",
+			image:      "123456789012.dkr.ecr.us-east-2.amazonaws.com/team/app:1.0",
+			want:       Reference{Registry: "123456789012.dkr.ecr.us-east-2.amazonaws.com", Repository: "team/app", Tag: "1.0", name: "123456789012.dkr.ecr.us-east-2.amazonaws.com/team/app"},
+			wantString: "123456789012.dkr.ecr.us-east-2.amazonaws.com/team/app:1.0",
+		},
+		{
+			name:       "registry-with-port",
+			image:      "localhost:5000/app",
+			want:       Reference{Registry: "localhost:5000", Repository: "app", Tag: "latest", name: "localhost:5000/app"},
+			wantString: "localhost:5000/app:latest",
+		},
+		{
+			name:       "digest",
+			image:      "myorg/app@sha256:abcdef",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "myorg/app", Digest: "sha256:abcdef", name: "myorg/app"},
+			wantString: "myorg/app@sha256:abcdef",
+		},
+		{
+			name:       "tag-and-digest",
+			image:      "myorg/app:1.0@sha256:abcdef",
+			want:       Reference{Registry: "registry-1.docker.io", Repository: "myorg/app", Tag: "1.0", Digest: "sha256:abcdef", name: "myorg/app"},
+			wantString: "myorg/app:1.0@sha256:abcdef",
+		},
+		{
+			name:    "empty",
+			image:   "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-digest",
+			image:   "myorg/app@abcdef",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseReference() = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.wantString {
+				t.Errorf("Reference.String() = %v, want %v", got.String(), tt.wantString)
+			}
+		})
+	}
+}
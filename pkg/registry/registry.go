@@ -0,0 +1,318 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// manifestMediaTypes are the manifest formats accepted when resolving a digest
+// Manifest lists and indexes are preferred so multi-architecture images resolve to the same digest as docker pull
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// Resolver resolves image tags to manifest digests with the Docker Registry HTTP API v2
+type Resolver struct {
+	Client *http.Client
+	Auth   Authenticator
+	// PlainHTTP talks to registries over http instead of https. Only useful for local registries
+	PlainHTTP bool
+}
+
+// NewResolver returns a Resolver that uses auth to authenticate against registries
+func NewResolver(auth Authenticator) *Resolver {
+	return &Resolver{
+		Client: &http.Client{Timeout: 30 * time.Second},
+		Auth:   auth,
+	}
+}
+
+// Resolve returns the immutable name@digest form of image
+// Images that are already pinned to a digest are returned unchanged
+func (r *Resolver) Resolve(ctx context.Context, image string) (string, error) {
+	ref, err := ParseReference(image)
+
+	if err != nil {
+		return "", err
+	}
+
+	if ref.Digest != "" {
+		return image, nil
+	}
+
+	digest, err := r.Digest(ctx, ref)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ref.WithDigest(digest), nil
+}
+
+// Digest returns the manifest digest of ref's tag
+func (r *Resolver) Digest(ctx context.Context, ref Reference) (string, error) {
+	scheme := "https"
+
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, ref.Registry, ref.Repository, ref.Tag)
+
+	// Try HEAD first since it does not count against Docker Hub pull limits
+	resp, err := r.do(ctx, http.MethodHead, manifestURL, ref)
+
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+			return digest, nil
+		}
+	}
+
+	// Some registries do not return the digest on HEAD requests. Compute it from the manifest instead
+	resp, err = r.do(ctx, http.MethodGet, manifestURL, ref)
+
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry returned status %d for %s", resp.StatusCode, ref.String())
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+}
+
+// do sends a manifest request and retries it once with credentials if the registry asks for authentication
+func (r *Resolver) do(ctx context.Context, method string, manifestURL string, ref Reference) (*http.Response, error) {
+	resp, err := r.send(ctx, method, manifestURL, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	authorization, err := r.authorize(ctx, ref, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.send(ctx, method, manifestURL, authorization)
+}
+
+func (r *Resolver) send(ctx context.Context, method string, target string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return r.Client.Do(req)
+}
+
+// authorize returns the Authorization header that satisfies a WWW-Authenticate challenge
+func (r *Resolver) authorize(ctx context.Context, ref Reference, challenge string) (string, error) {
+	username, password, err := r.credentials(ctx, ref.Registry)
+
+	if err != nil {
+		return "", err
+	}
+
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" && password == "" {
+			return "", fmt.Errorf("registry %s requires credentials", ref.Registry)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(username, password)
+
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := r.token(ctx, ref, params, username, password)
+
+		if err != nil {
+			return "", err
+		}
+
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge '%s' from registry %s", challenge, ref.Registry)
+	}
+}
+
+func (r *Resolver) credentials(ctx context.Context, registry string) (string, string, error) {
+	if r.Auth == nil {
+		return "", "", nil
+	}
+
+	return r.Auth.Credentials(ctx, registry)
+}
+
+// token fetches a bearer token from the realm in a challenge
+func (r *Resolver) token(ctx context.Context, ref Reference, params map[string]string, username string, password string) (string, error) {
+	realm, ok := params["realm"]
+
+	if !ok {
+		return "", errors.New("bearer challenge has no realm")
+	}
+
+	tokenURL, err := url.Parse(realm)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid bearer realm '%s': %v", realm, err)
+	}
+
+	scope := params["scope"]
+
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Repository)
+	}
+
+	q := tokenURL.Query()
+	q.Set("scope", scope)
+
+	if service, ok := params["service"]; ok {
+		q.Set("service", service)
+	}
+
+	tokenURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := r.Client.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d for %s", resp.StatusCode, ref.String())
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("could not decode token response: %v", err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+
+	return "", errors.New("token endpoint returned no token")
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and parameters
+// Parameter values may be quoted and contain commas, e.g. scope="repository:foo:pull,push"
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	challenge = strings.TrimSpace(challenge)
+
+	idx := strings.Index(challenge, " ")
+
+	if idx == -1 {
+		return challenge, params
+	}
+
+	scheme := challenge[:idx]
+	rest := challenge[idx+1:]
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, ", ")
+		eq := strings.Index(rest, "=")
+
+		if eq == -1 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+
+			if end == -1 {
+				value = rest[1:]
+				rest = ""
+			} else {
+				value = rest[1 : end+1]
+				rest = rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+
+			if end == -1 {
+				value = rest
+				rest = ""
+			} else {
+				value = rest[:end]
+				rest = rest[end:]
+			}
+		}
+
+		params[key] = value
+	}
+
+	return scheme, params
+}
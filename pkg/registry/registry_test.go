@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const testManifest = `{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.v2+json"}`
+
+// newTestRegistry starts a local stand-in for a registry that serves a single manifest for myorg/app:1.0
+// auth is one of "none", "basic" or "bearer"
+func newTestRegistry(t *testing.T, auth string, sendDigest bool) *httptest.Server {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testManifest)))
+
+	mux := http.NewServeMux()
+	var server *httptest.Server
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		assert.Equal(t, "repository:myorg/app:pull", r.URL.Query().Get("scope"))
+		assert.Equal(t, "test-registry", r.URL.Query().Get("service"))
+
+		json.NewEncoder(w).Encode(map[string]string{"token": "test-token"})
+	})
+
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		switch auth {
+		case "basic":
+			username, password, ok := r.BasicAuth()
+
+			if !ok || username != "user" || password != "pass" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test-registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "bearer":
+			if r.Header.Get("Authorization") != "Bearer test-token" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry",scope="repository:myorg/app:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		assert.Assert(t, strings.Contains(r.Header.Get("Accept"), "application/vnd.docker.distribution.manifest.list.v2+json"))
+
+		if r.URL.Path != "/v2/myorg/app/manifests/1.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if sendDigest {
+			w.Header().Set("Docker-Content-Digest", digest)
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+
+		if r.Method == http.MethodGet {
+			w.Write([]byte(testManifest))
+		}
+	})
+
+	server = httptest.NewServer(mux)
+
+	return server
+}
+
+func TestResolverResolve(t *testing.T) {
+	wantDigest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testManifest)))
+
+	tests := []struct {
+		name       string
+		auth       string
+		sendDigest bool
+		creds      Authenticator
+		image      string
+		wantErr    bool
+	}{
+		{name: "anonymous", auth: "none", sendDigest: true, creds: Anonymous{}, image: "myorg/app:1.0"},
+		{name: "basic", auth: "basic", sendDigest: true, creds: StaticCredentials{Username: "user", Password: "pass"}, image: "myorg/app:1.0"},
+		{name: "bearer", auth: "bearer", sendDigest: true, creds: StaticCredentials{Username: "user", Password: "pass"}, image: "myorg/app:1.0"},
+		{name: "computed-digest", auth: "none", sendDigest: false, creds: Anonymous{}, image: "myorg/app:1.0"},
+		{name: "basic-no-credentials", auth: "basic", sendDigest: true, creds: Anonymous{}, image: "myorg/app:1.0", wantErr: true},
+		{name: "bearer-wrong-credentials", auth: "bearer", sendDigest: true, creds: StaticCredentials{Username: "user", Password: "wrong"}, image: "myorg/app:1.0", wantErr: true},
+		{name: "unknown-tag", auth: "none", sendDigest: true, creds: Anonymous{}, image: "myorg/app:2.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestRegistry(t, tt.auth, tt.sendDigest)
+			defer server.Close()
+
+			host := strings.TrimPrefix(server.URL, "http://")
+
+			r := NewResolver(tt.creds)
+			r.PlainHTTP = true
+
+			got, err := r.Resolve(context.Background(), host+"/"+tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			assert.Equal(t, host+"/myorg/app@"+wantDigest, got)
+		})
+	}
+}
+
+func TestResolverResolvePinnedImage(t *testing.T) {
+	r := NewResolver(Anonymous{})
+
+	// No request should be made for an image that is already pinned
+	got, err := r.Resolve(context.Background(), "myorg/app@sha256:abcdef")
+
+	assert.NilError(t, err)
+	assert.Equal(t, "myorg/app@sha256:abcdef", got)
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:myorg/app:pull,push"`)
+
+	assert.Equal(t, "Bearer", scheme)
+	assert.DeepEqual(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:myorg/app:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+
+	assert.Equal(t, "Basic", scheme)
+	assert.DeepEqual(t, map[string]string{"realm": "registry"}, params)
+}
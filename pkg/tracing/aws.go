@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// AppendMiddlewares adds a middleware to AWS SDK clients that records every API call, including its retries, as a client span
+// Pass the APIOptions of an aws.Config. Calls whose context has no tracer are not recorded
+func AppendMiddlewares(apiOptions *[]func(*middleware.Stack) error) {
+	*apiOptions = append(*apiOptions, func(stack *middleware.Stack) error {
+		// After, so the service and operation names have been set by the client
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("DeployTracing", traceCall), middleware.After)
+	})
+}
+
+func traceCall(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service := awsmiddleware.GetServiceID(ctx)
+	operation := awsmiddleware.GetOperationName(ctx)
+
+	ctx, span := StartKind(ctx, service+"."+operation, SpanKindClient,
+		String("rpc.system", "aws-api"),
+		String("rpc.service", service),
+		String("rpc.method", operation),
+		String("cloud.region", awsmiddleware.GetRegion(ctx)),
+	)
+
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+		span.SetAttributes(String("aws.request_id", requestID))
+	}
+
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		span.SetAttributes(Int("http.status_code", resp.StatusCode))
+	}
+
+	span.End(err)
+
+	return out, metadata, err
+}
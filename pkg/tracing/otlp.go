@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// scopeName is the instrumentation scope of every span
+const scopeName = "github.com/assemblyai/drone-deploy-ecs"
+
+// OTLP status codes
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// OTLPExporter posts spans to an OTLP/HTTP collector, such as the OpenTelemetry Collector, using the JSON encoding
+type OTLPExporter struct {
+	// Endpoint is the base URL of the collector, such as http://localhost:4318. Spans are posted to its /v1/traces path
+	Endpoint string
+	// Headers are sent with every export, such as an API key of a hosted backend
+	Headers map[string]string
+	Client  *http.Client
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an AnyValue. 64 bit integers are encoded as strings
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	var kvs []otlpKeyValue
+
+	for _, a := range attrs {
+		var v otlpValue
+
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case int32:
+			s := strconv.FormatInt(int64(value), 10)
+			v.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		case bool:
+			v.BoolValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+
+		kvs = append(kvs, otlpKeyValue{Key: a.Key, Value: v})
+	}
+
+	return kvs
+}
+
+func otlpSpanOf(s *Span) otlpSpan {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.SpanID[:]),
+		Name:              s.Name,
+		Kind:              s.Kind,
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.Attributes),
+		Status:            otlpStatus{Code: statusUnset},
+	}
+
+	if s.ParentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+	}
+
+	if s.Error != "" {
+		span.Status = otlpStatus{Code: statusError, Message: s.Error}
+	}
+
+	return span
+}
+
+func (e OTLPExporter) Export(ctx context.Context, resource []Attribute, spans []*Span) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: scopeName}}
+
+	for _, s := range spans {
+		scope.Spans = append(scope.Spans, otlpSpanOf(s))
+	}
+
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource:   otlpResource{Attributes: otlpAttributes(resource)},
+				ScopeSpans: []otlpScopeSpans{scope},
+			},
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.Endpoint, "/")+"/v1/traces", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d from OTLP collector: %s", resp.StatusCode, string(b))
+	}
+
+	return nil
+}
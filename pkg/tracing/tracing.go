@@ -0,0 +1,215 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+// Span kinds use the values of the OTLP protocol
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute is a key value of a span. Value is a string, int, int64, float64 or bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, resource []Attribute, spans []*Span) error
+}
+
+// Tracer collects the spans of a deploy until they are flushed to its Exporter
+// A nil Tracer, and a context without a Tracer, records nothing
+type Tracer struct {
+	Exporter Exporter
+	// Resource describes the process that produced the spans, such as its service.name
+	Resource []Attribute
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewTracer returns a tracer that exports spans with serviceName as their service.name
+func NewTracer(exporter Exporter, serviceName string) *Tracer {
+	return &Tracer{
+		Exporter: exporter,
+		Resource: []Attribute{String("service.name", serviceName)},
+	}
+}
+
+// Flush exports every span that has ended since the last flush
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	return t.Exporter.Export(ctx, t.Resource, spans)
+}
+
+func (t *Tracer) record(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = append(t.spans, s)
+}
+
+// Span is a timed operation of a trace. Every method of a nil Span does nothing
+type Span struct {
+	Name       string
+	Kind       SpanKind
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+	// Error is the error the span ended with, if any
+	Error string
+
+	tracer *Tracer
+	mu     sync.Mutex
+	ended  bool
+}
+
+type tracerKey struct{}
+type spanKey struct{}
+
+// WithTracer returns a context whose spans are recorded by t
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// ContextWithSpan returns a context whose new spans are children of s
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext returns the current span of ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Detach returns a context that keeps the tracer and span of ctx but not its deadline or cancellation
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+
+	if t, ok := ctx.Value(tracerKey{}).(*Tracer); ok {
+		detached = WithTracer(detached, t)
+	}
+
+	return ContextWithSpan(detached, SpanFromContext(ctx))
+}
+
+// Start starts an internal span as a child of the current span of ctx
+// It returns a nil span if ctx has no tracer
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, name, SpanKindInternal, attrs...)
+}
+
+// StartKind starts a span of kind as a child of the current span of ctx
+func StartKind(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	s := &Span{
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: attrs,
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.tracer = parent.tracer
+		s.TraceID = parent.TraceID
+		s.ParentID = parent.SpanID
+	} else {
+		s.tracer, _ = ctx.Value(tracerKey{}).(*Tracer)
+		rand.Read(s.TraceID[:])
+	}
+
+	if s.tracer == nil {
+		return ctx, nil
+	}
+
+	rand.Read(s.SpanID[:])
+
+	return ContextWithSpan(ctx, s), s
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+// End finishes the span with the error of its operation, if any. Only the first call has an effect
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	s.ended = true
+	s.EndTime = time.Now()
+
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	s.mu.Unlock()
+
+	s.tracer.record(s)
+}
+
+// TraceIDString returns the hex trace ID of the span, or an empty string for a nil span
+func (s *Span) TraceIDString() string {
+	if s == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(s.TraceID[:])
+}
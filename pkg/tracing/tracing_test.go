@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"gotest.tools/assert"
+)
+
+// recorder is an Exporter that keeps every exported span
+type recorder struct {
+	spans []*Span
+}
+
+func (r *recorder) Export(ctx context.Context, resource []Attribute, spans []*Span) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func attribute(s *Span, key string) interface{} {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+
+	return nil
+}
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx, span := Start(context.TODO(), "deploy")
+
+	assert.Assert(t, span == nil)
+	assert.Assert(t, SpanFromContext(ctx) == nil)
+
+	// A nil span does nothing
+	span.SetAttributes(String("key", "value"))
+	span.End(errors.New("failed"))
+	assert.Equal(t, "", span.TraceIDString())
+}
+
+func TestSpans(t *testing.T) {
+	r := &recorder{}
+	tracer := NewTracer(r, "drone-deploy-ecs")
+
+	ctx, root := Start(WithTracer(context.TODO(), tracer), "deploy", String("ecs.cluster", "prod-ecs-cluster"))
+	_, child := Start(ctx, "update service")
+	child.End(errors.New("service not found"))
+	child.End(nil)
+
+	// A detached context keeps the trace but not the deadline
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	detached := Detach(deadlineCtx)
+	_, ok := detached.Deadline()
+	assert.Assert(t, !ok)
+
+	_, cleanup := Start(detached, "rollback")
+	cleanup.End(nil)
+	root.End(nil)
+
+	assert.NilError(t, tracer.Flush(context.TODO()))
+	assert.Equal(t, 3, len(r.spans))
+
+	assert.Equal(t, "update service", r.spans[0].Name)
+	assert.Equal(t, "service not found", r.spans[0].Error)
+	assert.Equal(t, root.TraceID, r.spans[0].TraceID)
+	assert.Equal(t, root.SpanID, r.spans[0].ParentID)
+
+	assert.Equal(t, "rollback", r.spans[1].Name)
+	assert.Equal(t, root.SpanID, r.spans[1].ParentID)
+
+	assert.Equal(t, "deploy", r.spans[2].Name)
+	assert.Equal(t, [8]byte{}, r.spans[2].ParentID)
+	assert.Equal(t, "prod-ecs-cluster", attribute(r.spans[2], "ecs.cluster"))
+	assert.Equal(t, 32, len(root.TraceIDString()))
+
+	// Flushed spans are not exported again
+	assert.NilError(t, tracer.Flush(context.TODO()))
+	assert.Equal(t, 3, len(r.spans))
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpRequest
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer(OTLPExporter{Endpoint: collector.URL + "/", Headers: map[string]string{"X-Api-Key": "secret"}, Client: collector.Client()}, "drone-deploy-ecs")
+
+	ctx, root := Start(WithTracer(context.TODO(), tracer), "deploy")
+	_, child := Start(ctx, "check", Int("deploy.check", 2), Bool("deploy.dry_run", false))
+	child.End(errors.New("deployment failed"))
+	root.End(nil)
+
+	assert.NilError(t, tracer.Flush(context.TODO()))
+
+	assert.Equal(t, 1, len(got.ResourceSpans))
+	assert.Equal(t, "service.name", got.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "drone-deploy-ecs", *got.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, 2, len(spans))
+
+	check := spans[0]
+	assert.Equal(t, "check", check.Name)
+	assert.Equal(t, root.TraceIDString(), check.TraceID)
+	assert.Equal(t, spans[1].SpanID, check.ParentSpanID)
+	assert.Equal(t, SpanKindInternal, check.Kind)
+	assert.Equal(t, "2", *check.Attributes[0].Value.IntValue)
+	assert.Equal(t, false, *check.Attributes[1].Value.BoolValue)
+	assert.Equal(t, otlpStatus{Code: statusError, Message: "deployment failed"}, check.Status)
+
+	assert.Equal(t, "", spans[1].ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: statusUnset}, spans[1].Status)
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer(OTLPExporter{Endpoint: collector.URL, Client: collector.Client()}, "drone-deploy-ecs")
+
+	_, span := Start(WithTracer(context.TODO(), tracer), "deploy")
+	span.End(nil)
+
+	assert.ErrorContains(t, tracer.Flush(context.TODO()), "unexpected status 503")
+}
+
+func TestAppendMiddlewares(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RequestId", "request-1")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"services": []}`))
+	}))
+	defer server.Close()
+
+	options := ecs.Options{
+		Region:           "us-east-2",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: ecs.EndpointResolverFromURL(server.URL),
+		HTTPClient:       server.Client(),
+	}
+
+	AppendMiddlewares(&options.APIOptions)
+
+	client := ecs.New(options)
+
+	r := &recorder{}
+	tracer := NewTracer(r, "drone-deploy-ecs")
+
+	ctx, root := Start(WithTracer(context.TODO(), tracer), "deploy")
+
+	_, err := client.DescribeServices(ctx, &ecs.DescribeServicesInput{Services: []string{"webapp"}})
+	assert.NilError(t, err)
+
+	// Calls without a tracer are not recorded
+	_, err = client.DescribeServices(context.TODO(), &ecs.DescribeServicesInput{Services: []string{"webapp"}})
+	assert.NilError(t, err)
+
+	root.End(nil)
+	assert.NilError(t, tracer.Flush(context.TODO()))
+
+	assert.Equal(t, 2, len(r.spans))
+
+	call := r.spans[0]
+	assert.Equal(t, "ECS.DescribeServices", call.Name)
+	assert.Equal(t, SpanKindClient, call.Kind)
+	assert.Equal(t, root.SpanID, call.ParentID)
+	assert.Equal(t, "request-1", attribute(call, "aws.request_id"))
+	assert.Equal(t, 200, attribute(call, "http.status_code"))
+	assert.Equal(t, "us-east-2", attribute(call, "cloud.region"))
+}